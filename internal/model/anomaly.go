@@ -10,10 +10,68 @@ const (
 	SeverityLow    AnomalySeverity = "Low"
 )
 
+// AnomalyType says what kind of check raised an anomaly.
+type AnomalyType string
+
+const (
+	AnomalyCategoryAmount AnomalyType = "Unusual for Category"
+	AnomalyPayeeAmount    AnomalyType = "Unusual for Payee"
+	AnomalyNewPayee       AnomalyType = "New Payee"
+	AnomalyUnusualDay     AnomalyType = "Unusual Day"
+	AnomalyBudgetDrift    AnomalyType = "Budget Drift"
+	AnomalyPriceIncrease  AnomalyType = "Price Increase"
+)
+
 type Anomaly struct {
-	ID          int64 // Virtual ID usually
-	Type        string // "Large Transaction", "Budget Drift"
-	Description string
-	Severity    AnomalySeverity
-	Date        string
+	ID            int64  // Virtual ID usually
+	Key           string // Stable identity, used to dismiss it
+	Type          AnomalyType
+	Description   string
+	Severity      AnomalySeverity
+	Date          string
+	TransactionID int64   // The transaction it is about, if any
+	Related       []int64 // Transactions behind it, e.g. a budget's spending
+	Score         float64 // Robust z-score for amount anomalies
+}
+
+// AnomalySettings are the tunable thresholds for anomaly detection.
+type AnomalySettings struct {
+	LookbackDays   int     // Transactions this recent are checked
+	BaselineMonths int     // History a transaction is compared against
+	MinHistory     int     // Earlier payments needed before a baseline is trusted
+	LowScore       float64 // Robust z-scores at or above these raise an alert
+	MediumScore    float64
+	HighScore      float64
+	NewPayeeMin    int64 // First payments to a payee below this (base minor units) are ignored
+	FlagNewPayees  bool
+	FlagUnusualDay bool
+
+	// Budget drift: warn once spending runs this far ahead of the period's
+	// pace, e.g. 1.1 when 55% is spent with half the month gone
+	BudgetPace      float64
+	FlagBudgetDrift bool
+
+	// Price increases: a recurring charge this much above the previous one
+	PriceIncreaseMin   float64
+	FlagPriceIncreases bool
+}
+
+// DefaultAnomalySettings are used until the user changes them.
+func DefaultAnomalySettings() AnomalySettings {
+	return AnomalySettings{
+		LookbackDays:   30,
+		BaselineMonths: 12,
+		MinHistory:     5,
+		LowScore:       3,
+		MediumScore:    5,
+		HighScore:      8,
+		NewPayeeMin:    5000,
+		FlagNewPayees:  true,
+		FlagUnusualDay: true,
+
+		BudgetPace:         1.1,
+		FlagBudgetDrift:    true,
+		PriceIncreaseMin:   0.03,
+		FlagPriceIncreases: true,
+	}
 }
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// Attachment is a receipt or other document kept with a transaction.
+// The file itself is stored once per content hash, so the same receipt
+// attached to several transactions takes up space only once.
+type Attachment struct {
+	ID            int64
+	TransactionID int64
+	Name          string // Original file name
+	MimeType      string // e.g. "image/jpeg" or "application/pdf"
+	Hash          string // Hex SHA-256 of the file
+	Size          int64  // Bytes
+	AddedAt       time.Time
+}
@@ -0,0 +1,260 @@
+package model
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Currency describes an ISO 4217 currency.
+type Currency struct {
+	Code     string
+	Name     string
+	Symbol   string // Empty when the code itself is the clearest label
+	Exponent int    // Digits in the minor unit: 2 for USD, 0 for JPY, 3 for KWD
+}
+
+// defaultExponent applies to unspecified or unknown currencies.
+const defaultExponent = 2
+
+// currencies is the ISO 4217 registry of active currencies.
+var currencies = map[string]Currency{}
+
+func init() {
+	for _, c := range []Currency{
+		{"AED", "UAE Dirham", "", 2},
+		{"AFN", "Afghani", "؋", 2},
+		{"ALL", "Lek", "", 2},
+		{"AMD", "Armenian Dram", "֏", 2},
+		{"ANG", "Netherlands Antillean Guilder", "", 2},
+		{"AOA", "Kwanza", "Kz", 2},
+		{"ARS", "Argentine Peso", "", 2},
+		{"AUD", "Australian Dollar", "A$", 2},
+		{"AWG", "Aruban Florin", "", 2},
+		{"AZN", "Azerbaijan Manat", "₼", 2},
+		{"BAM", "Convertible Mark", "KM", 2},
+		{"BBD", "Barbados Dollar", "", 2},
+		{"BDT", "Taka", "৳", 2},
+		{"BGN", "Bulgarian Lev", "", 2},
+		{"BHD", "Bahraini Dinar", "", 3},
+		{"BIF", "Burundi Franc", "", 0},
+		{"BMD", "Bermudian Dollar", "", 2},
+		{"BND", "Brunei Dollar", "", 2},
+		{"BOB", "Boliviano", "Bs", 2},
+		{"BRL", "Brazilian Real", "R$", 2},
+		{"BSD", "Bahamian Dollar", "", 2},
+		{"BTN", "Ngultrum", "", 2},
+		{"BWP", "Pula", "", 2},
+		{"BYN", "Belarusian Ruble", "", 2},
+		{"BZD", "Belize Dollar", "", 2},
+		{"CAD", "Canadian Dollar", "CA$", 2},
+		{"CDF", "Congolese Franc", "", 2},
+		{"CHF", "Swiss Franc", "", 2},
+		{"CLF", "Unidad de Fomento", "", 4},
+		{"CLP", "Chilean Peso", "", 0},
+		{"CNY", "Yuan Renminbi", "CN¥", 2},
+		{"COP", "Colombian Peso", "", 2},
+		{"CRC", "Costa Rican Colon", "₡", 2},
+		{"CUP", "Cuban Peso", "", 2},
+		{"CVE", "Cabo Verde Escudo", "", 2},
+		{"CZK", "Czech Koruna", "Kč", 2},
+		{"DJF", "Djibouti Franc", "", 0},
+		{"DKK", "Danish Krone", "", 2},
+		{"DOP", "Dominican Peso", "", 2},
+		{"DZD", "Algerian Dinar", "", 2},
+		{"EGP", "Egyptian Pound", "E£", 2},
+		{"ERN", "Nakfa", "", 2},
+		{"ETB", "Ethiopian Birr", "", 2},
+		{"EUR", "Euro", "€", 2},
+		{"FJD", "Fiji Dollar", "", 2},
+		{"FKP", "Falkland Islands Pound", "", 2},
+		{"GBP", "Pound Sterling", "£", 2},
+		{"GEL", "Lari", "₾", 2},
+		{"GHS", "Ghana Cedi", "₵", 2},
+		{"GIP", "Gibraltar Pound", "", 2},
+		{"GMD", "Dalasi", "", 2},
+		{"GNF", "Guinean Franc", "", 0},
+		{"GTQ", "Quetzal", "Q", 2},
+		{"GYD", "Guyana Dollar", "", 2},
+		{"HKD", "Hong Kong Dollar", "HK$", 2},
+		{"HNL", "Lempira", "", 2},
+		{"HTG", "Gourde", "", 2},
+		{"HUF", "Forint", "Ft", 2},
+		{"IDR", "Rupiah", "Rp", 2},
+		{"ILS", "New Israeli Sheqel", "₪", 2},
+		{"INR", "Indian Rupee", "₹", 2},
+		{"IQD", "Iraqi Dinar", "", 3},
+		{"IRR", "Iranian Rial", "", 2},
+		{"ISK", "Iceland Krona", "", 0},
+		{"JMD", "Jamaican Dollar", "", 2},
+		{"JOD", "Jordanian Dinar", "", 3},
+		{"JPY", "Yen", "¥", 0},
+		{"KES", "Kenyan Shilling", "KSh", 2},
+		{"KGS", "Som", "", 2},
+		{"KHR", "Riel", "៛", 2},
+		{"KMF", "Comorian Franc", "", 0},
+		{"KPW", "North Korean Won", "", 2},
+		{"KRW", "Won", "₩", 0},
+		{"KWD", "Kuwaiti Dinar", "", 3},
+		{"KYD", "Cayman Islands Dollar", "", 2},
+		{"KZT", "Tenge", "₸", 2},
+		{"LAK", "Lao Kip", "₭", 2},
+		{"LBP", "Lebanese Pound", "", 2},
+		{"LKR", "Sri Lanka Rupee", "", 2},
+		{"LRD", "Liberian Dollar", "", 2},
+		{"LSL", "Loti", "", 2},
+		{"LYD", "Libyan Dinar", "", 3},
+		{"MAD", "Moroccan Dirham", "", 2},
+		{"MDL", "Moldovan Leu", "", 2},
+		{"MGA", "Malagasy Ariary", "", 2},
+		{"MKD", "Denar", "", 2},
+		{"MMK", "Kyat", "", 2},
+		{"MNT", "Tugrik", "₮", 2},
+		{"MOP", "Pataca", "", 2},
+		{"MRU", "Ouguiya", "", 2},
+		{"MUR", "Mauritius Rupee", "", 2},
+		{"MVR", "Rufiyaa", "", 2},
+		{"MWK", "Malawi Kwacha", "", 2},
+		{"MXN", "Mexican Peso", "MX$", 2},
+		{"MYR", "Malaysian Ringgit", "RM", 2},
+		{"MZN", "Mozambique Metical", "", 2},
+		{"NAD", "Namibia Dollar", "", 2},
+		{"NGN", "Naira", "₦", 2},
+		{"NIO", "Cordoba Oro", "", 2},
+		{"NOK", "Norwegian Krone", "", 2},
+		{"NPR", "Nepalese Rupee", "रू", 2},
+		{"NZD", "New Zealand Dollar", "NZ$", 2},
+		{"OMR", "Rial Omani", "", 3},
+		{"PAB", "Balboa", "", 2},
+		{"PEN", "Sol", "S/", 2},
+		{"PGK", "Kina", "", 2},
+		{"PHP", "Philippine Peso", "₱", 2},
+		{"PKR", "Pakistan Rupee", "", 2},
+		{"PLN", "Zloty", "zł", 2},
+		{"PYG", "Guarani", "₲", 0},
+		{"QAR", "Qatari Rial", "", 2},
+		{"RON", "Romanian Leu", "", 2},
+		{"RSD", "Serbian Dinar", "", 2},
+		{"RUB", "Russian Ruble", "₽", 2},
+		{"RWF", "Rwanda Franc", "", 0},
+		{"SAR", "Saudi Riyal", "", 2},
+		{"SBD", "Solomon Islands Dollar", "", 2},
+		{"SCR", "Seychelles Rupee", "", 2},
+		{"SDG", "Sudanese Pound", "", 2},
+		{"SEK", "Swedish Krona", "", 2},
+		{"SGD", "Singapore Dollar", "S$", 2},
+		{"SHP", "Saint Helena Pound", "", 2},
+		{"SLE", "Leone", "", 2},
+		{"SOS", "Somali Shilling", "", 2},
+		{"SRD", "Surinam Dollar", "", 2},
+		{"SSP", "South Sudanese Pound", "", 2},
+		{"STN", "Dobra", "", 2},
+		{"SVC", "El Salvador Colon", "", 2},
+		{"SYP", "Syrian Pound", "", 2},
+		{"SZL", "Lilangeni", "", 2},
+		{"THB", "Baht", "฿", 2},
+		{"TJS", "Somoni", "", 2},
+		{"TMT", "Turkmenistan New Manat", "", 2},
+		{"TND", "Tunisian Dinar", "", 3},
+		{"TOP", "Pa’anga", "T$", 2},
+		{"TRY", "Turkish Lira", "₺", 2},
+		{"TTD", "Trinidad and Tobago Dollar", "", 2},
+		{"TWD", "New Taiwan Dollar", "NT$", 2},
+		{"TZS", "Tanzanian Shilling", "", 2},
+		{"UAH", "Hryvnia", "₴", 2},
+		{"UGX", "Uganda Shilling", "", 0},
+		{"USD", "US Dollar", "$", 2},
+		{"UYU", "Peso Uruguayo", "$U", 2},
+		{"UYW", "Unidad Previsional", "", 4},
+		{"UZS", "Uzbekistan Sum", "", 2},
+		{"VES", "Bolívar Soberano", "", 2},
+		{"VND", "Dong", "₫", 0},
+		{"VUV", "Vatu", "", 0},
+		{"WST", "Tala", "", 2},
+		{"XAF", "CFA Franc BEAC", "FCFA", 0},
+		{"XCD", "East Caribbean Dollar", "EC$", 2},
+		{"XCG", "Caribbean Guilder", "", 2},
+		{"XOF", "CFA Franc BCEAO", "CFA", 0},
+		{"XPF", "CFP Franc", "", 0},
+		{"YER", "Yemeni Rial", "", 2},
+		{"ZAR", "Rand", "R", 2},
+		{"ZMW", "Zambian Kwacha", "", 2},
+		{"ZWG", "Zimbabwe Gold", "", 2},
+	} {
+		currencies[c.Code] = c
+	}
+}
+
+// LookupCurrency finds a currency by its ISO 4217 code.
+func LookupCurrency(code string) (Currency, bool) {
+	c, ok := currencies[strings.ToUpper(strings.TrimSpace(code))]
+	return c, ok
+}
+
+// CurrencyExponent returns the number of minor-unit digits for a code.
+// Unknown or empty codes use two.
+func CurrencyExponent(code string) int {
+	if c, ok := LookupCurrency(code); ok {
+		return c.Exponent
+	}
+	return defaultExponent
+}
+
+// CurrencyCodes lists every registered code, sorted.
+func CurrencyCodes() []string {
+	codes := make([]string, 0, len(currencies))
+	for code := range currencies {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// MinorRate turns a currency rate (units of to per unit of from) into a
+// rate between minor units, e.g. 0.0068 USD per JPY becomes 0.68 cents per
+// yen.
+func MinorRate(rate float64, from, to string) float64 {
+	return rate * math.Pow10(CurrencyExponent(to)-CurrencyExponent(from))
+}
+
+// Format is the one place money is rendered for display: a currency symbol
+// (or code), a minus sign where needed, thousands separators and the
+// currency's own number of decimals, e.g. "-$1,234.56", "¥1,235" or
+// "KWD 12.345".
+func (m Money) Format() string {
+	digits := m.String()
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	whole, frac, hasFrac := strings.Cut(digits, ".")
+	var grouped strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(r)
+	}
+	number := grouped.String()
+	if hasFrac {
+		number += "." + frac
+	}
+
+	c, ok := LookupCurrency(m.Currency)
+	switch {
+	case ok && c.Symbol != "":
+		if endsWithLetter(c.Symbol) {
+			return sign + c.Symbol + " " + number
+		}
+		return sign + c.Symbol + number
+	case m.Currency != "":
+		return sign + strings.ToUpper(m.Currency) + " " + number
+	}
+	return sign + number
+}
+
+func endsWithLetter(s string) bool {
+	r := []rune(s)
+	return len(r) > 1 && unicode.IsLetter(r[len(r)-1])
+}
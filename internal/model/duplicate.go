@@ -0,0 +1,10 @@
+package model
+
+// DuplicatePair is two transactions that look like the same payment
+// entered twice, e.g. a manual entry and its imported bank line.
+type DuplicatePair struct {
+	A       Transaction // The older entry (lower ID)
+	B       Transaction
+	Score   float64  // 0..1; 1 is an exact copy
+	Reasons []string // What matched, e.g. "same amount", "2 days apart"
+}
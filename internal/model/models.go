@@ -25,6 +25,7 @@ type Account struct {
 	Name     string
 	Type     AccountType
 	Currency string
+	IsClosed bool
 }
 
 type Category struct {
@@ -42,6 +43,9 @@ type Transaction struct {
 	Note        string
 	Status      TransactionStatus
 	Splits      []Split
+	Tags        []string
+	PayeeID     *int64 // Set from Description when saved
+	Match       *SearchMatch `json:"-"` // Set by text searches only
 }
 
 type Split struct {
@@ -53,31 +57,216 @@ type Split struct {
 	Amount int64
 
 	Currency      string
-	ExchangeRate  float64
+	ExchangeRate  float64 // Base-currency minor units per minor unit of Currency
+	Tags          []string // In addition to the transaction's own tags
 }
 
+// Transfer moves money between two of the user's own accounts. ToAmount is
+// only needed when the accounts use different currencies; zero means
+// convert at the stored exchange rate.
+type Transfer struct {
+	Date          time.Time
+	FromAccountID int64
+	ToAccountID   int64
+	Amount        Money // Leaves the source account, in its currency
+	ToAmount      Money // Arrives in the destination account
+	Description   string
+	Note          string
+	Tags          []string
+}
+
+type BudgetPeriod string
+
+const (
+	BudgetPeriodWeekly    BudgetPeriod = "Weekly"
+	BudgetPeriodMonthly   BudgetPeriod = "Monthly"
+	BudgetPeriodQuarterly BudgetPeriod = "Quarterly"
+	BudgetPeriodYearly    BudgetPeriod = "Yearly"
+	BudgetPeriodCustom    BudgetPeriod = "Custom"
+)
+
+// Budget is a spending limit for a category. Recurring budgets (weekly to
+// yearly) apply from EffectiveFrom until a later budget for the same
+// category replaces them. Custom budgets cover EffectiveFrom..EndDate only.
 type Budget struct {
-	ID         int64
-	CategoryID int64
-	Amount     int64
-	Period     string
+	ID            int64
+	CategoryID    int64
+	Amount        int64
+	Period        BudgetPeriod
+	EffectiveFrom time.Time
+	EndDate       *time.Time // Custom budgets only (inclusive)
+	Rollover      bool       // Carry unspent (or overspent) amounts into the next period
+}
+
+// ExchangeRate says one unit of From was worth Rate units of To on Date.
+type ExchangeRate struct {
+	ID     int64
+	Date   time.Time
+	From   string
+	To     string
+	Rate   float64
+	Source string // e.g. "manual", "ECB"
+}
+
+// RateImportReport summarizes an exchange-rate file import.
+type RateImportReport struct {
+	Format     string   // "ECB CSV", "ECB XML" or "CSV"
+	Imported   int      // New rates stored
+	Updated    int      // Stored rates replaced by the file's value
+	Duplicates int      // Repeated date/pair rows in the file; the last one wins
+	Filled     int      // Days with no quote filled from the previous day
+	Skipped    int      // Unreadable rows and blank (N/A) quotes
+	Uncovered  []string // Account currencies still lacking a rate to base
+}
+
+// CSVImportReport summarizes a bank CSV import.
+type CSVImportReport struct {
+	Imported   int             // Transactions created
+	Skipped    int             // Rows without a readable date or amount
+	Duplicates []DuplicatePair // Imported rows that look like existing transactions
+}
+
+// AccountValuation is an account's balance valued in the base currency.
+// Amounts are in minor units; CostBasis is the base value at the rates in
+// force when each transaction happened.
+type AccountValuation struct {
+	AccountID    int64
+	AccountName  string
+	Type         AccountType
+	Currency     string
+	Balance      int64   // In the account's currency
+	Rate         float64 // Account currency -> base on the valuation date
+	BaseValue    int64
+	CostBasis    int64
+	UnrealizedFX int64 // BaseValue - CostBasis
+	MissingRate  bool  // A currency had no rate; values needing it are left out
+}
+
+// Reconciliation matches an account against one bank statement. It stays
+// open (CompletedAt nil) until the cleared balance equals the statement.
+// Amounts are in cents.
+type Reconciliation struct {
+	ID               int64
+	AccountID        int64
+	StatementDate    time.Time
+	StatementBalance int64
+	ClearedBalance   int64
+	StartedAt        time.Time
+	CompletedAt      *time.Time
+}
+
+// ReconcileItem is a transaction as it affects the account being reconciled.
+type ReconcileItem struct {
+	TransactionID int64
+	Date          time.Time
+	Description   string
+	Amount        int64 // Net effect on the account, in cents
+	Status        TransactionStatus
+}
+
+// ReconcileSummary tracks how far a session is from matching its statement.
+type ReconcileSummary struct {
+	OpeningBalance int64 // Sum of already reconciled transactions
+	ClearedAmount  int64 // Sum of transactions ticked as Cleared
+	ClearedBalance int64 // OpeningBalance + ClearedAmount
+	Difference     int64 // StatementBalance - ClearedBalance; zero when done
+}
+
+// Envelope is one category's envelope for a month in zero-based budgeting.
+// Amounts are in cents; Available = Carryover + Assigned - Activity.
+type Envelope struct {
+	CategoryID   int64
+	CategoryName string
+	Depth        int   // Nesting level in the category tree, for indentation
+	Budgeted     int64 // Monthly budget limit, if one is set; a suggested assignment
+	Carryover    int64 // Positive balance brought forward from last month
+	Assigned     int64
+	Activity     int64 // Net spending this month
+	Available    int64
+}
+
+// EnvelopeMonth is the zero-based budget for one calendar month.
+type EnvelopeMonth struct {
+	Month           time.Time // First day of the month
+	Income          int64     // Inflow to Income accounts this month
+	Assigned        int64     // Total assigned this month
+	OverspentBefore int64     // Uncovered overspending from earlier months, taken out of the pool
+	ReadyToAssign   int64
+	Envelopes       []Envelope
+}
+
+// RolloverEntry is one closed period in a category's rollover ledger.
+// Amounts are in cents; CarriedOut = CarriedIn + Budgeted - Spent.
+type RolloverEntry struct {
+	ID          int64
+	CategoryID  int64
+	BudgetID    int64
+	PeriodStart time.Time
+	PeriodEnd   time.Time // Inclusive
+	Budgeted    int64
+	Spent       int64
+	CarriedIn   int64
+	CarriedOut  int64
+	ComputedAt  time.Time
 }
 
 type BudgetProgress struct {
+	BudgetID     int64
+	CategoryID   int64
 	CategoryName string
-	Budgeted     float64
-	Spent        float64
-	Remaining    float64
+	Period       BudgetPeriod
+	PeriodStart  time.Time
+	PeriodEnd    time.Time // Inclusive last day of the period
+	Budgeted     Money
+	CarriedIn    Money // Rolled over from the previous period (negative if overspent)
+	Available    Money // Budgeted + CarriedIn
+	Spent        Money
+	Remaining    Money
 	Percent      float64
+	Rollover     bool
 }
 
-// Rule defines smart enrichment logic
+// Rule fills in transactions that meet all of its conditions. Rules apply
+// in Priority order (lowest first); an action set by an earlier rule is
+// not overwritten by a later one, but tags add up.
 type Rule struct {
-	ID             int64
-	Pattern        string // Regex or Simple match
+	ID       int64
+	Name     string
+	Priority int
+
+	// Conditions; unset ones match every transaction
+	Pattern   string // Found in the description (ignoring case), or a regular expression if IsRegex
+	IsRegex   bool
+	MinAmount *int64 // Transaction amount, in minor units of its own currency
+	MaxAmount *int64
+	AccountID *int64 // Any split in this account
+	StartDate *time.Time
+	EndDate   *time.Time // Inclusive
+
+	// Actions; empty ones leave the transaction alone
 	TargetCategoryID *int64
-	TargetPayee    string
-	TargetNote     string
+	TargetPayee      string // Replaces the description
+	TargetNote       string
+	TargetTags       []string
+	TargetStatus     TransactionStatus // Pending or Cleared
+}
+
+// RuleMatch is an existing transaction a rule matches, with what applying
+// the rule would change.
+type RuleMatch struct {
+	Transaction Transaction // As it is now
+	Changes     []string    // e.g. "Category: Food → Coffee"; empty if already up to date
+}
+
+// RuleBatch is one application of a rule to existing transactions, kept so
+// it can be undone.
+type RuleBatch struct {
+	ID        int64
+	RuleID    *int64 // Nil once the rule is deleted
+	RuleName  string
+	AppliedAt time.Time
+	Changed   int
+	UndoneAt  *time.Time
 }
 
 // CategoryBreakdown represents spending by category
@@ -85,13 +274,22 @@ type CategoryBreakdown struct {
 	CategoryID   int64
 	CategoryName string
 	Color        string
-	Amount       float64
+	Amount       Money
+	HasChildren  bool // Amount includes subcategories that can be drilled into
+}
+
+// TagBreakdown represents spending by tag. A split counts toward a tag
+// when it or its transaction carries the tag.
+type TagBreakdown struct {
+	Tag          string
+	Amount       Money
+	Transactions int
 }
 
 // NetWorthPoint represents net worth at a point in time
 type NetWorthPoint struct {
 	Month      string
-	Assets     float64
-	Liabilities float64
-	NetWorth   float64
+	Assets     Money
+	Liabilities Money
+	NetWorth   Money
 }
\ No newline at end of file
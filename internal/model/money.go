@@ -0,0 +1,289 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Money errors.
+var (
+	ErrCurrencyMismatch = errors.New("cannot combine amounts in different currencies")
+	ErrMoneyOverflow    = errors.New("amount is too large")
+	ErrInvalidMoney     = errors.New("invalid amount")
+)
+
+// Money is an exact amount held in a currency's minor units (cents for
+// USD, whole yen for JPY, fils for KWD). An empty Currency means "not
+// specified" and combines with any currency; the zero value is therefore a
+// usable zero.
+type Money struct {
+	Amount   int64  // Minor units
+	Currency string // ISO 4217 code
+}
+
+// NewMoney wraps an amount already in minor units.
+func NewMoney(minor int64, currency string) Money {
+	return Money{Amount: minor, Currency: currency}
+}
+
+func (m Money) IsZero() bool     { return m.Amount == 0 }
+func (m Money) IsNegative() bool { return m.Amount < 0 }
+
+// currencyWith returns the currency shared by m and o.
+func (m Money) currencyWith(o Money) (string, error) {
+	switch {
+	case m.Currency == o.Currency || o.Currency == "":
+		return m.Currency, nil
+	case m.Currency == "":
+		return o.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+}
+
+// Add returns m + o, failing on a currency mismatch or overflow.
+func (m Money) Add(o Money) (Money, error) {
+	cur, err := m.currencyWith(o)
+	if err != nil {
+		return Money{}, err
+	}
+	sum := m.Amount + o.Amount
+	if (o.Amount > 0 && sum < m.Amount) || (o.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: sum, Currency: cur}, nil
+}
+
+// Sub returns m - o, failing on a currency mismatch or overflow.
+func (m Money) Sub(o Money) (Money, error) {
+	neg, err := o.Neg()
+	if err != nil {
+		return Money{}, err
+	}
+	return m.Add(neg)
+}
+
+// Neg returns -m.
+func (m Money) Neg() (Money, error) {
+	if m.Amount == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: -m.Amount, Currency: m.Currency}, nil
+}
+
+// Abs returns |m|.
+func (m Money) Abs() (Money, error) {
+	if m.Amount < 0 {
+		return m.Neg()
+	}
+	return m, nil
+}
+
+// Mul returns m * n.
+func (m Money) Mul(n int64) (Money, error) {
+	p := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(n))
+	if !p.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: p.Int64(), Currency: m.Currency}, nil
+}
+
+// Div returns m / n rounded half to even, e.g. for averages.
+func (m Money) Div(n int64) (Money, error) {
+	if n == 0 {
+		return Money{}, errors.New("division by zero")
+	}
+	q := divHalfEven(big.NewInt(m.Amount), big.NewInt(n))
+	if !q.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: q.Int64(), Currency: m.Currency}, nil
+}
+
+// Convert applies an exchange rate (units of to per unit of m's currency),
+// rescaling between the two currencies' minor units and rounding half to
+// even.
+func (m Money) Convert(rate float64, to string) (Money, error) {
+	if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+		return Money{}, fmt.Errorf("invalid exchange rate %v", rate)
+	}
+	v := math.RoundToEven(float64(m.Amount) * MinorRate(rate, m.Currency, to))
+	if v >= math.MaxInt64 || v < math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: int64(v), Currency: to}, nil
+}
+
+// Allocate splits m into parts proportional to ratios. Each share is
+// rounded half to even, then leftover minor units go to the shares whose
+// rounding was furthest off, so the parts always add back up to m.
+func (m Money) Allocate(ratios ...int64) ([]Money, error) {
+	if len(ratios) == 0 {
+		return nil, errors.New("allocation needs at least one ratio")
+	}
+	total := new(big.Int)
+	for _, r := range ratios {
+		if r < 0 {
+			return nil, errors.New("allocation ratios must not be negative")
+		}
+		total.Add(total, big.NewInt(r))
+	}
+	if total.Sign() == 0 {
+		return nil, errors.New("allocation ratios must not all be zero")
+	}
+
+	amount := big.NewInt(m.Amount)
+	parts := make([]Money, len(ratios))
+	// err is how far each rounded share is above its exact value, scaled by total
+	errs := make([]*big.Int, len(ratios))
+	allocated := new(big.Int)
+	for i, r := range ratios {
+		exact := new(big.Int).Mul(amount, big.NewInt(r))
+		q := divHalfEven(exact, total)
+		errs[i] = new(big.Int).Sub(new(big.Int).Mul(q, total), exact)
+		allocated.Add(allocated, q)
+		parts[i] = Money{Amount: q.Int64(), Currency: m.Currency}
+	}
+
+	left := new(big.Int).Sub(amount, allocated).Int64()
+	order := make([]int, len(parts))
+	for i := range order {
+		order[i] = i
+	}
+	// Give missing units to the most under-rounded shares, take surplus
+	// units from the most over-rounded ones
+	sort.SliceStable(order, func(a, b int) bool {
+		c := errs[order[a]].Cmp(errs[order[b]])
+		if left > 0 {
+			return c < 0
+		}
+		return c > 0
+	})
+	for i := 0; left != 0; i = (i + 1) % len(order) {
+		if ratios[order[i]] == 0 {
+			continue
+		}
+		if left > 0 {
+			parts[order[i]].Amount++
+			left--
+		} else {
+			parts[order[i]].Amount--
+			left++
+		}
+	}
+	return parts, nil
+}
+
+// divHalfEven divides a by b, rounding half to even.
+func divHalfEven(a, b *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(a, b, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	// Compare 2|r| with |b| to decide whether to round away from zero
+	twice := new(big.Int).Abs(r)
+	twice.Lsh(twice, 1)
+	c := twice.Cmp(new(big.Int).Abs(b))
+	if c > 0 || (c == 0 && q.Bit(0) == 1) {
+		if (a.Sign() < 0) != (b.Sign() < 0) {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+// Sum adds amounts in the same currency.
+func Sum(amounts ...Money) (Money, error) {
+	var total Money
+	for _, a := range amounts {
+		var err error
+		if total, err = total.Add(a); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// Float64 returns m in major units. Use it only for charts and ratios,
+// never to do arithmetic on money.
+func (m Money) Float64() float64 {
+	return float64(m.Amount) / math.Pow10(CurrencyExponent(m.Currency))
+}
+
+// String formats m as a plain decimal in major units with the currency's
+// number of decimals, e.g. "-19.99" or "1235" for yen. It is suitable for
+// editable fields; use Format for display.
+func (m Money) String() string {
+	sign := ""
+	n := new(big.Int).SetInt64(m.Amount)
+	if n.Sign() < 0 {
+		sign = "-"
+		n.Neg(n)
+	}
+	exp := CurrencyExponent(m.Currency)
+	if exp == 0 {
+		return sign + n.String()
+	}
+	digits := fmt.Sprintf("%0*s", exp+1, n.String())
+	cut := len(digits) - exp
+	return sign + digits[:cut] + "." + digits[cut:]
+}
+
+// ParseMoney reads a decimal string such as "19.99", "-1,234.5" or "+7"
+// into exact minor units of currency. Thousands separators are allowed;
+// digits beyond the minor unit must be zero, so "19.999" is rejected for
+// USD (but is fine for KWD) rather than rounded.
+func ParseMoney(s, currency string) (Money, error) {
+	minorDigits := CurrencyExponent(currency)
+	in := strings.TrimSpace(s)
+	neg := false
+	switch {
+	case strings.HasPrefix(in, "-"):
+		neg = true
+		in = in[1:]
+	case strings.HasPrefix(in, "+"):
+		in = in[1:]
+	}
+
+	whole, frac, hasPoint := strings.Cut(in, ".")
+	whole = strings.ReplaceAll(whole, ",", "")
+	if whole == "" && (!hasPoint || frac == "") {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+	if !allDigits(whole) || !allDigits(frac) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+	if len(frac) > minorDigits {
+		if strings.Trim(frac[minorDigits:], "0") != "" {
+			return Money{}, fmt.Errorf("%w: %q has more than %d decimal places", ErrInvalidMoney, s, minorDigits)
+		}
+		frac = frac[:minorDigits]
+	}
+	frac += strings.Repeat("0", minorDigits-len(frac))
+
+	n, ok := new(big.Int).SetString("0"+whole+frac, 10)
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+	if neg {
+		n.Neg(n)
+	}
+	if !n.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: n.Int64(), Currency: currency}, nil
+}
+
+func allDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
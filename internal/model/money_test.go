@@ -0,0 +1,57 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		err  error
+	}{
+		{"19.99", 1999, nil},
+		{"-1,234.5", -123450, nil},
+		{".5", 50, nil},
+		{"19.999", 0, ErrInvalidMoney},
+		{"1e3", 0, ErrInvalidMoney},
+		{"", 0, ErrInvalidMoney},
+		{"92233720368547758.08", 0, ErrMoneyOverflow},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in, "USD")
+		if !errors.Is(err, tt.err) || got.Amount != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, %v; want %d, %v", tt.in, got.Amount, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	if _, err := NewMoney(100, "USD").Add(NewMoney(100, "EUR")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("adding USD to EUR: %v, want ErrCurrencyMismatch", err)
+	}
+	if got, err := NewMoney(5, "USD").Div(2); err != nil || got.Amount != 2 {
+		t.Errorf("5 / 2 = %d, %v; want 2 (half to even)", got.Amount, err)
+	}
+	if got, _ := NewMoney(-5, "USD").Add(Money{}); got.String() != "-0.05" {
+		t.Errorf("String() = %q, want -0.05", got.String())
+	}
+}
+
+func TestAllocateAddsBackUp(t *testing.T) {
+	parts, err := NewMoney(100, "USD").Allocate(1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum int64
+	for _, p := range parts {
+		sum += p.Amount
+		if p.Amount < 33 || p.Amount > 34 {
+			t.Errorf("share %d out of range", p.Amount)
+		}
+	}
+	if sum != 100 {
+		t.Errorf("shares add up to %d, want 100", sum)
+	}
+}
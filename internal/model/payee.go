@@ -0,0 +1,21 @@
+package model
+
+// Payee is a merchant or person money goes to or comes from. Bank
+// descriptions are matched to a payee by its name or one of its aliases,
+// so "AMZN Mktp US*2K3" and "Amazon" can count as the same payee.
+type Payee struct {
+	ID                int64
+	Name              string
+	Aliases           []string // Other descriptions that mean this payee
+	DefaultCategoryID *int64   // Suggested for new transactions
+	DefaultAccountID  *int64   // Suggested paying account
+	Transactions      int      // Linked transactions (read-only)
+}
+
+// PayeeSpending is the expense total for one payee, in the base currency.
+type PayeeSpending struct {
+	PayeeID int64 // 0 for transactions without a payee
+	Name    string
+	Amount  Money
+	Count   int // Number of transactions
+}
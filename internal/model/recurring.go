@@ -1,21 +1,120 @@
 package model
 
-// ... existing models ...
+import "time"
 
-// RecurringRule defines a detected or manual recurring transaction
+// RecurrenceFrequency is how often a recurring rule repeats.
+type RecurrenceFrequency string
+
+const (
+	FrequencyDaily      RecurrenceFrequency = "Daily"
+	FrequencyWeekly     RecurrenceFrequency = "Weekly"
+	FrequencyBiweekly   RecurrenceFrequency = "Biweekly"
+	FrequencyMonthly    RecurrenceFrequency = "Monthly"
+	FrequencyMonthEnd   RecurrenceFrequency = "Monthly (last day)"
+	FrequencyQuarterly  RecurrenceFrequency = "Quarterly"
+	FrequencyYearly     RecurrenceFrequency = "Yearly"
+	FrequencyEveryNDays RecurrenceFrequency = "Every N Days"
+)
+
+// RecurrenceFrequencies lists every frequency in picker order.
+var RecurrenceFrequencies = []RecurrenceFrequency{
+	FrequencyDaily, FrequencyWeekly, FrequencyBiweekly, FrequencyMonthly,
+	FrequencyMonthEnd, FrequencyQuarterly, FrequencyYearly, FrequencyEveryNDays,
+}
+
+// RecurringRule is a stored schedule that posts a copy of its split
+// template on every occurrence.
 type RecurringRule struct {
-	ID        int64
-	Pattern   string // Payee name pattern
-	Amount    int64  // Expected amount (cents)
-	Interval  string // "Monthly", "Weekly"
-	LastDate  string // YYYY-MM-DD
-	Confirmed bool   // User confirmed this rule?
+	ID           int64
+	Name         string // Description of each posted transaction
+	Note         string
+	Frequency    RecurrenceFrequency
+	IntervalDays int // Only for FrequencyEveryNDays
+	StartDate    time.Time
+	EndDate      *time.Time // No occurrences after this date; nil runs forever
+	NextIndex    int        // Occurrences already posted or skipped
+	AutoPost     bool       // Post due occurrences without asking
+	Splits       []Split    // Template; must balance like a transaction
+}
+
+// OccurrenceDate returns the scheduled date of the n-th occurrence
+// (0 is the start date). Monthly and yearly dates are counted from the
+// start date, so a rule starting on the 31st lands on the last day of
+// shorter months and returns to the 31st afterwards.
+func (r RecurringRule) OccurrenceDate(n int) time.Time {
+	y, m, d := r.StartDate.Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	switch r.Frequency {
+	case FrequencyDaily:
+		return start.AddDate(0, 0, n)
+	case FrequencyWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case FrequencyBiweekly:
+		return start.AddDate(0, 0, 14*n)
+	case FrequencyEveryNDays:
+		return start.AddDate(0, 0, r.IntervalDays*n)
+	case FrequencyMonthEnd:
+		return time.Date(y, m+time.Month(n)+1, 0, 0, 0, 0, 0, time.UTC)
+	case FrequencyQuarterly:
+		return addMonthsClamped(y, m, d, 3*n)
+	case FrequencyYearly:
+		return addMonthsClamped(y, m, d, 12*n)
+	default: // Monthly
+		return addMonthsClamped(y, m, d, n)
+	}
+}
+
+// HasOccurrence reports whether the n-th occurrence falls within the series.
+func (r RecurringRule) HasOccurrence(n int) bool {
+	return r.EndDate == nil || !r.OccurrenceDate(n).After(*r.EndDate)
 }
 
-// Subscription is a high-level view of a recurring expense
+// addMonthsClamped moves months forward from y-m-d, using the last day of
+// the target month when it is shorter.
+func addMonthsClamped(y int, m time.Month, d, months int) time.Time {
+	last := time.Date(y, m+time.Month(months)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if d > last {
+		d = last
+	}
+	return time.Date(y, m+time.Month(months), d, 0, 0, 0, 0, time.UTC)
+}
+
+// RecurringOverride changes a single occurrence of a rule: skip it, move it
+// to another date, replace its amount or give it its own note.
+type RecurringOverride struct {
+	RuleID int64
+	Index  int // Which occurrence, counted from the start date
+	Skip   bool
+	Date   *time.Time // Post on this date instead of the scheduled one
+	Amount *int64     // Replacement total in minor units, spread over the template
+	Note   string
+}
+
+// RecurringOccurrence is one scheduled instance of a rule, with any
+// override applied.
+type RecurringOccurrence struct {
+	RuleID      int64
+	RuleName    string
+	Index       int
+	Scheduled   time.Time
+	Skip        bool
+	Overridden  bool
+	Transaction Transaction // What will be posted
+}
+
+// Subscription is a recurring expense detected in the transaction history.
 type Subscription struct {
-	Name        string
-	Amount      float64
-	Frequency   string
+	Name        string // Payee name, or the latest payment's description
+	Payee       string // Normalized payee the payments were grouped on
+	Amount      Money  // Latest payment
+	Frequency   RecurrenceFrequency
 	NextDueDate string
+	LastDate    time.Time
+	Occurrences int
+	Confidence  float64 // 0-1: how regular the dates and amounts are
+
+	// Where the latest payment came from and went, for scheduling it
+	AccountID        int64
+	ExpenseAccountID int64
+	CategoryID       *int64
 }
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func parseDay(s string) time.Time {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestOccurrenceDate(t *testing.T) {
+	tests := []struct {
+		frequency RecurrenceFrequency
+		start     string
+		n         int
+		want      string
+	}{
+		{FrequencyMonthly, "2025-01-31", 1, "2025-02-28"},
+		{FrequencyMonthly, "2025-01-31", 2, "2025-03-31"},
+		{FrequencyMonthly, "2024-01-31", 1, "2024-02-29"},
+		{FrequencyMonthEnd, "2025-01-15", 0, "2025-01-31"},
+		{FrequencyYearly, "2024-02-29", 1, "2025-02-28"},
+		{FrequencyWeekly, "2025-12-25", 2, "2026-01-08"},
+	}
+	for _, tt := range tests {
+		r := RecurringRule{Frequency: tt.frequency, StartDate: parseDay(tt.start)}
+		if got := r.OccurrenceDate(tt.n).Format("2006-01-02"); got != tt.want {
+			t.Errorf("%s from %s, occurrence %d = %s, want %s", tt.frequency, tt.start, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestHasOccurrence(t *testing.T) {
+	end := parseDay("2025-03-31")
+	r := RecurringRule{Frequency: FrequencyMonthly, StartDate: parseDay("2025-01-31"), EndDate: &end}
+	if !r.HasOccurrence(2) || r.HasOccurrence(3) {
+		t.Errorf("HasOccurrence(2), HasOccurrence(3) = %v, %v; want true, false", r.HasOccurrence(2), r.HasOccurrence(3))
+	}
+}
@@ -0,0 +1,348 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MatchStart and MatchEnd surround the matched terms in a SearchMatch.
+const (
+	MatchStart = "\x02"
+	MatchEnd   = "\x03"
+)
+
+// SearchMatch shows where a text search matched a transaction.
+type SearchMatch struct {
+	Description string  // The description with matched terms marked
+	Snippet     string  // Best matching excerpt from any searched field
+	Rank        float64 // bm25 score; lower is more relevant
+}
+
+// SearchField is what a search term filters on.
+type SearchField string
+
+const (
+	SearchText        SearchField = "text"    // Full text of description, note, payee, tags and categories
+	SearchDescription SearchField = "desc"    // Description contains
+	SearchNote        SearchField = "note"    // Note contains
+	SearchPayee       SearchField = "payee"   // Payee name or description contains
+	SearchCategory    SearchField = "cat"     // Category or one of its subcategories, by name
+	SearchAccount     SearchField = "account" // Any split in the account, by name
+	SearchTag         SearchField = "tag"     // On the transaction or one of its splits
+	SearchStatus      SearchField = "status"
+	SearchDate        SearchField = "date"
+	SearchAmount      SearchField = "amount" // Total of the incoming legs, in the base currency
+)
+
+// SearchOp compares a field with a term's value. Text-like fields and
+// status always use SearchIs; dates and amounts use the comparisons.
+type SearchOp string
+
+const (
+	SearchIs SearchOp = ":"
+	SearchEq SearchOp = "="
+	SearchLt SearchOp = "<"
+	SearchLe SearchOp = "<="
+	SearchGt SearchOp = ">"
+	SearchGe SearchOp = ">="
+)
+
+// SearchTerm is one condition of a search. Only the value matching Field
+// is set.
+type SearchTerm struct {
+	Field  SearchField
+	Op     SearchOp
+	Negate bool // Exclude matching transactions
+
+	Text   string // Text-like fields; for SearchText several words form a phrase
+	Prefix bool   // SearchText only: match words starting with the last word
+	Date   time.Time
+	Amount Money
+	Status TransactionStatus
+}
+
+// SearchQuery is a parsed search: a transaction must satisfy every term.
+// An empty query matches everything.
+type SearchQuery struct {
+	Terms []SearchTerm
+}
+
+// SearchSyntaxError reports a malformed search query.
+type SearchSyntaxError struct {
+	Column int // 1-based, in characters
+	Msg    string
+}
+
+func (e *SearchSyntaxError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Msg)
+}
+
+// searchFieldNames maps filter names, including short forms, to fields.
+// after: and before: are date filters with a fixed comparison.
+var searchFieldNames = map[string]SearchField{
+	"desc":        SearchDescription,
+	"description": SearchDescription,
+	"note":        SearchNote,
+	"payee":       SearchPayee,
+	"cat":         SearchCategory,
+	"category":    SearchCategory,
+	"account":     SearchAccount,
+	"acct":        SearchAccount,
+	"tag":         SearchTag,
+	"status":      SearchStatus,
+	"date":        SearchDate,
+	"after":       SearchDate,
+	"before":      SearchDate,
+	"amount":      SearchAmount,
+	"amt":         SearchAmount,
+}
+
+// searchFilters lists the filters for error messages, without short forms.
+const searchFilters = "cat: account: payee: desc: note: tag: status: date: after: before: amount:"
+
+// ParseSearchQuery parses a search such as
+//
+//	coffee cat:Food amount>50 account:"Chase Visa" after:2025-01-01 -note:refund
+//
+// Bare words and "quoted phrases" are full-text terms, and a trailing *
+// matches by prefix. field:value filters narrow the results; dates and
+// amounts also take =, <, <=, > and >=. after:D means on or after D and
+// before:D means before D. A leading - excludes what the term matches.
+// Amounts are read in currency, normally the base currency.
+func ParseSearchQuery(text, currency string) (*SearchQuery, error) {
+	p := &searchParser{text: text, currency: currency}
+	q := &SearchQuery{}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.text) {
+			return q, nil
+		}
+		term, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		q.Terms = append(q.Terms, term)
+	}
+}
+
+type searchParser struct {
+	text     string
+	pos      int // Byte offset
+	currency string
+}
+
+func (p *searchParser) errorf(at int, format string, args ...interface{}) error {
+	return &SearchSyntaxError{Column: utf8.RuneCountInString(p.text[:at]) + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *searchParser) skipSpace() {
+	for p.pos < len(p.text) {
+		r, size := utf8.DecodeRuneInString(p.text[p.pos:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		p.pos += size
+	}
+}
+
+// word reads up to the next space or quote.
+func (p *searchParser) word() string {
+	start := p.pos
+	for p.pos < len(p.text) {
+		r, size := utf8.DecodeRuneInString(p.text[p.pos:])
+		if unicode.IsSpace(r) || r == '"' {
+			break
+		}
+		p.pos += size
+	}
+	return p.text[start:p.pos]
+}
+
+// quoted reads a "quoted" string starting at the opening quote.
+func (p *searchParser) quoted() (string, error) {
+	start := p.pos
+	end := strings.IndexByte(p.text[start+1:], '"')
+	if end < 0 {
+		return "", p.errorf(start, "unclosed quote")
+	}
+	p.pos = start + 1 + end + 1
+	return p.text[start+1 : start+1+end], nil
+}
+
+// operator reads a filter operator, longest first, or returns "".
+func (p *searchParser) operator() SearchOp {
+	for _, op := range []SearchOp{SearchLe, SearchGe, SearchIs, SearchEq, SearchLt, SearchGt} {
+		if strings.HasPrefix(p.text[p.pos:], string(op)) {
+			p.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *searchParser) term() (SearchTerm, error) {
+	start := p.pos
+	var term SearchTerm
+	if p.text[p.pos] == '-' {
+		term.Negate = true
+		p.pos++
+		if p.pos >= len(p.text) || p.text[p.pos] == ' ' || p.text[p.pos] == '\t' {
+			return term, p.errorf(start, `"-" must be followed by a word or filter to exclude`)
+		}
+	}
+
+	if p.text[p.pos] == '"' {
+		phrase, err := p.quoted()
+		if err != nil {
+			return term, err
+		}
+		return p.textTerm(term, phrase, start)
+	}
+
+	// A filter starts with a name made of letters and an operator
+	nameStart := p.pos
+	for p.pos < len(p.text) && isASCIILetter(p.text[p.pos]) {
+		p.pos++
+	}
+	name := strings.ToLower(p.text[nameStart:p.pos])
+	if op := p.operator(); name != "" && op != "" {
+		field, ok := searchFieldNames[name]
+		if !ok {
+			return term, p.errorf(nameStart, "unknown filter %q; use one of %s, or put the text in quotes to search for it",
+				name+string(op), searchFilters)
+		}
+		valueStart := p.pos
+		var value string
+		if p.pos < len(p.text) && p.text[p.pos] == '"' {
+			var err error
+			if value, err = p.quoted(); err != nil {
+				return term, err
+			}
+		} else {
+			value = p.word()
+		}
+		if strings.TrimSpace(value) == "" {
+			return term, p.errorf(valueStart, "%s needs a value", name+string(op))
+		}
+		return p.filterTerm(term, name, field, op, strings.TrimSpace(value), valueStart)
+	}
+
+	p.pos = nameStart
+	return p.textTerm(term, p.word(), start)
+}
+
+func isASCIILetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func (p *searchParser) textTerm(term SearchTerm, text string, start int) (SearchTerm, error) {
+	if p.pos < len(p.text) && p.text[p.pos] == '*' {
+		text += "*"
+		p.pos++
+	}
+	if strings.HasSuffix(text, "*") {
+		text = strings.TrimRight(text, "*")
+		term.Prefix = true
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if strings.IndexFunc(text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+		return term, p.errorf(start, "%q has no letters or digits to search for", p.text[start:p.pos])
+	}
+	term.Field, term.Op, term.Text = SearchText, SearchIs, text
+	return term, nil
+}
+
+func (p *searchParser) filterTerm(term SearchTerm, name string, field SearchField, op SearchOp, value string, valueStart int) (SearchTerm, error) {
+	term.Field, term.Op = field, op
+	switch field {
+	case SearchDate:
+		if name != "date" {
+			if op != SearchIs {
+				return term, p.errorf(valueStart-len(op), "%s only takes \":\", e.g. %s:2025-01-01", name, name)
+			}
+			term.Op = SearchGe
+			if name == "before" {
+				term.Op = SearchLt
+			}
+		} else if op == SearchIs {
+			term.Op = SearchEq
+		}
+		d, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return term, p.errorf(valueStart, "invalid date %q; use YYYY-MM-DD", value)
+		}
+		term.Date = d
+
+	case SearchAmount:
+		if op == SearchIs {
+			term.Op = SearchEq
+		}
+		amount, err := ParseMoney(value, p.currency)
+		if err != nil {
+			return term, p.errorf(valueStart, "%v", err)
+		}
+		if amount.IsNegative() {
+			return term, p.errorf(valueStart, "amounts are compared without sign; use %s", strings.TrimPrefix(value, "-"))
+		}
+		term.Amount = amount
+
+	default:
+		if op != SearchIs && op != SearchEq {
+			return term, p.errorf(valueStart-len(op), "%s only takes \":\"", name)
+		}
+		term.Op = SearchIs
+		if field == SearchStatus {
+			for _, s := range []TransactionStatus{TransactionStatusPending, TransactionStatusCleared, TransactionStatusReconciled} {
+				if strings.EqualFold(value, string(s)) {
+					term.Status = s
+					return term, nil
+				}
+			}
+			return term, p.errorf(valueStart, "unknown status %q; use pending, cleared or reconciled", value)
+		}
+		term.Text = value
+	}
+	return term, nil
+}
+
+// String writes the query back in the search syntax, so it can be saved
+// and parsed again.
+func (q *SearchQuery) String() string {
+	parts := make([]string, len(q.Terms))
+	for i, t := range q.Terms {
+		parts[i] = t.String()
+	}
+	return strings.Join(parts, " ")
+}
+
+func (t SearchTerm) String() string {
+	s := ""
+	if t.Negate {
+		s = "-"
+	}
+	switch t.Field {
+	case SearchText:
+		s += quoteSearchValue(t.Text)
+		if t.Prefix {
+			s += "*"
+		}
+		return s
+	case SearchDate:
+		return s + "date" + string(t.Op) + t.Date.Format("2006-01-02")
+	case SearchAmount:
+		return s + "amount" + string(t.Op) + t.Amount.String()
+	case SearchStatus:
+		return s + "status:" + strings.ToLower(string(t.Status))
+	}
+	return s + string(t.Field) + ":" + quoteSearchValue(t.Text)
+}
+
+func quoteSearchValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " \t\":<>=*") || strings.HasPrefix(v, "-") {
+		return `"` + strings.ReplaceAll(v, `"`, "") + `"`
+	}
+	return v
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []SearchTerm
+	}{
+		{"", nil},
+		{"star*", []SearchTerm{{Field: SearchText, Op: SearchIs, Text: "star", Prefix: true}}},
+		{`account:"Chase Visa"`, []SearchTerm{{Field: SearchAccount, Op: SearchIs, Text: "Chase Visa"}}},
+		{"-note:refund", []SearchTerm{{Field: SearchNote, Op: SearchIs, Text: "refund", Negate: true}}},
+		{"amount>50", []SearchTerm{{Field: SearchAmount, Op: SearchGt, Amount: NewMoney(5000, "USD")}}},
+		{"before:2025-01-01", []SearchTerm{{Field: SearchDate, Op: SearchLt, Date: parseDay("2025-01-01")}}},
+	}
+	for _, tt := range tests {
+		q, err := ParseSearchQuery(tt.in, "USD")
+		if err != nil {
+			t.Errorf("ParseSearchQuery(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(q.Terms, tt.want) {
+			t.Errorf("ParseSearchQuery(%q) = %+v, want %+v", tt.in, q.Terms, tt.want)
+		}
+	}
+}
+
+func TestParseSearchQueryErrors(t *testing.T) {
+	tests := []struct {
+		in     string
+		column int
+		msg    string
+	}{
+		{`coffee note:"open`, 13, "unclosed quote"},
+		{"cafe wat>1", 6, `unknown filter "wat>"`},
+		{"date:2025-13-01", 6, "invalid date"},
+		{"amount:1.234", 8, "more than 2 decimal places"},
+	}
+	for _, tt := range tests {
+		_, err := ParseSearchQuery(tt.in, "USD")
+		var syntax *SearchSyntaxError
+		if !errors.As(err, &syntax) || syntax.Column != tt.column || !strings.Contains(syntax.Msg, tt.msg) {
+			t.Errorf("ParseSearchQuery(%q) error = %v, want column %d containing %q", tt.in, err, tt.column, tt.msg)
+		}
+	}
+}
+
+func TestSearchQueryStringRoundTrip(t *testing.T) {
+	in := `coffee cat:Food amount>50 account:"Chase Visa" after:2025-01-01 -note:refund`
+	q, err := ParseSearchQuery(in, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ParseSearchQuery(q.String(), "USD")
+	if err != nil || !reflect.DeepEqual(again.Terms, q.Terms) {
+		t.Errorf("%q reparsed as %+v, %v; want %+v", q.String(), again.Terms, err, q.Terms)
+	}
+}
@@ -4,6 +4,6 @@ package model
 
 type MonthlyStat struct {
 	Month  string // "Jan", "Feb"
-	Income float64
-	Expense float64
+	Income Money
+	Expense Money
 }
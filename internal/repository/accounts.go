@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+// Account lifecycle errors. The UI matches on these to show friendly messages.
+var (
+	ErrAccountNotFound     = errors.New("account not found")
+	ErrAccountHasBalance   = errors.New("account balance must be zero before it can be closed")
+	ErrAccountNameTaken    = errors.New("an account with this name already exists")
+	ErrAccountHasHistory   = errors.New("account has transactions; choose an account to move them to")
+	ErrInvalidReassignment = errors.New("transactions must be moved to a different, open account")
+	ErrReassignCurrency    = errors.New("transactions can only be moved to an account in the same currency")
+)
+
+// GetAccountByID returns a single account, or nil if it does not exist.
+func (r *Repository) GetAccountByID(id int64) (*model.Account, error) {
+	query := `SELECT id, name, type, currency, is_closed FROM accounts WHERE id = ?`
+	var a model.Account
+	err := r.DB.QueryRow(query, id).Scan(&a.ID, &a.Name, &a.Type, &a.Currency, &a.IsClosed)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetOpenAccounts returns accounts that have not been closed.
+func (r *Repository) GetOpenAccounts() ([]model.Account, error) {
+	accounts, err := r.GetAllAccounts()
+	if err != nil {
+		return nil, err
+	}
+	var open []model.Account
+	for _, a := range accounts {
+		if !a.IsClosed {
+			open = append(open, a)
+		}
+	}
+	return open, nil
+}
+
+// accountBalanceCents returns the exact ledger balance of an account in minor units.
+func (r *Repository) accountBalanceCents(accountID int64) (int64, error) {
+	var balance sql.NullInt64
+	err := r.DB.QueryRow("SELECT SUM(amount) FROM splits WHERE account_id = ?", accountID).Scan(&balance)
+	if err != nil {
+		return 0, err
+	}
+	return balance.Int64, nil
+}
+
+// CloseAccount marks an account as closed. Closing is refused while the
+// account still holds money; its history stays in place for reports.
+func (r *Repository) CloseAccount(accountID int64) error {
+	acc, err := r.GetAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	if acc == nil {
+		return ErrAccountNotFound
+	}
+
+	balance, err := r.accountBalanceCents(accountID)
+	if err != nil {
+		return err
+	}
+	if balance != 0 {
+		return ErrAccountHasBalance
+	}
+
+	_, err = r.DB.Exec("UPDATE accounts SET is_closed = 1 WHERE id = ?", accountID)
+	return err
+}
+
+// ReopenAccount makes a closed account available for new transactions again.
+func (r *Repository) ReopenAccount(accountID int64) error {
+	res, err := r.DB.Exec("UPDATE accounts SET is_closed = 0 WHERE id = ?", accountID)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(res, ErrAccountNotFound)
+}
+
+// RenameAccount changes an account's display name. Names must stay unique.
+func (r *Repository) RenameAccount(accountID int64, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("account name is required")
+	}
+
+	existing, err := r.GetAccountByName(name)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != accountID {
+		return ErrAccountNameTaken
+	}
+
+	res, err := r.DB.Exec("UPDATE accounts SET name = ? WHERE id = ?", name, accountID)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(res, ErrAccountNotFound)
+}
+
+// ChangeAccountCurrency sets the currency of an account. Because stored
+// amounts are in the account's currency, this is only allowed while the
+// account has no transactions; otherwise open a new account and transfer.
+func (r *Repository) ChangeAccountCurrency(accountID int64, currency string) error {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return errors.New("currency is required")
+	}
+
+	count, err := r.countAccountSplits(accountID)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("cannot change currency of an account with %d transaction lines", count)
+	}
+
+	res, err := r.DB.Exec("UPDATE accounts SET currency = ? WHERE id = ?", currency, accountID)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(res, ErrAccountNotFound)
+}
+
+// countAccountSplits counts the account's transaction splits plus the
+// recurring templates that would post to it.
+func (r *Repository) countAccountSplits(accountID int64) (int, error) {
+	var count int
+	err := r.DB.QueryRow(`SELECT (SELECT COUNT(*) FROM splits WHERE account_id = ?)
+		+ (SELECT COUNT(*) FROM recurring_splits WHERE account_id = ?)`, accountID, accountID).Scan(&count)
+	return count, err
+}
+
+// DeleteAccount removes an account. If it has transactions, reassignTo must
+// name another open account in the same currency; all of its splits are
+// moved there first so every transaction stays balanced. Pass 0 when the
+// account is unused.
+func (r *Repository) DeleteAccount(accountID, reassignTo int64) error {
+	count, err := r.countAccountSplits(accountID)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		if reassignTo == 0 {
+			return ErrAccountHasHistory
+		}
+		if reassignTo == accountID {
+			return ErrInvalidReassignment
+		}
+		target, err := r.GetAccountByID(reassignTo)
+		if err != nil {
+			return err
+		}
+		if target == nil || target.IsClosed {
+			return ErrInvalidReassignment
+		}
+		acc, err := r.GetAccountByID(accountID)
+		if err != nil {
+			return err
+		}
+		if acc == nil {
+			return ErrAccountNotFound
+		}
+		// Split amounts are in the account's currency and would be misread in another
+		if target.Currency != acc.Currency {
+			return ErrReassignCurrency
+		}
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if count > 0 {
+		if _, err := tx.Exec("UPDATE splits SET account_id = ? WHERE account_id = ?", reassignTo, accountID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE recurring_splits SET account_id = ? WHERE account_id = ?", reassignTo, accountID); err != nil {
+			return err
+		}
+		// Statement history follows the transactions it covers
+		if _, err := tx.Exec("UPDATE reconciliations SET account_id = ? WHERE account_id = ?", reassignTo, accountID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE rules SET account_id = ? WHERE account_id = ?", reassignTo, accountID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE payees SET default_account_id = ? WHERE default_account_id = ?", reassignTo, accountID); err != nil {
+			return err
+		}
+	}
+	// Rules for an account that never had transactions can never match
+	if _, err := tx.Exec("DELETE FROM rules WHERE account_id = ?", accountID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM reconciliations WHERE account_id = ?", accountID); err != nil {
+		return err
+	}
+
+	res, err := tx.Exec("DELETE FROM accounts WHERE id = ?", accountID)
+	if err != nil {
+		return err
+	}
+	if err := requireRowAffected(res, ErrAccountNotFound); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// requireRowAffected turns an UPDATE/DELETE that touched nothing into notFound.
+func requireRowAffected(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
@@ -0,0 +1,467 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+// ErrInvalidAnomalySettings is returned for thresholds that cannot work.
+var ErrInvalidAnomalySettings = errors.New("anomaly thresholds must be positive and ordered Low <= Medium <= High")
+
+const anomalySettingsKey = "anomaly_settings"
+
+// GetAnomalySettings returns the detection thresholds, with defaults for
+// anything not saved.
+func (r *Repository) GetAnomalySettings() (model.AnomalySettings, error) {
+	settings := model.DefaultAnomalySettings()
+	raw, err := r.getSetting(anomalySettingsKey, "")
+	if err != nil || raw == "" {
+		return settings, err
+	}
+	if err := json.Unmarshal([]byte(raw), &settings); err != nil {
+		return model.DefaultAnomalySettings(), nil
+	}
+	return settings, nil
+}
+
+// SetAnomalySettings saves the detection thresholds.
+func (r *Repository) SetAnomalySettings(s model.AnomalySettings) error {
+	if s.LookbackDays <= 0 || s.BaselineMonths <= 0 || s.MinHistory <= 0 || s.NewPayeeMin < 0 ||
+		s.LowScore <= 0 || s.LowScore > s.MediumScore || s.MediumScore > s.HighScore ||
+		s.BudgetPace <= 0 || s.PriceIncreaseMin < 0 {
+		return ErrInvalidAnomalySettings
+	}
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := setSetting(tx, anomalySettingsKey, string(raw)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DismissAnomaly hides an anomaly from future detection runs.
+func (r *Repository) DismissAnomaly(key string) error {
+	_, err := r.DB.Exec("INSERT OR IGNORE INTO anomaly_dismissals (anomaly_key) VALUES (?)", key)
+	return err
+}
+
+// RestoreDismissedAnomalies brings back every dismissed anomaly and returns
+// how many there were.
+func (r *Repository) RestoreDismissedAnomalies() (int64, error) {
+	res, err := r.DB.Exec("DELETE FROM anomaly_dismissals")
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *Repository) dismissedAnomalies() (map[string]bool, error) {
+	rows, err := r.DB.Query("SELECT anomaly_key FROM anomaly_dismissals")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dismissed := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		dismissed[key] = true
+	}
+	return dismissed, rows.Err()
+}
+
+// DetectAnomalies checks recent expenses against their own history. Each
+// payment is compared with earlier payments in the same category and to
+// the same payee using a robust z-score (distance from the median in units
+// of the median absolute deviation), so one earlier splurge does not hide
+// the next. It also flags first payments to a new payee and payments made
+// on an unusual day of the month for a payee that is normally regular,
+// budgets spending faster than their period is passing, and recurring
+// charges that have gone up. Dismissed anomalies are left out.
+func (r *Repository) DetectAnomalies() ([]model.Anomaly, error) {
+	return r.detectAnomalies(time.Now())
+}
+
+func (r *Repository) detectAnomalies(asOf time.Time) ([]model.Anomaly, error) {
+	settings, err := r.GetAnomalySettings()
+	if err != nil {
+		return nil, err
+	}
+	base, err := r.GetBaseCurrency()
+	if err != nil {
+		return nil, err
+	}
+	dismissed, err := r.dismissedAnomalies()
+	if err != nil {
+		return nil, err
+	}
+	categories, err := r.GetAllCategories()
+	if err != nil {
+		return nil, err
+	}
+	categoryNames := make(map[int64]string, len(categories))
+	for _, c := range categories {
+		categoryNames[c.ID] = c.Name
+	}
+
+	windowStart := asOf.AddDate(0, 0, -settings.LookbackDays)
+	payments, err := r.loadPayments(windowStart.AddDate(0, -settings.BaselineMonths, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	var anomalies []model.Anomaly
+	add := func(a model.Anomaly) {
+		if !dismissed[a.Key] {
+			anomalies = append(anomalies, a)
+		}
+	}
+
+	// A price rise explains a higher amount, so it replaces the amount check
+	priceRises := make(map[int64]bool)
+	if settings.FlagPriceIncreases {
+		rises, err := r.priceIncreaseAnomalies(windowStart, asOf, settings)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range rises {
+			priceRises[a.TransactionID] = true
+			add(a)
+		}
+	}
+	if settings.FlagBudgetDrift {
+		drifts, err := r.budgetDriftAnomalies(asOf, settings)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range drifts {
+			add(a)
+		}
+	}
+
+	for i, p := range payments {
+		if p.date.Before(windowStart) || p.date.After(asOf) {
+			continue
+		}
+
+		// Earlier payments within the baseline window
+		from := p.date.AddDate(0, -settings.BaselineMonths, 0)
+		var history, byCategory, byPayee []float64
+		var payeeDays []float64
+		for _, h := range payments[:i] {
+			if h.date.Before(from) || !h.date.Before(p.date) {
+				continue
+			}
+			history = append(history, h.base)
+			if categoryKey(h.categoryID) == categoryKey(p.categoryID) {
+				byCategory = append(byCategory, h.base)
+			}
+			if h.payee == p.payee {
+				byPayee = append(byPayee, h.base)
+				payeeDays = append(payeeDays, float64(h.date.Day()))
+			}
+		}
+		amount := model.NewMoney(p.amount, p.currency).Format()
+		date := p.date.Format("2006-01-02")
+
+		// Amount against the category and payee baselines; the stronger wins
+		var best model.Anomaly
+		if len(byCategory) >= settings.MinHistory {
+			z, typical := robustZ(p.base, byCategory)
+			name := categoryNames[categoryKey(p.categoryID)]
+			if name == "" {
+				name = "uncategorized spending"
+			}
+			best = model.Anomaly{
+				Type:  model.AnomalyCategoryAmount,
+				Score: z,
+				Description: fmt.Sprintf("%s: %s, usually about %s for %s", p.description, amount,
+					model.NewMoney(int64(math.Round(typical)), base).Format(), name),
+			}
+		}
+		if len(byPayee) >= settings.MinHistory {
+			if z, typical := robustZ(p.base, byPayee); z > best.Score {
+				best = model.Anomaly{
+					Type:  model.AnomalyPayeeAmount,
+					Score: z,
+					Description: fmt.Sprintf("%s: %s, usually about %s at this payee", p.description, amount,
+						model.NewMoney(int64(math.Round(typical)), base).Format()),
+				}
+			}
+		}
+		if severity, ok := anomalySeverity(best.Score, settings); ok && !priceRises[p.txID] {
+			best.Key = fmt.Sprintf("amount:%d", p.txID)
+			best.Severity = severity
+			best.Date = date
+			best.TransactionID = p.txID
+			add(best)
+		}
+
+		// A first payment only stands out once there is history to compare with
+		if settings.FlagNewPayees && len(byPayee) == 0 && len(history) >= settings.MinHistory &&
+			p.base >= float64(settings.NewPayeeMin) && !paidBefore(payments[:i], p.payee) {
+			add(model.Anomaly{
+				Key:           fmt.Sprintf("newpayee:%d", p.txID),
+				Type:          model.AnomalyNewPayee,
+				Description:   fmt.Sprintf("First payment to %s: %s", p.description, amount),
+				Severity:      model.SeverityLow,
+				Date:          date,
+				TransactionID: p.txID,
+			})
+		}
+
+		// Only payees that always land within a couple of days have a usual day
+		if settings.FlagUnusualDay && len(payeeDays) >= settings.MinHistory {
+			usual := median(payeeDays)
+			regular := true
+			for _, d := range payeeDays {
+				if math.Abs(d-usual) > 2 {
+					regular = false
+					break
+				}
+			}
+			if regular && math.Abs(float64(p.date.Day())-usual) > 5 {
+				add(model.Anomaly{
+					Key:  fmt.Sprintf("day:%d", p.txID),
+					Type: model.AnomalyUnusualDay,
+					Description: fmt.Sprintf("%s: paid on the %s, usually around the %s", p.description,
+						ordinal(p.date.Day()), ordinal(int(usual))),
+					Severity:      model.SeverityLow,
+					Date:          date,
+					TransactionID: p.txID,
+				})
+			}
+		}
+	}
+
+	sortAnomalies(anomalies)
+	return anomalies, nil
+}
+
+// budgetDriftAnomalies warns about budgets whose spending is running ahead
+// of the share of the period that has passed, and about budgets already
+// overspent. Pace warnings wait until a quarter of the budget is spent, so
+// one early purchase does not set them off.
+func (r *Repository) budgetDriftAnomalies(asOf time.Time, settings model.AnomalySettings) ([]model.Anomaly, error) {
+	progress, err := r.GetBudgetsWithProgress(asOf)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := r.loadCategoryTree()
+	if err != nil {
+		return nil, err
+	}
+
+	y, m, d := asOf.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	var anomalies []model.Anomaly
+	for _, p := range progress {
+		if p.Available.Amount <= 0 || p.Spent.Amount <= 0 {
+			continue
+		}
+		days := p.PeriodEnd.Sub(p.PeriodStart).Hours()/24 + 1
+		elapsed := math.Min(1, (today.Sub(p.PeriodStart).Hours()/24+1)/days)
+		pace := p.Percent / elapsed
+
+		var severity model.AnomalySeverity
+		var desc string
+		switch {
+		case p.Percent >= 1:
+			severity = model.SeverityHigh
+			desc = fmt.Sprintf("%s is over budget: spent %s of %s", p.CategoryName, p.Spent.Format(), p.Available.Format())
+		case p.Percent >= 0.25 && pace >= settings.BudgetPace:
+			severity = model.SeverityLow
+			if pace >= 1.5 {
+				severity = model.SeverityMedium
+			}
+			projected, err := p.Spent.Div(int64(math.Round(elapsed * 1000)))
+			if err == nil {
+				projected, err = projected.Mul(1000)
+			}
+			if err != nil {
+				return nil, err
+			}
+			desc = fmt.Sprintf("%s: spent %s of %s (%.0f%%) with %.0f%% of the %s gone; on pace for %s",
+				p.CategoryName, p.Spent.Format(), p.Available.Format(), p.Percent*100, elapsed*100,
+				periodNoun(p.Period), projected.Format())
+		default:
+			continue
+		}
+
+		related, err := r.transactionsInWindow(tree.subtree(p.CategoryID), p.PeriodStart, p.PeriodEnd.AddDate(0, 0, 1))
+		if err != nil {
+			return nil, err
+		}
+		anomalies = append(anomalies, model.Anomaly{
+			Key:         fmt.Sprintf("budget:%d:%s:%s", p.BudgetID, p.PeriodStart.Format(dateLayout), severity),
+			Type:        model.AnomalyBudgetDrift,
+			Description: desc,
+			Severity:    severity,
+			Date:        today.Format("2006-01-02"),
+			Related:     related,
+		})
+	}
+	return anomalies, nil
+}
+
+func periodNoun(p model.BudgetPeriod) string {
+	switch p {
+	case model.BudgetPeriodWeekly:
+		return "week"
+	case model.BudgetPeriodQuarterly:
+		return "quarter"
+	case model.BudgetPeriodYearly:
+		return "year"
+	case model.BudgetPeriodCustom:
+		return "period"
+	}
+	return "month"
+}
+
+// priceIncreaseAnomalies finds recurring charges whose latest payment,
+// made within the lookback window and on schedule, costs more than the one
+// before. A jump of more than double is more likely a one-off purchase
+// from the same shop and is left to the amount checks.
+func (r *Repository) priceIncreaseAnomalies(windowStart, asOf time.Time, settings model.AnomalySettings) ([]model.Anomaly, error) {
+	groups, err := r.paymentsByPayee(asOf.AddDate(0, -subscriptionLookbackMonths, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	var anomalies []model.Anomaly
+	for _, payments := range groups {
+		n := len(payments)
+		if n < 3 {
+			continue
+		}
+		latest := payments[n-1]
+		if latest.date.Before(windowStart) || latest.date.After(asOf) {
+			continue
+		}
+		sub, kept, ok := detectSubscription(payments[:n-1], latest.date)
+		if !ok {
+			continue
+		}
+		prev := kept[len(kept)-1]
+		minDays, maxDays := subscriptionGap(sub.Frequency)
+		if gap := latest.date.Sub(prev.date).Hours() / 24; gap < minDays || gap > maxDays {
+			continue
+		}
+		rise := float64(latest.amount-prev.amount) / float64(prev.amount)
+		if rise < settings.PriceIncreaseMin || rise > 1 {
+			continue
+		}
+
+		severity := model.SeverityLow
+		if rise >= 0.25 {
+			severity = model.SeverityMedium
+		}
+		related := make([]int64, 0, len(kept)+1)
+		for i := len(kept) - 1; i >= 0; i-- {
+			related = append(related, kept[i].txID)
+		}
+		anomalies = append(anomalies, model.Anomaly{
+			Key:  fmt.Sprintf("price:%d", latest.txID),
+			Type: model.AnomalyPriceIncrease,
+			Description: fmt.Sprintf("%s: %s, up %.0f%% from %s (%s)", latest.description,
+				model.NewMoney(latest.amount, latest.currency).Format(), rise*100,
+				model.NewMoney(prev.amount, prev.currency).Format(), sub.Frequency),
+			Severity:      severity,
+			Date:          latest.date.Format("2006-01-02"),
+			TransactionID: latest.txID,
+			Related:       append([]int64{latest.txID}, related...),
+		})
+	}
+	return anomalies, nil
+}
+
+// sortAnomalies orders anomalies by severity, then newest first.
+func sortAnomalies(anomalies []model.Anomaly) {
+	rank := map[model.AnomalySeverity]int{model.SeverityHigh: 0, model.SeverityMedium: 1, model.SeverityLow: 2}
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		if rank[anomalies[i].Severity] != rank[anomalies[j].Severity] {
+			return rank[anomalies[i].Severity] < rank[anomalies[j].Severity]
+		}
+		return anomalies[i].Date > anomalies[j].Date
+	})
+}
+
+func categoryKey(id *int64) int64 {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+func paidBefore(payments []payment, payee string) bool {
+	for _, p := range payments {
+		if p.payee == payee {
+			return true
+		}
+	}
+	return false
+}
+
+// robustZ returns how far x is above the median of values in units of the
+// scaled median absolute deviation, along with the median. The spread is
+// floored at 5% of the median so near-identical history does not turn
+// every small change into an outlier.
+func robustZ(x float64, values []float64) (float64, float64) {
+	med := median(values)
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - med)
+	}
+	scale := 1.4826 * median(deviations)
+	if floor := 0.05 * med; scale < floor {
+		scale = floor
+	}
+	if scale < 1 {
+		scale = 1
+	}
+	return (x - med) / scale, med
+}
+
+func anomalySeverity(score float64, s model.AnomalySettings) (model.AnomalySeverity, bool) {
+	switch {
+	case score >= s.HighScore:
+		return model.SeverityHigh, true
+	case score >= s.MediumScore:
+		return model.SeverityMedium, true
+	case score >= s.LowScore:
+		return model.SeverityLow, true
+	}
+	return "", false
+}
+
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+// MaxAttachmentSize is the largest file that can be attached.
+const MaxAttachmentSize = 20 << 20
+
+var (
+	ErrAttachmentNotFound    = errors.New("attachment not found")
+	ErrAttachmentEmpty       = errors.New("attachment is empty")
+	ErrAttachmentTooLarge    = fmt.Errorf("attachments can be at most %d MB", MaxAttachmentSize>>20)
+	ErrAttachmentUnsupported = errors.New("only images and PDF files can be attached")
+)
+
+// attachmentType sniffs the file contents; the extension is not trusted.
+func attachmentType(data []byte) (string, error) {
+	mime := http.DetectContentType(data)
+	if i := strings.IndexByte(mime, ';'); i >= 0 {
+		mime = mime[:i]
+	}
+	if mime != "application/pdf" && !strings.HasPrefix(mime, "image/") {
+		return "", ErrAttachmentUnsupported
+	}
+	return mime, nil
+}
+
+// AddAttachment attaches a file to a transaction. Attaching the same
+// contents to the transaction again returns the existing attachment.
+func (r *Repository) AddAttachment(txID int64, name string, data []byte) (*model.Attachment, error) {
+	if len(data) == 0 {
+		return nil, ErrAttachmentEmpty
+	}
+	if len(data) > MaxAttachmentSize {
+		return nil, ErrAttachmentTooLarge
+	}
+	mime, err := attachmentType(data)
+	if err != nil {
+		return nil, err
+	}
+	name = filepath.Base(strings.TrimSpace(name))
+	if name == "." || name == string(filepath.Separator) {
+		name = "attachment"
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := transactionStatus(tx, txID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("INSERT OR IGNORE INTO attachment_files (hash, data, size) VALUES (?, ?, ?)",
+		hash, data, len(data)); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`
+		INSERT OR IGNORE INTO attachments (transaction_id, hash, name, mime_type, added_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, txID, hash, name, mime, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var a model.Attachment
+	err = tx.QueryRow(`
+		SELECT a.id, a.transaction_id, a.name, a.mime_type, a.hash, f.size, a.added_at
+		FROM attachments a JOIN attachment_files f ON f.hash = a.hash
+		WHERE a.transaction_id = ? AND a.hash = ?
+	`, txID, hash).Scan(&a.ID, &a.TransactionID, &a.Name, &a.MimeType, &a.Hash, &a.Size, &a.AddedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, tx.Commit()
+}
+
+// GetAttachments lists a transaction's attachments, oldest first.
+func (r *Repository) GetAttachments(txID int64) ([]model.Attachment, error) {
+	return queryAttachments(r.DB, "WHERE a.transaction_id = ?", txID)
+}
+
+func queryAttachments(q rowsQuerier, where string, args ...interface{}) ([]model.Attachment, error) {
+	rows, err := q.Query(`
+		SELECT a.id, a.transaction_id, a.name, a.mime_type, a.hash, f.size, a.added_at
+		FROM attachments a JOIN attachment_files f ON f.hash = a.hash
+		`+where+`
+		ORDER BY a.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []model.Attachment
+	for rows.Next() {
+		var a model.Attachment
+		if err := rows.Scan(&a.ID, &a.TransactionID, &a.Name, &a.MimeType, &a.Hash, &a.Size, &a.AddedAt); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+// GetAttachmentData returns the contents of an attachment.
+func (r *Repository) GetAttachmentData(id int64) ([]byte, error) {
+	var data []byte
+	err := r.DB.QueryRow(`
+		SELECT f.data FROM attachments a JOIN attachment_files f ON f.hash = a.hash
+		WHERE a.id = ?
+	`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrAttachmentNotFound
+	}
+	return data, err
+}
+
+// DeleteAttachment removes an attachment, and its file when no other
+// transaction uses it.
+func (r *Repository) DeleteAttachment(id int64) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM attachments WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if err := requireRowAffected(res, ErrAttachmentNotFound); err != nil {
+		return err
+	}
+	if err := deleteOrphanAttachmentFiles(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteOrphanAttachmentFiles drops stored files no attachment refers to.
+func deleteOrphanAttachmentFiles(tx *sql.Tx) error {
+	_, err := tx.Exec("DELETE FROM attachment_files WHERE hash NOT IN (SELECT hash FROM attachments)")
+	return err
+}
+
+// moveAttachments reattaches everything on one transaction to another,
+// skipping files the target already has.
+func moveAttachments(tx *sql.Tx, fromID, toID int64) error {
+	if _, err := tx.Exec(`
+		INSERT OR IGNORE INTO attachments (transaction_id, hash, name, mime_type, added_at)
+		SELECT ?, hash, name, mime_type, added_at FROM attachments WHERE transaction_id = ?
+		ORDER BY id
+	`, toID, fromID); err != nil {
+		return err
+	}
+	_, err := tx.Exec("DELETE FROM attachments WHERE transaction_id = ?", fromID)
+	return err
+}
+
+// attachmentFiles returns the contents of every stored file, by hash.
+func (r *Repository) attachmentFiles() (map[string][]byte, error) {
+	rows, err := r.DB.Query("SELECT hash, data FROM attachment_files")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := make(map[string][]byte)
+	for rows.Next() {
+		var hash string
+		var data []byte
+		if err := rows.Scan(&hash, &data); err != nil {
+			return nil, err
+		}
+		files[hash] = data
+	}
+	return files, rows.Err()
+}
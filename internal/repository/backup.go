@@ -3,9 +3,9 @@ package repository
 import (
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 
@@ -17,8 +17,15 @@ type BackupData struct {
 	Accounts     []model.Account     `json:"accounts"`
 	Categories   []model.Category    `json:"categories"`
 	Transactions []model.Transaction `json:"transactions"`
+	Payees       []model.Payee       `json:"payees"`
+	Attachments  []model.Attachment  `json:"attachments"`
+	// Attachment contents by hash, base64 encoded in the JSON
+	AttachmentFiles map[string][]byte `json:"attachment_files"`
 }
 
+// exportPageSize is how many transactions ExportDataToJSON loads at once.
+const exportPageSize = 500
+
 // ExportDataToJSON dumps the DB to a JSON file with full transaction data
 func (r *Repository) ExportDataToJSON(filepath string) error {
 	// 1. Fetch Accounts
@@ -33,16 +40,42 @@ func (r *Repository) ExportDataToJSON(filepath string) error {
 		return err
 	}
 
-	// 3. Fetch All Transactions with splits
-	transactions, err := r.GetRecentTransactions(100000) // Large limit to get all
+	// 3. Fetch All Transactions with splits, a page at a time
+	var transactions []model.Transaction
+	var cursor *TransactionCursor
+	for {
+		page, next, err := r.SearchTransactions(&model.SearchQuery{}, cursor, exportPageSize)
+		if err != nil {
+			return err
+		}
+		transactions = append(transactions, page...)
+		if next == nil {
+			break
+		}
+		cursor = next
+	}
+
+	payees, err := r.GetPayees()
+	if err != nil {
+		return err
+	}
+
+	attachments, err := queryAttachments(r.DB, "")
+	if err != nil {
+		return err
+	}
+	files, err := r.attachmentFiles()
 	if err != nil {
 		return err
 	}
 
 	data := BackupData{
-		Accounts:     accounts,
-		Categories:   categories,
-		Transactions: transactions,
+		Accounts:        accounts,
+		Categories:      categories,
+		Transactions:    transactions,
+		Payees:          payees,
+		Attachments:     attachments,
+		AttachmentFiles: files,
 	}
 
 	file, err := os.Create(filepath)
@@ -90,11 +123,42 @@ func (r *Repository) ImportDataFromJSON(filepath string) error {
 		}
 	}
 
-	// Import transactions
+	// Import payees first so their aliases link the transactions; defaults
+	// point at IDs in the exported database and are left out
+	for _, p := range data.Payees {
+		p.ID, p.DefaultCategoryID, p.DefaultAccountID = 0, nil, nil
+		err := r.CreatePayee(&p)
+		if err != nil && !errors.Is(err, ErrPayeeExists) && !errors.Is(err, ErrPayeeAliasTaken) {
+			return err
+		}
+	}
+
+	// Import transactions, remembering their new IDs for the attachments
+	payees, err := loadPayeeMatcher(r.DB)
+	if err != nil {
+		return err
+	}
+	newIDs := make(map[int64]int64, len(data.Transactions))
 	for _, t := range data.Transactions {
-		if err := r.CreateTransaction(&t); err != nil {
+		oldID := t.ID
+		if err := r.createTransaction(payees, &t); err != nil {
 			return err
 		}
+		newIDs[oldID] = t.ID
+	}
+
+	for _, a := range data.Attachments {
+		txID, ok := newIDs[a.TransactionID]
+		if !ok {
+			continue
+		}
+		fileData, ok := data.AttachmentFiles[a.Hash]
+		if !ok {
+			return fmt.Errorf("attachment %q: file missing from backup", a.Name)
+		}
+		if _, err := r.AddAttachment(txID, a.Name, fileData); err != nil {
+			return fmt.Errorf("attachment %q: %w", a.Name, err)
+		}
 	}
 
 	return tx.Commit()
@@ -112,27 +176,29 @@ type CSVTransactionRow struct {
 
 // ImportTransactionsFromCSV imports transactions from a CSV file
 // Expected CSV format: Date,Description,Amount,Category,Account,Note
-func (r *Repository) ImportTransactionsFromCSV(filepath string, accountName string, categoryName string) error {
+// The report lists imported rows that look like existing transactions, so
+// a file imported twice can be cleaned up.
+func (r *Repository) ImportTransactionsFromCSV(filepath string, accountName string, categoryName string) (*model.CSVImportReport, error) {
 	file, err := os.Open(filepath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer file.Close()
 
 	reader := csv.NewReader(file)
 	records, err := reader.ReadAll()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(records) < 2 {
-		return fmt.Errorf("CSV file must have at least a header and one data row")
+		return nil, fmt.Errorf("CSV file must have at least a header and one data row")
 	}
 
 	// Get default account and category if not provided
 	accounts, err := r.GetAllAccounts()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var defaultAccountID int64
@@ -151,7 +217,11 @@ func (r *Repository) ImportTransactionsFromCSV(filepath string, accountName stri
 
 	categories, err := r.GetAllCategories()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	payees, err := loadPayeeMatcher(r.DB)
+	if err != nil {
+		return nil, err
 	}
 
 	var defaultCategoryID int64
@@ -189,13 +259,16 @@ func (r *Repository) ImportTransactionsFromCSV(filepath string, accountName stri
 	}
 
 	if dateIdx == -1 || descIdx == -1 || amountIdx == -1 {
-		return fmt.Errorf("CSV must have Date, Description, and Amount columns")
+		return nil, fmt.Errorf("CSV must have Date, Description, and Amount columns")
 	}
 
 	// Process rows
+	report := &model.CSVImportReport{}
+	var imported []int64
 	for i := 1; i < len(records); i++ {
 		row := records[i]
 		if len(row) <= dateIdx || len(row) <= descIdx || len(row) <= amountIdx {
+			report.Skipped++
 			continue // Skip malformed rows
 		}
 
@@ -206,20 +279,11 @@ func (r *Repository) ImportTransactionsFromCSV(filepath string, accountName stri
 			// Try other formats
 			date, err = time.Parse("01/02/2006", dateStr)
 			if err != nil {
+				report.Skipped++
 				continue // Skip rows with invalid dates
 			}
 		}
 
-		// Parse amount
-		amountStr := strings.TrimSpace(row[amountIdx])
-		amountStr = strings.ReplaceAll(amountStr, "$", "")
-		amountStr = strings.ReplaceAll(amountStr, ",", "")
-		amount, err := strconv.ParseFloat(amountStr, 64)
-		if err != nil {
-			continue // Skip rows with invalid amounts
-		}
-		amountCents := int64(amount * 100)
-
 		// Get account
 		accID := defaultAccountID
 		if accIdx >= 0 && accIdx < len(row) && strings.TrimSpace(row[accIdx]) != "" {
@@ -231,6 +295,29 @@ func (r *Repository) ImportTransactionsFromCSV(filepath string, accountName stri
 				}
 			}
 		}
+		var currency string
+		for _, acc := range accounts {
+			if acc.ID == accID {
+				currency = acc.Currency
+				break
+			}
+		}
+
+		// Parse amount in the account's currency, dropping any symbol or code
+		amountStr := strings.TrimSpace(row[amountIdx])
+		amountStr = strings.ReplaceAll(amountStr, "$", "")
+		if c, ok := model.LookupCurrency(currency); ok {
+			if c.Symbol != "" {
+				amountStr = strings.ReplaceAll(amountStr, c.Symbol, "")
+			}
+			amountStr = strings.TrimSpace(strings.ReplaceAll(amountStr, c.Code, ""))
+		}
+		amount, err := model.ParseMoney(amountStr, currency)
+		if err != nil {
+			report.Skipped++
+			continue // Skip rows with invalid amounts
+		}
+		amountCents := amount.Amount
 
 		// Get category
 		catID := defaultCategoryID
@@ -256,26 +343,34 @@ func (r *Repository) ImportTransactionsFromCSV(filepath string, accountName stri
 			Status:      model.TransactionStatusPending,
 			Splits: []model.Split{
 				{
-					AccountID:    accID,
-					Amount:       -amountCents,
-					Currency:     "USD",
-					ExchangeRate: 1.0,
+					AccountID: accID,
+					Amount:    -amountCents,
+					Currency:  currency,
 				},
 				{
-					AccountID:    expenseAccountID,
-					CategoryID:   &catID,
-					Amount:       amountCents,
-					Currency:     "USD",
-					ExchangeRate: 1.0,
+					AccountID:  expenseAccountID,
+					CategoryID: &catID,
+					Amount:     amountCents,
+					Currency:   currency,
 				},
 			},
 		}
 
-		if err := r.CreateTransaction(transaction); err != nil {
+		if err := r.ApplyRules(transaction); err != nil {
+			return nil, err
+		}
+		if err := r.createTransaction(payees, transaction); err != nil {
 			// Log error but continue with other rows
+			report.Skipped++
 			continue
 		}
+		imported = append(imported, transaction.ID)
 	}
+	report.Imported = len(imported)
 
-	return nil
+	report.Duplicates, err = r.FindDuplicatesOf(imported)
+	if err != nil {
+		return report, err
+	}
+	return report, nil
 }
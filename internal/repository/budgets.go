@@ -0,0 +1,325 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+const dateLayout = "2006-01-02"
+
+// periodWindow returns the half-open window [start, end) of the budget
+// period containing day. Weeks start on Monday.
+func periodWindow(period model.BudgetPeriod, day time.Time) (time.Time, time.Time) {
+	y, m, d := day.Date()
+	switch period {
+	case model.BudgetPeriodWeekly:
+		offset := (int(day.Weekday()) + 6) % 7 // Monday = 0
+		start := time.Date(y, m, d-offset, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 7)
+	case model.BudgetPeriodQuarterly:
+		qm := time.Month((int(m)-1)/3*3 + 1)
+		start := time.Date(y, qm, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 3, 0)
+	case model.BudgetPeriodYearly:
+		start := time.Date(y, time.January, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(1, 0, 0)
+	default: // Monthly
+		start := time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+}
+
+// SetBudget creates or replaces a budget. For recurring periods the
+// effective date is snapped to the start of its period, and a budget that
+// already starts on that date for the category is updated in place; earlier
+// budgets keep applying to earlier periods. Custom budgets need an EndDate
+// and are keyed on their start date.
+func (r *Repository) SetBudget(b *model.Budget) error {
+	if b.Amount < 0 {
+		return errors.New("budget amount cannot be negative")
+	}
+	if b.Period == "" {
+		b.Period = model.BudgetPeriodMonthly
+	}
+	if b.EffectiveFrom.IsZero() {
+		b.EffectiveFrom = time.Now()
+	}
+
+	var endDate interface{}
+	isCustom := b.Period == model.BudgetPeriodCustom
+	if isCustom {
+		b.Rollover = false // A one-off range has no next period to carry into
+		if b.EndDate == nil {
+			return errors.New("custom budgets need an end date")
+		}
+		if b.EndDate.Before(b.EffectiveFrom) {
+			return errors.New("budget end date is before its start date")
+		}
+		y, m, d := b.EffectiveFrom.Date()
+		b.EffectiveFrom = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		endDate = b.EndDate.Format(dateLayout)
+	} else {
+		b.EffectiveFrom, _ = periodWindow(b.Period, b.EffectiveFrom)
+		b.EndDate = nil
+	}
+	from := b.EffectiveFrom.Format(dateLayout)
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var existingID int64
+	err = tx.QueryRow(`
+		SELECT id FROM budgets
+		WHERE category_id = ? AND effective_from = ? AND (period = 'Custom') = ?
+	`, b.CategoryID, from, isCustom).Scan(&existingID)
+	switch {
+	case err == sql.ErrNoRows:
+		res, err := tx.Exec(`INSERT INTO budgets (category_id, amount, period, effective_from, end_date, rollover) VALUES (?, ?, ?, ?, ?, ?)`,
+			b.CategoryID, b.Amount, b.Period, from, endDate, b.Rollover)
+		if err != nil {
+			return err
+		}
+		if b.ID, err = res.LastInsertId(); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		_, err = tx.Exec(`UPDATE budgets SET amount = ?, period = ?, end_date = ?, rollover = ? WHERE id = ?`,
+			b.Amount, b.Period, endDate, b.Rollover, existingID)
+		if err != nil {
+			return err
+		}
+		b.ID = existingID
+	}
+
+	return tx.Commit()
+}
+
+// GetBudgets returns every stored budget row, oldest first.
+func (r *Repository) GetBudgets() ([]model.Budget, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, category_id, amount, period, effective_from, end_date, rollover
+		FROM budgets
+		ORDER BY category_id, effective_from
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var budgets []model.Budget
+	for rows.Next() {
+		var b model.Budget
+		var period sql.NullString
+		var end sql.NullTime
+		if err := rows.Scan(&b.ID, &b.CategoryID, &b.Amount, &period, &b.EffectiveFrom, &end, &b.Rollover); err != nil {
+			return nil, err
+		}
+		b.Period = model.BudgetPeriod(period.String)
+		if b.Period == "" {
+			b.Period = model.BudgetPeriodMonthly
+		}
+		if end.Valid {
+			b.EndDate = &end.Time
+		}
+		budgets = append(budgets, b)
+	}
+	return budgets, rows.Err()
+}
+
+// activeBudgets picks the budgets in force on day: for each category the
+// latest recurring budget that has taken effect, plus any custom budgets
+// whose range covers day.
+func activeBudgets(budgets []model.Budget, day time.Time) []model.Budget {
+	var active []model.Budget
+	latest := make(map[int64]int) // category -> index in active
+
+	for _, b := range budgets {
+		if b.EffectiveFrom.After(day) {
+			continue
+		}
+		if b.Period == model.BudgetPeriodCustom {
+			if b.EndDate != nil && !b.EndDate.Before(day) {
+				active = append(active, b)
+			}
+			continue
+		}
+		// budgets are ordered by effective_from, so later rows replace earlier ones
+		if i, ok := latest[b.CategoryID]; ok {
+			active[i] = b
+			continue
+		}
+		latest[b.CategoryID] = len(active)
+		active = append(active, b)
+	}
+	return active
+}
+
+// budgetWindow returns the half-open date window a budget covers around day.
+func budgetWindow(b model.Budget, day time.Time) (time.Time, time.Time) {
+	if b.Period == model.BudgetPeriodCustom && b.EndDate != nil {
+		return b.EffectiveFrom, b.EndDate.AddDate(0, 0, 1)
+	}
+	return periodWindow(b.Period, day)
+}
+
+// spentInWindow sums expense debits for a set of categories in [start, end).
+func (r *Repository) spentInWindow(catIDs []int64, start, end time.Time) (int64, error) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(catIDs)), ",")
+	args := make([]interface{}, 0, len(catIDs)+2)
+	for _, id := range catIDs {
+		args = append(args, id)
+	}
+	args = append(args, start.Format(dateLayout), end.Format(dateLayout))
+
+	// Amount is Debit (Positive) for Expenses, valued in the base currency
+	query := `
+		SELECT CAST(SUM(ROUND(s.amount * s.exchange_rate)) AS INTEGER)
+		FROM splits s
+		JOIN transactions t ON s.transaction_id = t.id
+		WHERE s.category_id IN (` + placeholders + `)
+		AND t.date >= ? AND t.date < ?
+		AND s.amount > 0 -- Only sum debits (expenses)
+	`
+	var spent sql.NullInt64
+	if err := r.DB.QueryRow(query, args...).Scan(&spent); err != nil {
+		return 0, err
+	}
+	return spent.Int64, nil
+}
+
+// transactionsInWindow lists the transactions that spent in the given
+// categories between start (inclusive) and end (exclusive), largest first.
+func (r *Repository) transactionsInWindow(catIDs []int64, start, end time.Time) ([]int64, error) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(catIDs)), ",")
+	args := make([]interface{}, 0, len(catIDs)+2)
+	for _, id := range catIDs {
+		args = append(args, id)
+	}
+	args = append(args, start.Format(dateLayout), end.Format(dateLayout))
+
+	rows, err := r.DB.Query(`
+		SELECT t.id
+		FROM splits s
+		JOIN transactions t ON s.transaction_id = t.id
+		WHERE s.category_id IN (`+placeholders+`)
+		AND t.date >= ? AND t.date < ?
+		AND s.amount > 0
+		GROUP BY t.id
+		ORDER BY SUM(ROUND(s.amount * s.exchange_rate)) DESC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetBudgetsWithProgress reports every budget in force on asOf, measured
+// against its own period window (the week, month, quarter or year that
+// contains asOf, or the custom range). A budget on a parent category
+// covers spending in all its subcategories. Carry-overs of rollover
+// budgets are worked out from splits on each call, so edits to past
+// transactions are reflected.
+func (r *Repository) GetBudgetsWithProgress(asOf time.Time) ([]model.BudgetProgress, error) {
+	y, m, d := asOf.Date()
+	asOf = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	budgets, err := r.GetBudgets()
+	if err != nil {
+		return nil, err
+	}
+	tree, err := r.loadCategoryTree()
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := r.GetBaseCurrency()
+	if err != nil {
+		return nil, err
+	}
+
+	active := activeBudgets(budgets, asOf)
+	var ledgers map[int64][]model.RolloverEntry
+	var carries map[int64]int64
+	for _, b := range active {
+		if b.Rollover {
+			if ledgers, carries, err = r.rolloverCarries(tree, asOf); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	var progress []model.BudgetProgress
+	for _, b := range active {
+		start, end := budgetWindow(b, asOf)
+		var carriedCents int64
+		if b.Rollover {
+			carriedCents = carries[b.CategoryID]
+			// After a switch to a longer period, the days already closed
+			// under the shorter one are not counted again
+			if ledger := ledgers[b.CategoryID]; len(ledger) > 0 {
+				if closed := ledger[len(ledger)-1].PeriodEnd.AddDate(0, 0, 1); closed.After(start) {
+					start = closed
+				}
+			}
+		}
+		spentCents, err := r.spentInWindow(tree.subtree(b.CategoryID), start, end)
+		if err != nil {
+			return nil, err
+		}
+
+		spent := model.NewMoney(spentCents, base)
+		budgeted := model.NewMoney(b.Amount, base)
+		carriedIn := model.NewMoney(carriedCents, base)
+		available, err := budgeted.Add(carriedIn)
+		if err != nil {
+			return nil, err
+		}
+		remaining, err := available.Sub(spent)
+		if err != nil {
+			return nil, err
+		}
+		percent := 0.0
+		if available.Amount > 0 {
+			percent = float64(spent.Amount) / float64(available.Amount)
+		} else if spent.Amount > 0 {
+			percent = 1
+		}
+
+		progress = append(progress, model.BudgetProgress{
+			BudgetID:     b.ID,
+			CategoryID:   b.CategoryID,
+			CategoryName: tree.byID[b.CategoryID].Name,
+			Period:       b.Period,
+			PeriodStart:  start,
+			PeriodEnd:    end.AddDate(0, 0, -1),
+			Budgeted:     budgeted,
+			CarriedIn:    carriedIn,
+			Available:    available,
+			Spent:        spent,
+			Remaining:    remaining,
+			Percent:      percent,
+			Rollover:     b.Rollover,
+		})
+	}
+	return progress, nil
+}
@@ -0,0 +1,453 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+// Category tree errors.
+var (
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrCategoryCycle    = errors.New("a category cannot be moved under itself or one of its subcategories")
+	ErrCategoryInUse    = errors.New("category is still used by transactions, budgets or rules; choose a replacement")
+	ErrBudgetConflict   = errors.New("both categories have a budget starting on the same date with a different period; change one first")
+)
+
+// categoryTree indexes categories by ID and by parent so totals can be
+// rolled up from subcategories into their ancestors.
+type categoryTree struct {
+	byID     map[int64]model.Category
+	children map[int64][]int64 // parent ID -> direct children; roots live under 0
+}
+
+func newCategoryTree(categories []model.Category) *categoryTree {
+	t := &categoryTree{
+		byID:     make(map[int64]model.Category, len(categories)),
+		children: make(map[int64][]int64),
+	}
+	for _, c := range categories {
+		t.byID[c.ID] = c
+	}
+	for _, c := range categories {
+		parent := int64(0)
+		if c.ParentID != nil {
+			// Orphans (parent row missing) are treated as roots
+			if _, ok := t.byID[*c.ParentID]; ok {
+				parent = *c.ParentID
+			}
+		}
+		t.children[parent] = append(t.children[parent], c.ID)
+	}
+	return t
+}
+
+func (r *Repository) loadCategoryTree() (*categoryTree, error) {
+	categories, err := r.GetAllCategories()
+	if err != nil {
+		return nil, err
+	}
+	return newCategoryTree(categories), nil
+}
+
+// parentOf returns the parent ID of a category, or 0 for roots.
+func (t *categoryTree) parentOf(id int64) int64 {
+	c, ok := t.byID[id]
+	if !ok || c.ParentID == nil {
+		return 0
+	}
+	if _, ok := t.byID[*c.ParentID]; !ok {
+		return 0
+	}
+	return *c.ParentID
+}
+
+// subtree returns id followed by all of its descendants.
+func (t *categoryTree) subtree(id int64) []int64 {
+	ids := []int64{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, t.children[ids[i]]...)
+	}
+	return ids
+}
+
+// ancestorUnder walks up from id and returns the ancestor (or id itself)
+// whose parent is level. ok is false if id is not below level.
+func (t *categoryTree) ancestorUnder(id, level int64) (int64, bool) {
+	seen := make(map[int64]bool)
+	for id != 0 && !seen[id] {
+		seen[id] = true
+		parent := t.parentOf(id)
+		if parent == level {
+			return id, true
+		}
+		id = parent
+	}
+	return 0, false
+}
+
+// GetCategoryByID returns a single category, or nil if it does not exist.
+func (r *Repository) GetCategoryByID(id int64) (*model.Category, error) {
+	query := `SELECT id, name, icon, color, parent_id FROM categories WHERE id = ?`
+	var c model.Category
+	var icon, color sql.NullString
+	err := r.DB.QueryRow(query, id).Scan(&c.ID, &c.Name, &icon, &color, &c.ParentID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	c.Icon = icon.String
+	c.Color = color.String
+	return &c, nil
+}
+
+// CreateCategory inserts a category, optionally under an existing parent.
+func (r *Repository) CreateCategory(c *model.Category) error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return errors.New("category name is required")
+	}
+	if c.ParentID != nil {
+		parent, err := r.GetCategoryByID(*c.ParentID)
+		if err != nil {
+			return err
+		}
+		if parent == nil {
+			return ErrCategoryNotFound
+		}
+	}
+
+	query := `INSERT INTO categories (name, icon, color, parent_id) VALUES (?, ?, ?, ?)`
+	res, err := r.DB.Exec(query, c.Name, c.Icon, c.Color, c.ParentID)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	c.ID = id
+	return nil
+}
+
+// MoveCategory re-parents a category. A nil parent makes it top-level.
+func (r *Repository) MoveCategory(id int64, newParentID *int64) error {
+	tree, err := r.loadCategoryTree()
+	if err != nil {
+		return err
+	}
+	if _, ok := tree.byID[id]; !ok {
+		return ErrCategoryNotFound
+	}
+	if newParentID != nil {
+		if _, ok := tree.byID[*newParentID]; !ok {
+			return ErrCategoryNotFound
+		}
+		for _, d := range tree.subtree(id) {
+			if d == *newParentID {
+				return ErrCategoryCycle
+			}
+		}
+	}
+
+	_, err = r.DB.Exec("UPDATE categories SET parent_id = ? WHERE id = ?", newParentID, id)
+	return err
+}
+
+// UpdateCategory saves a category's name, icon and color. Use MoveCategory
+// to change its parent.
+func (r *Repository) UpdateCategory(c *model.Category) error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return errors.New("category name is required")
+	}
+
+	query := `UPDATE categories SET name = ?, icon = ?, color = ? WHERE id = ?`
+	res, err := r.DB.Exec(query, c.Name, c.Icon, c.Color, c.ID)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(res, ErrCategoryNotFound)
+}
+
+// reassignCategoryRefs points everything that references category from at
+// category to instead: splits, recurring templates, budgets, rules, payee
+// defaults, envelope assignments and rollover ledger rows.
+func reassignCategoryRefs(tx *sql.Tx, from, to int64) error {
+	if _, err := tx.Exec("UPDATE splits SET category_id = ? WHERE category_id = ?", to, from); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE recurring_splits SET category_id = ? WHERE category_id = ?", to, from); err != nil {
+		return err
+	}
+	if err := foldBudgets(tx, from, to); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE rules SET target_category_id = ? WHERE target_category_id = ?", to, from); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE payees SET default_category_id = ? WHERE default_category_id = ?", to, from); err != nil {
+		return err
+	}
+	// Envelope assignments are unique per month, so fold them into any the target already has
+	_, err := tx.Exec(`
+		INSERT INTO envelope_assignments (month, category_id, amount)
+		SELECT month, ?, amount FROM envelope_assignments WHERE category_id = ? AND true
+		ON CONFLICT(month, category_id) DO UPDATE SET amount = amount + excluded.amount
+	`, to, from)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM envelope_assignments WHERE category_id = ?", from); err != nil {
+		return err
+	}
+	// The saved ledger is rebuilt on the next recompute; this just keeps its foreign keys valid
+	if _, err := tx.Exec("UPDATE budget_rollovers SET category_id = ? WHERE category_id = ?", to, from); err != nil {
+		return err
+	}
+	return nil
+}
+
+// foldBudgets moves a category's budgets to another. Budgets are unique
+// per category and start date, so one that collides with a budget of the
+// target is added into it; colliding budgets with different periods or
+// custom ranges return ErrBudgetConflict.
+func foldBudgets(tx *sql.Tx, from, to int64) error {
+	rows, err := tx.Query(`
+		SELECT s.id, s.amount, s.rollover, d.id,
+			COALESCE(s.period, 'Monthly') = COALESCE(d.period, 'Monthly') AND s.end_date IS d.end_date
+		FROM budgets s
+		JOIN budgets d ON d.category_id = ? AND d.effective_from = s.effective_from
+			AND (d.period = 'Custom') IS (s.period = 'Custom')
+		WHERE s.category_id = ?
+	`, to, from)
+	if err != nil {
+		return err
+	}
+	type collision struct {
+		sourceID, targetID int64
+		amount             int64
+		rollover, same     bool
+	}
+	var collisions []collision
+	for rows.Next() {
+		var c collision
+		if err := rows.Scan(&c.sourceID, &c.amount, &c.rollover, &c.targetID, &c.same); err != nil {
+			rows.Close()
+			return err
+		}
+		collisions = append(collisions, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, c := range collisions {
+		if !c.same {
+			return ErrBudgetConflict
+		}
+		if _, err := tx.Exec("UPDATE budgets SET amount = amount + ?, rollover = rollover OR ? WHERE id = ?",
+			c.amount, c.rollover, c.targetID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE budget_rollovers SET budget_id = ? WHERE budget_id = ?", c.targetID, c.sourceID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM budgets WHERE id = ?", c.sourceID); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("UPDATE budgets SET category_id = ? WHERE category_id = ?", to, from)
+	return err
+}
+
+// MergeCategory folds source into target in one transaction: splits,
+// budgets and rules that use source are re-pointed at target, source's
+// subcategories move under target, and source is removed.
+func (r *Repository) MergeCategory(sourceID, targetID int64) error {
+	if sourceID == targetID {
+		return errors.New("cannot merge a category into itself")
+	}
+	tree, err := r.loadCategoryTree()
+	if err != nil {
+		return err
+	}
+	if _, ok := tree.byID[sourceID]; !ok {
+		return ErrCategoryNotFound
+	}
+	if _, ok := tree.byID[targetID]; !ok {
+		return ErrCategoryNotFound
+	}
+	if _, below := tree.ancestorUnder(targetID, sourceID); below {
+		return ErrCategoryCycle
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := reassignCategoryRefs(tx, sourceID, targetID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE categories SET parent_id = ? WHERE parent_id = ?", targetID, sourceID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM categories WHERE id = ?", sourceID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CategoryUsage counts how many records reference a category. Zeroed-out
+// envelope assignments do not count.
+func (r *Repository) CategoryUsage(id int64) (int, error) {
+	var refs int
+	err := r.DB.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM splits WHERE category_id = ?) +
+		       (SELECT COUNT(*) FROM recurring_splits WHERE category_id = ?) +
+		       (SELECT COUNT(*) FROM budgets WHERE category_id = ?) +
+		       (SELECT COUNT(*) FROM rules WHERE target_category_id = ?) +
+		       (SELECT COUNT(*) FROM envelope_assignments WHERE category_id = ? AND amount != 0)
+	`, id, id, id, id, id).Scan(&refs)
+	return refs, err
+}
+
+// DeleteCategory removes a category. If it is still used by splits, budgets
+// or rules, replacementID must name the category that takes them over;
+// pass 0 for an unused category. Subcategories are promoted to its parent.
+func (r *Repository) DeleteCategory(id, replacementID int64) error {
+	tree, err := r.loadCategoryTree()
+	if err != nil {
+		return err
+	}
+	c, ok := tree.byID[id]
+	if !ok {
+		return ErrCategoryNotFound
+	}
+
+	refs, err := r.CategoryUsage(id)
+	if err != nil {
+		return err
+	}
+	if refs > 0 {
+		if replacementID == 0 {
+			return ErrCategoryInUse
+		}
+		if replacementID == id {
+			return errors.New("replacement must be a different category")
+		}
+		if _, ok := tree.byID[replacementID]; !ok {
+			return ErrCategoryNotFound
+		}
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if refs > 0 {
+		if err := reassignCategoryRefs(tx, id, replacementID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM envelope_assignments WHERE category_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE categories SET parent_id = ? WHERE parent_id = ?", c.ParentID, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM categories WHERE id = ?", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// spendingByCategory returns raw (not rolled-up) expense totals in base
+// minor units keyed by the category each split was booked against. Only
+// expense-account legs count, so transfers between own accounts never do.
+func (r *Repository) spendingByCategory(startDate, endDate *time.Time) (map[int64]int64, error) {
+	query := `
+		SELECT s.category_id, SUM(s.amount * s.exchange_rate) as total
+		FROM splits s
+		JOIN transactions t ON s.transaction_id = t.id
+		JOIN accounts a ON s.account_id = a.id
+		WHERE s.amount > 0 AND s.category_id IS NOT NULL AND a.type = 'Expense'
+	`
+	var args []interface{}
+
+	if startDate != nil {
+		query += " AND t.date >= ?"
+		args = append(args, startDate.Format("2006-01-02"))
+	}
+	if endDate != nil {
+		query += " AND t.date <= ?"
+		args = append(args, endDate.Format("2006-01-02"))
+	}
+
+	query += " GROUP BY s.category_id"
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := make(map[int64]int64)
+	for rows.Next() {
+		var catID int64
+		var total sql.NullFloat64
+		if err := rows.Scan(&catID, &total); err != nil {
+			return nil, err
+		}
+		totals[catID] = int64(math.RoundToEven(total.Float64))
+	}
+	return totals, rows.Err()
+}
+
+// breakdownUnder rolls totals up to the direct children of level (0 for
+// the top level). When level is a real category, spending booked directly
+// against it is reported as its own row.
+func (t *categoryTree) breakdownUnder(level int64, totals map[int64]int64, currency string) []model.CategoryBreakdown {
+	rolled := make(map[int64]int64)
+	for catID, amount := range totals {
+		if catID == level {
+			rolled[level] += amount
+			continue
+		}
+		if node, ok := t.ancestorUnder(catID, level); ok {
+			rolled[node] += amount
+		}
+	}
+
+	var breakdowns []model.CategoryBreakdown
+	for catID, cents := range rolled {
+		c := t.byID[catID]
+		cb := model.CategoryBreakdown{
+			CategoryID:   catID,
+			CategoryName: c.Name,
+			Color:        c.Color,
+			Amount:       model.NewMoney(cents, currency),
+			HasChildren:  catID != level && len(t.children[catID]) > 0,
+		}
+		breakdowns = append(breakdowns, cb)
+	}
+
+	sort.Slice(breakdowns, func(i, j int) bool {
+		return breakdowns[i].Amount.Amount > breakdowns[j].Amount.Amount
+	})
+	return breakdowns
+}
@@ -0,0 +1,474 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+// ErrSameTransaction is returned when both sides of a pair are one transaction.
+var ErrSameTransaction = errors.New("choose two different transactions")
+
+// Duplicate detection settings.
+const (
+	// duplicateWindowDays is how far apart two entries of one payment may
+	// be dated; banks often post a card payment a few days after it was made.
+	duplicateWindowDays = 4
+	// duplicateAmountDrift allows for a manual entry rounded differently
+	// from the bank's figure.
+	duplicateAmountDrift = 0.01
+	// minDuplicateScore hides weak matches. Entries a day apart for the same
+	// amount in the same account pass even if named differently, as a
+	// manual entry and its bank line usually are.
+	minDuplicateScore = 0.7
+)
+
+// ledgerEntry is a transaction reduced to what duplicate detection compares:
+// its largest leg in one of the user's own accounts.
+type ledgerEntry struct {
+	txID      int64
+	date      time.Time
+	payee     string // Linked payee's key, or NormalizePayee(description)
+	status    model.TransactionStatus
+	accountID int64
+	amount    int64 // Signed, in the account's currency
+	currency  string
+}
+
+// FindDuplicates returns pairs of transactions that look like the same
+// payment entered twice, best matches first. Pairs marked as not
+// duplicates are left out.
+func (r *Repository) FindDuplicates() ([]model.DuplicatePair, error) {
+	return r.findDuplicates(nil, nil, nil)
+}
+
+// FindDuplicatesOf is FindDuplicates limited to pairs involving at least
+// one of txIDs, e.g. the rows of an import.
+func (r *Repository) FindDuplicatesOf(txIDs []int64) ([]model.DuplicatePair, error) {
+	if len(txIDs) == 0 {
+		return nil, nil
+	}
+	only := make(map[int64]bool, len(txIDs))
+	for _, id := range txIDs {
+		only[id] = true
+	}
+
+	// Only dates near the given transactions can hold a match
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(txIDs)), ",")
+	args := make([]interface{}, len(txIDs))
+	for i, id := range txIDs {
+		args[i] = id
+	}
+	rows, err := r.DB.Query("SELECT date FROM transactions WHERE id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var from, to time.Time
+	for rows.Next() {
+		var d time.Time
+		if err := rows.Scan(&d); err != nil {
+			return nil, err
+		}
+		if from.IsZero() || d.Before(from) {
+			from = d
+		}
+		if d.After(to) {
+			to = d
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if from.IsZero() {
+		return nil, nil
+	}
+	from = from.AddDate(0, 0, -duplicateWindowDays)
+	to = to.AddDate(0, 0, duplicateWindowDays)
+	return r.findDuplicates(&from, &to, only)
+}
+
+func (r *Repository) findDuplicates(from, to *time.Time, only map[int64]bool) ([]model.DuplicatePair, error) {
+	entries, err := r.loadLedgerEntries(from, to)
+	if err != nil {
+		return nil, err
+	}
+	dismissed, err := r.dismissedDuplicates()
+	if err != nil {
+		return nil, err
+	}
+
+	var pairs []model.DuplicatePair
+	for i, a := range entries {
+		for _, b := range entries[i+1:] {
+			if b.date.Sub(a.date).Hours()/24 > duplicateWindowDays {
+				break
+			}
+			if only != nil && !only[a.txID] && !only[b.txID] {
+				continue
+			}
+			lo, hi := orderedPair(a.txID, b.txID)
+			if dismissed[[2]int64{lo, hi}] {
+				continue
+			}
+			score, reasons, ok := scoreDuplicate(a, b)
+			if !ok || score < minDuplicateScore {
+				continue
+			}
+			pairs = append(pairs, model.DuplicatePair{
+				A:       model.Transaction{ID: lo},
+				B:       model.Transaction{ID: hi},
+				Score:   score,
+				Reasons: reasons,
+			})
+		}
+	}
+
+	loaded := make(map[int64]*model.Transaction)
+	load := func(id int64) (model.Transaction, error) {
+		if t, ok := loaded[id]; ok {
+			return *t, nil
+		}
+		t, err := r.GetTransactionByID(id)
+		if err != nil {
+			return model.Transaction{}, err
+		}
+		if t == nil {
+			return model.Transaction{}, ErrTransactionNotFound
+		}
+		loaded[id] = t
+		return *t, nil
+	}
+	for i := range pairs {
+		if pairs[i].A, err = load(pairs[i].A.ID); err != nil {
+			return nil, err
+		}
+		if pairs[i].B, err = load(pairs[i].B.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool {
+		if pairs[i].Score != pairs[j].Score {
+			return pairs[i].Score > pairs[j].Score
+		}
+		return pairs[i].B.Date.After(pairs[j].B.Date)
+	})
+	return pairs, nil
+}
+
+// loadLedgerEntries returns one entry per transaction between from and to
+// (either may be nil), in date order.
+func (r *Repository) loadLedgerEntries(from, to *time.Time) ([]ledgerEntry, error) {
+	var conditions []string
+	var args []interface{}
+	if from != nil {
+		conditions = append(conditions, "t.date >= ?")
+		args = append(args, from.Format(dateLayout))
+	}
+	if to != nil {
+		conditions = append(conditions, "t.date < ?")
+		args = append(args, to.AddDate(0, 0, 1).Format(dateLayout))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := r.DB.Query(fmt.Sprintf(`
+		SELECT t.id, t.date, t.description, t.status, s.account_id, s.amount, s.currency, a.type, p.name_key
+		FROM transactions t
+		JOIN splits s ON s.transaction_id = t.id
+		JOIN accounts a ON a.id = s.account_id
+		LEFT JOIN payees p ON p.id = t.payee_id
+		%s
+		ORDER BY t.date, t.id, s.id
+	`, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// The largest leg in a balance-sheet account stands for the transaction;
+	// a transaction with none falls back to its largest leg
+	var entries []ledgerEntry
+	var ownLeg bool
+	for rows.Next() {
+		var e ledgerEntry
+		var description string
+		var status *string
+		var accType model.AccountType
+		var payeeKey *string
+		if err := rows.Scan(&e.txID, &e.date, &description, &status, &e.accountID, &e.amount, &e.currency, &accType, &payeeKey); err != nil {
+			return nil, err
+		}
+		own := isBalanceSheet(accType)
+
+		n := len(entries)
+		if n == 0 || entries[n-1].txID != e.txID {
+			e.payee = NormalizePayee(description)
+			if payeeKey != nil {
+				e.payee = *payeeKey
+			}
+			if status != nil {
+				e.status = model.TransactionStatus(*status)
+			}
+			entries = append(entries, e)
+			ownLeg = own
+			continue
+		}
+		last := &entries[n-1]
+		if (own && !ownLeg) || (own == ownLeg && abs64(e.amount) > abs64(last.amount)) {
+			last.accountID, last.amount, last.currency = e.accountID, e.amount, e.currency
+			ownLeg = own
+		}
+	}
+	return entries, rows.Err()
+}
+
+// scoreDuplicate rates how likely two entries are one payment. Entries in
+// different currencies, in opposite directions or with amounts too far
+// apart never match, and neither do two entries already reconciled
+// against statements, since the bank has confirmed both.
+func scoreDuplicate(a, b ledgerEntry) (float64, []string, bool) {
+	if a.currency != b.currency || (a.amount < 0) != (b.amount < 0) {
+		return 0, nil, false
+	}
+	if a.status == model.TransactionStatusReconciled && b.status == model.TransactionStatusReconciled {
+		return 0, nil, false
+	}
+	larger := math.Max(float64(abs64(a.amount)), float64(abs64(b.amount)))
+	diff := float64(abs64(a.amount - b.amount))
+	if larger == 0 || diff > duplicateAmountDrift*larger {
+		return 0, nil, false
+	}
+	days := math.Round(math.Abs(b.date.Sub(a.date).Hours()) / 24)
+	if days > duplicateWindowDays {
+		return 0, nil, false
+	}
+
+	var reasons []string
+	amountScore := 1.0
+	if diff == 0 {
+		reasons = append(reasons, "same amount")
+	} else {
+		amountScore = 1 - 0.5*diff/(duplicateAmountDrift*larger)
+		reasons = append(reasons, "amounts within 1%")
+	}
+
+	dateScore := 1 - days/(duplicateWindowDays+1)
+	if days == 0 {
+		reasons = append(reasons, "same day")
+	} else {
+		reasons = append(reasons, fmt.Sprintf("%.0f day(s) apart", days))
+	}
+
+	accountScore := 0.0
+	if a.accountID == b.accountID {
+		accountScore = 1
+		reasons = append(reasons, "same account")
+	}
+
+	similarity := payeeSimilarity(a.payee, b.payee)
+	if similarity == 1 {
+		reasons = append(reasons, "same payee")
+	} else if similarity >= 0.5 {
+		reasons = append(reasons, "similar description")
+	}
+
+	score := 0.35*amountScore + 0.25*dateScore + 0.2*accountScore + 0.2*similarity
+	return score, reasons, true
+}
+
+// payeeSimilarity compares normalized payees by the letter pairs they
+// share (the Sørensen–Dice coefficient), so "netflix" and "netflix premium"
+// are close while "coffee" and "starbucks" are not.
+func payeeSimilarity(a, b string) float64 {
+	ra := []rune(strings.ReplaceAll(a, " ", ""))
+	rb := []rune(strings.ReplaceAll(b, " ", ""))
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	if string(ra) == string(rb) {
+		return 1
+	}
+	if len(ra) < 2 || len(rb) < 2 {
+		return 0
+	}
+	pairs := make(map[[2]rune]int)
+	for i := 0; i+1 < len(ra); i++ {
+		pairs[[2]rune{ra[i], ra[i+1]}]++
+	}
+	shared := 0
+	for i := 0; i+1 < len(rb); i++ {
+		p := [2]rune{rb[i], rb[i+1]}
+		if pairs[p] > 0 {
+			pairs[p]--
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(ra)-1+len(rb)-1)
+}
+
+// MergeDuplicates deletes dropID after moving its note, category, tags,
+// attachments and cleared status onto keepID where keepID lacks them.
+func (r *Repository) MergeDuplicates(keepID, dropID int64) error {
+	if keepID == dropID {
+		return ErrSameTransaction
+	}
+	keep, err := r.GetTransactionByID(keepID)
+	if err != nil {
+		return err
+	}
+	drop, err := r.GetTransactionByID(dropID)
+	if err != nil {
+		return err
+	}
+	if keep == nil || drop == nil {
+		return ErrTransactionNotFound
+	}
+	if drop.Status == model.TransactionStatusReconciled {
+		return ErrTransactionReconciled
+	}
+
+	note := keep.Note
+	if len(strings.TrimSpace(drop.Note)) > len(strings.TrimSpace(keep.Note)) {
+		note = drop.Note
+	}
+	status := keep.Status
+	if status != model.TransactionStatusReconciled && drop.Status == model.TransactionStatusCleared {
+		status = model.TransactionStatusCleared
+	}
+
+	tree, err := r.loadCategoryTree()
+	if err != nil {
+		return err
+	}
+	accounts, err := r.GetAllAccounts()
+	if err != nil {
+		return err
+	}
+	types := make(map[int64]model.AccountType, len(accounts))
+	for _, a := range accounts {
+		types[a.ID] = a.Type
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE transactions SET note = ? WHERE id = ?", note, keepID); err != nil {
+		return err
+	}
+	if status != keep.Status {
+		if err := setTransactionStatus(tx, keepID, status); err != nil {
+			return err
+		}
+	}
+	if splitID, catID, ok := mergedCategory(keep, drop, tree, types); ok {
+		if _, err := tx.Exec("UPDATE splits SET category_id = ? WHERE id = ?", catID, splitID); err != nil {
+			return err
+		}
+	}
+	if err := setTransactionTags(tx, keepID, append(keep.Tags, drop.Tags...)); err != nil {
+		return err
+	}
+	if err := moveAttachments(tx, dropID, keepID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM splits WHERE transaction_id = ?", dropID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM transactions WHERE id = ?", dropID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// mergedCategory picks the split of keep that should take drop's category:
+// keep's largest income or expense leg when it has no category, or its
+// categorized leg when drop's category is one of that category's
+// subcategories.
+func mergedCategory(keep, drop *model.Transaction, tree *categoryTree, types map[int64]model.AccountType) (int64, int64, bool) {
+	from := largestSplit(drop.Splits, func(s model.Split) bool { return s.CategoryID != nil })
+	if from < 0 {
+		return 0, 0, false
+	}
+	catID := *drop.Splits[from].CategoryID
+
+	if to := largestSplit(keep.Splits, func(s model.Split) bool { return s.CategoryID != nil }); to >= 0 {
+		current := *keep.Splits[to].CategoryID
+		for _, id := range tree.subtree(current)[1:] {
+			if id == catID {
+				return keep.Splits[to].ID, catID, true
+			}
+		}
+		return 0, 0, false
+	}
+
+	to := largestSplit(keep.Splits, func(s model.Split) bool { return !isBalanceSheet(types[s.AccountID]) })
+	if to < 0 {
+		return 0, 0, false
+	}
+	return keep.Splits[to].ID, catID, true
+}
+
+// largestSplit returns the index of the largest split matching keep, or -1.
+func largestSplit(splits []model.Split, keep func(model.Split) bool) int {
+	best := -1
+	for i, s := range splits {
+		if keep(s) && (best < 0 || abs64(s.Amount) > abs64(splits[best].Amount)) {
+			best = i
+		}
+	}
+	return best
+}
+
+// DismissDuplicate marks a pair as not duplicates, permanently hiding it
+// from FindDuplicates.
+func (r *Repository) DismissDuplicate(aID, bID int64) error {
+	if aID == bID {
+		return ErrSameTransaction
+	}
+	lo, hi := orderedPair(aID, bID)
+	_, err := r.DB.Exec("INSERT OR IGNORE INTO duplicate_dismissals (transaction_a, transaction_b) VALUES (?, ?)", lo, hi)
+	return err
+}
+
+func (r *Repository) dismissedDuplicates() (map[[2]int64]bool, error) {
+	rows, err := r.DB.Query("SELECT transaction_a, transaction_b FROM duplicate_dismissals")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	dismissed := make(map[[2]int64]bool)
+	for rows.Next() {
+		var pair [2]int64
+		if err := rows.Scan(&pair[0], &pair[1]); err != nil {
+			return nil, err
+		}
+		dismissed[pair] = true
+	}
+	return dismissed, rows.Err()
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func orderedPair(a, b int64) (int64, int64) {
+	if a > b {
+		return b, a
+	}
+	return a, b
+}
@@ -0,0 +1,361 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+// Envelope budgeting errors.
+var (
+	ErrInsufficientEnvelopeFunds = errors.New("not enough money available to move")
+	ErrEnvelopeNotOverspent      = errors.New("envelope is not overspent")
+)
+
+const monthLayout = "2006-01"
+
+// monthStart returns the first day of the month containing t, in UTC.
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// walk visits every category depth-first, parents before children.
+func (t *categoryTree) walk(fn func(id int64, depth int)) {
+	var visit func(parent int64, depth int)
+	visit = func(parent int64, depth int) {
+		for _, id := range t.children[parent] {
+			fn(id, depth)
+			visit(id, depth+1)
+		}
+	}
+	visit(0, 0)
+}
+
+// GetEnvelopeMonth computes the zero-based budget for the month containing
+// month. Inflows to Income accounts feed the Ready to Assign pool; money
+// assigned to an envelope leaves the pool. Positive envelope balances carry
+// into the next month. Overspending that is not covered within its month
+// resets the envelope to zero and is taken out of the next month's pool.
+func (r *Repository) GetEnvelopeMonth(month time.Time) (*model.EnvelopeMonth, error) {
+	target := monthStart(month)
+	targetKey := target.Format(monthLayout)
+
+	tree, err := r.loadCategoryTree()
+	if err != nil {
+		return nil, err
+	}
+	assigned, err := r.envelopeAssignments(targetKey)
+	if err != nil {
+		return nil, err
+	}
+	activity, err := r.envelopeActivity(targetKey)
+	if err != nil {
+		return nil, err
+	}
+	income, err := r.incomeByMonth(targetKey)
+	if err != nil {
+		return nil, err
+	}
+
+	months := map[string]bool{targetKey: true}
+	for m := range assigned {
+		months[m] = true
+	}
+	for m := range activity {
+		months[m] = true
+	}
+	for m := range income {
+		months[m] = true
+	}
+	var keys []string
+	for m := range months {
+		keys = append(keys, m)
+	}
+	sort.Strings(keys)
+
+	em := &model.EnvelopeMonth{Month: target}
+	carry := make(map[int64]int64)
+	var pool int64
+	for _, key := range keys {
+		pool += income[key]
+		for _, amount := range assigned[key] {
+			pool -= amount
+		}
+		if key == targetKey {
+			break
+		}
+		// Close out an earlier month
+		cats := make(map[int64]bool)
+		for id := range carry {
+			cats[id] = true
+		}
+		for id := range assigned[key] {
+			cats[id] = true
+		}
+		for id := range activity[key] {
+			cats[id] = true
+		}
+		for id := range cats {
+			available := carry[id] + assigned[key][id] - activity[key][id]
+			if available < 0 {
+				em.OverspentBefore += -available
+				available = 0
+			}
+			carry[id] = available
+		}
+	}
+
+	budgeted, err := r.monthlyBudgetTargets(target)
+	if err != nil {
+		return nil, err
+	}
+
+	em.Income = income[targetKey]
+	em.ReadyToAssign = pool - em.OverspentBefore
+	tree.walk(func(id int64, depth int) {
+		e := model.Envelope{
+			CategoryID:   id,
+			CategoryName: tree.byID[id].Name,
+			Depth:        depth,
+			Budgeted:     budgeted[id],
+			Carryover:    carry[id],
+			Assigned:     assigned[targetKey][id],
+			Activity:     activity[targetKey][id],
+		}
+		e.Available = e.Carryover + e.Assigned - e.Activity
+		em.Assigned += e.Assigned
+		em.Envelopes = append(em.Envelopes, e)
+	})
+	return em, nil
+}
+
+// envelopeAssignments returns assignments up to and including month,
+// keyed by month then category.
+func (r *Repository) envelopeAssignments(throughKey string) (map[string]map[int64]int64, error) {
+	rows, err := r.DB.Query(`
+		SELECT month, category_id, amount FROM envelope_assignments WHERE month <= ?
+	`, throughKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMonthCategoryTotals(rows)
+}
+
+// envelopeActivity returns net categorized spending per month and category
+// in the base currency, leaving out the income side of each transaction.
+func (r *Repository) envelopeActivity(throughKey string) (map[string]map[int64]int64, error) {
+	rows, err := r.DB.Query(`
+		SELECT substr(t.date, 1, 7) as month_key, s.category_id, CAST(SUM(ROUND(s.amount * s.exchange_rate)) AS INTEGER)
+		FROM splits s
+		JOIN transactions t ON s.transaction_id = t.id
+		JOIN accounts a ON s.account_id = a.id
+		WHERE s.category_id IS NOT NULL AND a.type != ?
+		AND substr(t.date, 1, 7) <= ?
+		GROUP BY month_key, s.category_id
+	`, model.AccountTypeIncome, throughKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMonthCategoryTotals(rows)
+}
+
+func scanMonthCategoryTotals(rows *sql.Rows) (map[string]map[int64]int64, error) {
+	totals := make(map[string]map[int64]int64)
+	for rows.Next() {
+		var month string
+		var catID int64
+		var amount sql.NullInt64
+		if err := rows.Scan(&month, &catID, &amount); err != nil {
+			return nil, err
+		}
+		if totals[month] == nil {
+			totals[month] = make(map[int64]int64)
+		}
+		totals[month][catID] += amount.Int64
+	}
+	return totals, rows.Err()
+}
+
+// incomeByMonth returns money received per month in the base currency.
+// Income accounts are credited (negative) when income is recorded.
+func (r *Repository) incomeByMonth(throughKey string) (map[string]int64, error) {
+	rows, err := r.DB.Query(`
+		SELECT substr(t.date, 1, 7) as month_key, -CAST(SUM(ROUND(s.amount * s.exchange_rate)) AS INTEGER)
+		FROM splits s
+		JOIN transactions t ON s.transaction_id = t.id
+		JOIN accounts a ON s.account_id = a.id
+		WHERE a.type = ? AND substr(t.date, 1, 7) <= ?
+		GROUP BY month_key
+	`, model.AccountTypeIncome, throughKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	income := make(map[string]int64)
+	for rows.Next() {
+		var month string
+		var amount sql.NullInt64
+		if err := rows.Scan(&month, &amount); err != nil {
+			return nil, err
+		}
+		income[month] = amount.Int64
+	}
+	return income, rows.Err()
+}
+
+// monthlyBudgetTargets returns the Monthly budget in force for each
+// category during the month starting at month.
+func (r *Repository) monthlyBudgetTargets(month time.Time) (map[int64]int64, error) {
+	budgets, err := r.GetBudgets()
+	if err != nil {
+		return nil, err
+	}
+	targets := make(map[int64]int64)
+	for _, b := range activeBudgets(budgets, month) {
+		if b.Period == model.BudgetPeriodMonthly {
+			targets[b.CategoryID] = b.Amount
+		}
+	}
+	return targets, nil
+}
+
+// AssignToEnvelope sets how much is assigned to a category for a month,
+// replacing any previous assignment.
+func (r *Repository) AssignToEnvelope(month time.Time, categoryID, amount int64) error {
+	c, err := r.GetCategoryByID(categoryID)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return ErrCategoryNotFound
+	}
+	_, err = r.DB.Exec(`
+		INSERT INTO envelope_assignments (month, category_id, amount) VALUES (?, ?, ?)
+		ON CONFLICT(month, category_id) DO UPDATE SET amount = excluded.amount
+	`, monthStart(month).Format(monthLayout), categoryID, amount)
+	return err
+}
+
+// adjustAssignment adds delta to a category's assignment for a month.
+func adjustAssignment(tx *sql.Tx, monthKey string, categoryID, delta int64) error {
+	_, err := tx.Exec(`
+		INSERT INTO envelope_assignments (month, category_id, amount) VALUES (?, ?, ?)
+		ON CONFLICT(month, category_id) DO UPDATE SET amount = amount + excluded.amount
+	`, monthKey, categoryID, delta)
+	return err
+}
+
+// MoveEnvelopeFunds moves amount cents from one envelope to another within
+// a month. A category ID of 0 stands for the Ready to Assign pool, so
+// moving from 0 assigns new money and moving to 0 un-assigns it.
+func (r *Repository) MoveEnvelopeFunds(month time.Time, fromID, toID, amount int64) error {
+	if amount <= 0 {
+		return errors.New("amount to move must be positive")
+	}
+	if fromID == toID {
+		return errors.New("choose two different envelopes")
+	}
+
+	em, err := r.GetEnvelopeMonth(month)
+	if err != nil {
+		return err
+	}
+	available := em.ReadyToAssign
+	if fromID != 0 {
+		env := findEnvelope(em, fromID)
+		if env == nil {
+			return ErrCategoryNotFound
+		}
+		available = env.Available
+	}
+	if toID != 0 && findEnvelope(em, toID) == nil {
+		return ErrCategoryNotFound
+	}
+	if available < amount {
+		return ErrInsufficientEnvelopeFunds
+	}
+
+	key := em.Month.Format(monthLayout)
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if fromID != 0 {
+		if err := adjustAssignment(tx, key, fromID, -amount); err != nil {
+			return err
+		}
+	}
+	if toID != 0 {
+		if err := adjustAssignment(tx, key, toID, amount); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CoverOverspending moves exactly enough from another envelope (or from
+// Ready to Assign when fromID is 0) to bring an overspent envelope back to
+// zero for the month.
+func (r *Repository) CoverOverspending(month time.Time, overspentID, fromID int64) error {
+	em, err := r.GetEnvelopeMonth(month)
+	if err != nil {
+		return err
+	}
+	env := findEnvelope(em, overspentID)
+	if env == nil {
+		return ErrCategoryNotFound
+	}
+	if env.Available >= 0 {
+		return ErrEnvelopeNotOverspent
+	}
+	return r.MoveEnvelopeFunds(month, fromID, overspentID, -env.Available)
+}
+
+// AssignBudgetedAmounts assigns each category its Monthly budget limit for
+// the month, skipping categories that already have an assignment. It
+// returns how many envelopes were filled.
+func (r *Repository) AssignBudgetedAmounts(month time.Time) (int, error) {
+	em, err := r.GetEnvelopeMonth(month)
+	if err != nil {
+		return 0, err
+	}
+	key := em.Month.Format(monthLayout)
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	filled := 0
+	for _, e := range em.Envelopes {
+		if e.Budgeted == 0 || e.Assigned != 0 {
+			continue
+		}
+		if err := adjustAssignment(tx, key, e.CategoryID, e.Budgeted); err != nil {
+			return 0, err
+		}
+		filled++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return filled, nil
+}
+
+func findEnvelope(em *model.EnvelopeMonth, categoryID int64) *model.Envelope {
+	for i := range em.Envelopes {
+		if em.Envelopes[i].CategoryID == categoryID {
+			return &em.Envelopes[i]
+		}
+	}
+	return nil
+}
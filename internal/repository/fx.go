@@ -0,0 +1,573 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+const defaultBaseCurrency = "USD"
+
+// ErrInvalidCurrency is returned for codes that are not three letters.
+var ErrInvalidCurrency = errors.New("currency code must be three letters, e.g. USD")
+
+// ErrNoExchangeRate is returned when no stored rate (direct, inverse or via
+// one intermediate currency) converts From to To.
+type ErrNoExchangeRate struct {
+	From, To string
+	Date     time.Time
+}
+
+func (e *ErrNoExchangeRate) Error() string {
+	return fmt.Sprintf("no exchange rate from %s to %s on or before %s; add one in Settings",
+		e.From, e.To, e.Date.Format(dateLayout))
+}
+
+// normalizeCurrency upper-cases a code and checks it looks like ISO 4217.
+func normalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return "", ErrInvalidCurrency
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return "", ErrInvalidCurrency
+		}
+	}
+	return code, nil
+}
+
+// endOfDay returns the exclusive upper bound for "on or before day".
+func endOfDay(day time.Time) string {
+	y, m, d := day.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC).Format(dateLayout)
+}
+
+// --- Settings ---
+
+func (r *Repository) getSetting(key, fallback string) (string, error) {
+	var value string
+	err := r.DB.QueryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return fallback, nil
+	}
+	return value, err
+}
+
+func setSetting(tx *sql.Tx, key, value string) error {
+	_, err := tx.Exec(`
+		INSERT INTO settings (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, key, value)
+	return err
+}
+
+// GetBaseCurrency returns the currency reports are valued in (USD by default).
+func (r *Repository) GetBaseCurrency() (string, error) {
+	return r.getSetting("base_currency", defaultBaseCurrency)
+}
+
+// SetBaseCurrency changes the reporting currency. Every split's stored rate
+// is re-derived against the new base on its transaction date, so it fails
+// up front if some currency in use cannot be converted. In a transaction
+// that mixes currencies, such as a transfer, only the leg that left is
+// priced at the market rate; the other legs keep their value relative to
+// it, so the transaction still balances.
+func (r *Repository) SetBaseCurrency(code string) error {
+	code, err := normalizeCurrency(code)
+	if err != nil {
+		return err
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT DISTINCT s.currency, substr(t.date, 1, 10)
+		FROM splits s JOIN transactions t ON s.transaction_id = t.id
+	`)
+	if err != nil {
+		return err
+	}
+	type key struct{ currency, day string }
+	var keys []key
+	for rows.Next() {
+		var k key
+		var currency sql.NullString
+		if err := rows.Scan(&currency, &k.day); err != nil {
+			rows.Close()
+			return err
+		}
+		k.currency = currency.String
+		if k.currency == "" {
+			k.currency = defaultBaseCurrency
+		}
+		keys = append(keys, k)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rates := make(map[key]float64, len(keys))
+	for _, k := range keys {
+		day, err := time.Parse(dateLayout, k.day)
+		if err != nil {
+			return err
+		}
+		rate, err := r.rateNear(k.currency, code, day)
+		if err != nil {
+			return err
+		}
+		rates[k] = model.MinorRate(rate, k.currency, code)
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	mixed := `SELECT transaction_id FROM splits GROUP BY transaction_id
+		HAVING COUNT(DISTINCT COALESCE(currency, '` + defaultBaseCurrency + `')) > 1`
+	for k, rate := range rates {
+		_, err := tx.Exec(`
+			UPDATE splits SET exchange_rate = ?
+			WHERE COALESCE(currency, ?) = ?
+			AND transaction_id IN (SELECT id FROM transactions WHERE substr(date, 1, 10) = ?)
+			AND transaction_id NOT IN (`+mixed+`)
+		`, rate, defaultBaseCurrency, k.currency, k.day)
+		if err != nil {
+			return err
+		}
+	}
+
+	rows, err = tx.Query(`
+		SELECT s.id, s.transaction_id, substr(t.date, 1, 10), COALESCE(s.currency, ?), s.amount, s.exchange_rate
+		FROM splits s JOIN transactions t ON s.transaction_id = t.id
+		WHERE s.transaction_id IN (`+mixed+`)
+		ORDER BY s.transaction_id, s.id
+	`, defaultBaseCurrency)
+	if err != nil {
+		return err
+	}
+	type leg struct {
+		id, txID int64
+		key      key
+		amount   int64
+		rate     float64
+	}
+	var legs []leg
+	for rows.Next() {
+		var l leg
+		if err := rows.Scan(&l.id, &l.txID, &l.key.day, &l.key.currency, &l.amount, &l.rate); err != nil {
+			rows.Close()
+			return err
+		}
+		legs = append(legs, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(legs); {
+		end := start
+		for end < len(legs) && legs[end].txID == legs[start].txID {
+			end++
+		}
+		// The first outgoing leg is the one priced at market
+		anchor := legs[start]
+		for _, l := range legs[start:end] {
+			if l.amount < 0 {
+				anchor = l
+				break
+			}
+		}
+		for _, l := range legs[start:end] {
+			rate := rates[l.key]
+			if anchor.rate > 0 {
+				rate = l.rate * rates[anchor.key] / anchor.rate
+			}
+			if _, err := tx.Exec("UPDATE splits SET exchange_rate = ? WHERE id = ?", rate, l.id); err != nil {
+				return err
+			}
+		}
+		start = end
+	}
+
+	if err := setSetting(tx, "base_currency", code); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// --- Exchange rates ---
+
+// AddExchangeRate stores a rate (1 From = Rate To) for a date, replacing
+// any rate already stored for the same pair and date.
+func (r *Repository) AddExchangeRate(rate *model.ExchangeRate) error {
+	from, err := normalizeCurrency(rate.From)
+	if err != nil {
+		return err
+	}
+	to, err := normalizeCurrency(rate.To)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return errors.New("an exchange rate needs two different currencies")
+	}
+	if rate.Rate <= 0 || math.IsInf(rate.Rate, 0) || math.IsNaN(rate.Rate) {
+		return errors.New("exchange rate must be a positive number")
+	}
+	rate.From, rate.To = from, to
+
+	res, err := r.DB.Exec(`
+		INSERT INTO exchange_rates (date, from_currency, to_currency, rate, source) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(date, from_currency, to_currency) DO UPDATE SET rate = excluded.rate, source = excluded.source
+	`, rate.Date.Format(dateLayout), from, to, rate.Rate, rate.Source)
+	if err != nil {
+		return err
+	}
+	if id, err := res.LastInsertId(); err == nil {
+		rate.ID = id
+	}
+	return nil
+}
+
+// GetExchangeRates returns the stored rate history, newest first. Empty
+// from/to match any currency.
+func (r *Repository) GetExchangeRates(from, to string) ([]model.ExchangeRate, error) {
+	query := `SELECT id, date, from_currency, to_currency, rate, source FROM exchange_rates WHERE 1=1`
+	var args []interface{}
+	if from != "" {
+		query += " AND from_currency = ?"
+		args = append(args, strings.ToUpper(from))
+	}
+	if to != "" {
+		query += " AND to_currency = ?"
+		args = append(args, strings.ToUpper(to))
+	}
+	query += " ORDER BY date DESC, from_currency, to_currency"
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []model.ExchangeRate
+	for rows.Next() {
+		var er model.ExchangeRate
+		var source sql.NullString
+		if err := rows.Scan(&er.ID, &er.Date, &er.From, &er.To, &er.Rate, &source); err != nil {
+			return nil, err
+		}
+		er.Source = source.String
+		rates = append(rates, er)
+	}
+	return rates, rows.Err()
+}
+
+// DeleteExchangeRate removes one stored rate.
+func (r *Repository) DeleteExchangeRate(id int64) error {
+	res, err := r.DB.Exec("DELETE FROM exchange_rates WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(res, errors.New("exchange rate not found"))
+}
+
+// rateBook holds the latest known rate for every currency pair.
+type rateBook map[string]map[string]float64
+
+func (b rateBook) set(from, to string, rate float64) {
+	if b[from] == nil {
+		b[from] = make(map[string]float64)
+	}
+	b[from][to] = rate
+}
+
+// rate converts from -> to using a direct quote, its inverse, or a cross
+// rate through one intermediate currency (e.g. EUR for ECB rates).
+func (b rateBook) rate(from, to string) (float64, bool) {
+	if from == to {
+		return 1, true
+	}
+	if r, ok := b[from][to]; ok {
+		return r, true
+	}
+	pivots := make([]string, 0, len(b[from]))
+	for pivot := range b[from] {
+		pivots = append(pivots, pivot)
+	}
+	sort.Strings(pivots) // Deterministic when several crosses exist
+	for _, pivot := range pivots {
+		if r2, ok := b[pivot][to]; ok {
+			return b[from][pivot] * r2, true
+		}
+	}
+	return 0, false
+}
+
+// loadRateBook builds a rateBook from the latest quote for each pair dated
+// on or before asOf. Each quote also supplies its inverse; for a pair quoted
+// both ways the more recent quote wins.
+func (r *Repository) loadRateBook(asOf time.Time) (rateBook, error) {
+	// SQLite returns the bare columns from the row holding MAX(date)
+	rows, err := r.DB.Query(`
+		SELECT from_currency, to_currency, rate, MAX(date) AS latest
+		FROM exchange_rates
+		WHERE date < ?
+		GROUP BY from_currency, to_currency
+		ORDER BY latest, from_currency, to_currency
+	`, endOfDay(asOf))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	book := make(rateBook)
+	for rows.Next() {
+		var from, to string
+		var rate float64
+		var latest interface{}
+		if err := rows.Scan(&from, &to, &rate, &latest); err != nil {
+			return nil, err
+		}
+		// Later dates overwrite earlier ones
+		book.set(from, to, rate)
+		book.set(to, from, 1/rate)
+	}
+	return book, rows.Err()
+}
+
+// RateAsOf returns how many units of to one unit of from was worth on asOf,
+// using the most recent stored rate on or before that date.
+func (r *Repository) RateAsOf(from, to string, asOf time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	book, err := r.loadRateBook(asOf)
+	if err != nil {
+		return 0, err
+	}
+	if rate, ok := book.rate(from, to); ok {
+		return rate, nil
+	}
+	return 0, &ErrNoExchangeRate{From: from, To: to, Date: asOf}
+}
+
+// rateNear is RateAsOf, falling back to the latest rate on file when the
+// date predates the rate history. It is used to stamp new transactions.
+func (r *Repository) rateNear(from, to string, day time.Time) (float64, error) {
+	rate, err := r.RateAsOf(from, to, day)
+	var noRate *ErrNoExchangeRate
+	if errors.As(err, &noRate) {
+		if latest, lerr := r.RateAsOf(from, to, time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)); lerr == nil {
+			return latest, nil
+		}
+		return 0, &ErrNoExchangeRate{From: from, To: to, Date: day}
+	}
+	return rate, err
+}
+
+// fillSplitRates defaults blank split currencies to the transaction's
+// currency (that of its first balance-sheet account, or the base currency)
+// and, where no rate was given, stamps the rate to base on the transaction
+// date. Stamped rates convert the split's minor units straight into base
+// minor units, so SUM(amount * exchange_rate) is already in base cents.
+func (r *Repository) fillSplitRates(t *model.Transaction) error {
+	base, err := r.GetBaseCurrency()
+	if err != nil {
+		return err
+	}
+	currency := ""
+	for i := range t.Splits {
+		s := &t.Splits[i]
+		if s.Currency == "" {
+			if currency == "" {
+				if currency, err = r.transactionCurrency(t, base); err != nil {
+					return err
+				}
+			}
+			s.Currency = currency
+		}
+		if s.ExchangeRate > 0 {
+			continue
+		}
+		rate, err := r.rateNear(s.Currency, base, t.Date)
+		if err != nil {
+			return err
+		}
+		s.ExchangeRate = model.MinorRate(rate, s.Currency, base)
+	}
+	return nil
+}
+
+// transactionCurrency picks the currency the transaction was made in: that of
+// the first asset or liability account it touches, else base.
+func (r *Repository) transactionCurrency(t *model.Transaction, base string) (string, error) {
+	for _, s := range t.Splits {
+		acc, err := r.GetAccountByID(s.AccountID)
+		if err != nil {
+			return "", err
+		}
+		if acc != nil && isBalanceSheet(acc.Type) && acc.Currency != "" {
+			return normalizeCurrency(acc.Currency)
+		}
+	}
+	return base, nil
+}
+
+// --- Valuation ---
+
+// isBalanceSheet reports whether an account type holds a balance (as
+// opposed to Income/Expense, which only record flows).
+func isBalanceSheet(t model.AccountType) bool {
+	return t != model.AccountTypeIncome && t != model.AccountTypeExpense
+}
+
+// isLiability reports whether an account type is credit-normal debt.
+func isLiability(t model.AccountType) bool {
+	return t == model.AccountTypeLiability || t == model.AccountTypeCard
+}
+
+// GetAccountValuations values every balance-sheet account with activity in
+// the base currency as of asOf. Balance is in the account's own currency;
+// BaseValue uses the asOf rate, CostBasis the rates stamped on each split,
+// and UnrealizedFX is their difference. An account holding a currency
+// with no rate is still listed, with MissingRate set and the amounts that
+// needed the rate left out.
+func (r *Repository) GetAccountValuations(asOf time.Time) ([]model.AccountValuation, error) {
+	base, err := r.GetBaseCurrency()
+	if err != nil {
+		return nil, err
+	}
+	book, err := r.loadRateBook(asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT a.id, a.name, a.type, a.currency, s.currency, SUM(s.amount), SUM(s.amount * s.exchange_rate)
+		FROM splits s
+		JOIN transactions t ON s.transaction_id = t.id
+		JOIN accounts a ON s.account_id = a.id
+		WHERE t.date < ?
+		GROUP BY a.id, s.currency
+		ORDER BY a.name
+	`, endOfDay(asOf))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vals []model.AccountValuation
+	index := make(map[int64]int)
+	for rows.Next() {
+		var v model.AccountValuation
+		var accCurrency, splitCurrency sql.NullString
+		var amount int64
+		var cost float64
+		if err := rows.Scan(&v.AccountID, &v.AccountName, &v.Type, &accCurrency, &splitCurrency, &amount, &cost); err != nil {
+			return nil, err
+		}
+		if !isBalanceSheet(v.Type) {
+			continue
+		}
+		v.Currency = accCurrency.String
+		if v.Currency == "" {
+			v.Currency = defaultBaseCurrency
+		}
+		cur := splitCurrency.String
+		if cur == "" {
+			cur = defaultBaseCurrency
+		}
+
+		i, seen := index[v.AccountID]
+		if !seen {
+			i = len(vals)
+			index[v.AccountID] = i
+			accRate, ok := book.rate(v.Currency, base)
+			v.Rate, v.MissingRate = accRate, !ok
+			vals = append(vals, v)
+		}
+		vals[i].CostBasis += int64(math.Round(cost))
+
+		// A missing rate only affects the values that need it
+		if toAccount, ok := book.rate(cur, v.Currency); ok {
+			inAccount, err := model.NewMoney(amount, cur).Convert(toAccount, v.Currency)
+			if err != nil {
+				return nil, err
+			}
+			vals[i].Balance += inAccount.Amount
+		} else {
+			vals[i].MissingRate = true
+		}
+		if toBase, ok := book.rate(cur, base); ok {
+			inBase, err := model.NewMoney(amount, cur).Convert(toBase, base)
+			if err != nil {
+				return nil, err
+			}
+			vals[i].BaseValue += inBase.Amount
+		} else {
+			vals[i].MissingRate = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range vals {
+		if !vals[i].MissingRate {
+			vals[i].UnrealizedFX = vals[i].BaseValue - vals[i].CostBasis
+		}
+	}
+	return vals, nil
+}
+
+// GetFXGainLoss reports unrealized exchange gains (positive) or losses on
+// accounts held in a currency other than the base currency. A debt that
+// grew in base terms shows as a loss.
+func (r *Repository) GetFXGainLoss(asOf time.Time) ([]model.AccountValuation, error) {
+	base, err := r.GetBaseCurrency()
+	if err != nil {
+		return nil, err
+	}
+	vals, err := r.GetAccountValuations(asOf)
+	if err != nil {
+		return nil, err
+	}
+	var foreign []model.AccountValuation
+	for _, v := range vals {
+		if v.Currency != base {
+			foreign = append(foreign, v)
+		}
+	}
+	return foreign, nil
+}
+
+// netWorthAt sums valuations into assets and liabilities (both in cents,
+// liabilities as a positive amount owed).
+func (r *Repository) netWorthAt(asOf time.Time) (assets, liabilities int64, err error) {
+	vals, err := r.GetAccountValuations(asOf)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, v := range vals {
+		switch {
+		case isLiability(v.Type):
+			liabilities -= v.BaseValue
+		case v.Type == model.AccountTypeEquity:
+			// Opening balances and the like are not part of net worth
+		default:
+			assets += v.BaseValue
+		}
+	}
+	return assets, liabilities, nil
+}
@@ -0,0 +1,691 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+// migration is a single, ordered step in the schema history.
+// Versions must be unique and strictly increasing; once a migration has
+// shipped it must never be edited, only followed by a new one.
+type migration struct {
+	Version     int
+	Description string
+	SQL         string
+}
+
+// migration7Exponents lists the currencies without two-decimal minor
+// units, as migration 7 rescaled them. The migration keeps its own copy
+// instead of reading model.Currencies so that it does the same thing on
+// every database even if the registry changes later; a test checks the
+// two still agree.
+var migration7Exponents = map[int][]string{
+	0: {"BIF", "CLP", "DJF", "GNF", "ISK", "JPY", "KMF", "KRW", "PYG", "RWF", "UGX", "VND", "VUV", "XAF", "XOF", "XPF"},
+	3: {"BHD", "IQD", "JOD", "KWD", "LYD", "OMR", "TND"},
+	4: {"CLF", "UYW"},
+}
+
+// migration7Scale returns SQL giving the minor units per major unit of the
+// currency code expr, using migration7Exponents.
+func migration7Scale(expr string) string {
+	cases := "CASE"
+	for _, exp := range []int{0, 3, 4} {
+		cases += fmt.Sprintf(" WHEN %s IN ('%s') THEN %.1f", expr,
+			strings.Join(migration7Exponents[exp], "','"), math.Pow10(exp))
+	}
+	return cases + " ELSE 100.0 END"
+}
+
+// migrations is the full schema history, applied in order by migrate.
+var migrations = []migration{
+	{
+		Version:     1,
+		Description: "initial schema",
+		// Uses IF NOT EXISTS so databases created before versioning
+		// (which already have these tables) adopt version 1 cleanly.
+		SQL: `
+	CREATE TABLE IF NOT EXISTS accounts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		type TEXT NOT NULL,
+		currency TEXT DEFAULT 'USD',
+		is_closed BOOLEAN DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS categories (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		icon TEXT,
+		color TEXT,
+		parent_id INTEGER,
+		FOREIGN KEY(parent_id) REFERENCES categories(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS transactions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		date DATETIME NOT NULL,
+		description TEXT NOT NULL,
+		note TEXT,
+		status TEXT DEFAULT 'Pending',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS splits (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		transaction_id INTEGER NOT NULL,
+		account_id INTEGER NOT NULL,
+		category_id INTEGER,
+		amount INTEGER NOT NULL, -- Stored in minor units (cents)
+		currency TEXT DEFAULT 'USD',
+		exchange_rate REAL DEFAULT 1.0,
+		FOREIGN KEY(transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
+		FOREIGN KEY(account_id) REFERENCES accounts(id),
+		FOREIGN KEY(category_id) REFERENCES categories(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS budgets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		category_id INTEGER NOT NULL,
+		amount INTEGER NOT NULL,
+		period TEXT DEFAULT 'Monthly',
+		FOREIGN KEY(category_id) REFERENCES categories(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		pattern TEXT NOT NULL,
+		target_category_id INTEGER,
+		target_payee TEXT,
+		target_note TEXT,
+		FOREIGN KEY(target_category_id) REFERENCES categories(id)
+	);
+	`,
+	},
+	{
+		Version:     2,
+		Description: "effective-dated budgets with period types",
+		// Older builds inserted a new row on every save; keep only the latest per category.
+		SQL: `
+	ALTER TABLE budgets ADD COLUMN effective_from DATE NOT NULL DEFAULT '1970-01-01';
+	ALTER TABLE budgets ADD COLUMN end_date DATE;
+	DELETE FROM budgets WHERE id NOT IN (SELECT MAX(id) FROM budgets GROUP BY category_id);
+	CREATE INDEX IF NOT EXISTS idx_budgets_category_effective ON budgets(category_id, effective_from);
+	`,
+	},
+	{
+		Version:     3,
+		Description: "budget rollover ledger",
+		SQL: `
+	ALTER TABLE budgets ADD COLUMN rollover BOOLEAN NOT NULL DEFAULT 0;
+
+	CREATE TABLE budget_rollovers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		category_id INTEGER NOT NULL,
+		budget_id INTEGER NOT NULL,
+		period_start DATE NOT NULL,
+		period_end DATE NOT NULL, -- Inclusive
+		budgeted INTEGER NOT NULL,
+		spent INTEGER NOT NULL,
+		carried_in INTEGER NOT NULL,
+		carried_out INTEGER NOT NULL,
+		computed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(category_id) REFERENCES categories(id),
+		FOREIGN KEY(budget_id) REFERENCES budgets(id)
+	);
+	CREATE INDEX idx_budget_rollovers_category ON budget_rollovers(category_id, period_end);
+	`,
+	},
+	{
+		Version:     4,
+		Description: "envelope budgeting assignments",
+		SQL: `
+	CREATE TABLE envelope_assignments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		month TEXT NOT NULL, -- YYYY-MM
+		category_id INTEGER NOT NULL,
+		amount INTEGER NOT NULL DEFAULT 0,
+		UNIQUE(month, category_id),
+		FOREIGN KEY(category_id) REFERENCES categories(id)
+	);
+	`,
+	},
+	{
+		Version:     5,
+		Description: "statement reconciliation",
+		SQL: `
+	CREATE TABLE reconciliations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		account_id INTEGER NOT NULL,
+		statement_date DATE NOT NULL,
+		statement_balance INTEGER NOT NULL,
+		cleared_balance INTEGER,
+		started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		completed_at DATETIME, -- NULL while the session is open
+		FOREIGN KEY(account_id) REFERENCES accounts(id)
+	);
+	CREATE INDEX idx_reconciliations_account ON reconciliations(account_id);
+
+	ALTER TABLE transactions ADD COLUMN reconciliation_id INTEGER REFERENCES reconciliations(id);
+	`,
+	},
+	{
+		Version:     6,
+		Description: "settings and exchange rate history",
+		SQL: `
+	CREATE TABLE settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);
+
+	CREATE TABLE exchange_rates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		date DATE NOT NULL,
+		from_currency TEXT NOT NULL,
+		to_currency TEXT NOT NULL,
+		rate REAL NOT NULL, -- 1 from_currency = rate to_currency
+		source TEXT,
+		UNIQUE(date, from_currency, to_currency)
+	);
+	CREATE INDEX idx_exchange_rates_pair ON exchange_rates(from_currency, to_currency, date);
+	`,
+	},
+	{
+		Version:     7,
+		Description: "split exchange rates in minor units",
+		// Rates were stored per major unit; rescale them to base-currency
+		// minor units per split minor unit so currencies without two
+		// decimals (JPY, KWD, ...) value correctly.
+		SQL: `
+	UPDATE splits SET exchange_rate = exchange_rate
+		* (SELECT ` + migration7Scale("code") + `
+		FROM (SELECT COALESCE((SELECT value FROM settings WHERE key = 'base_currency'), 'USD') AS code))
+		/ (` + migration7Scale("COALESCE(currency, 'USD')") + `)
+	WHERE exchange_rate IS NOT NULL;
+	`,
+	},
+	{
+		Version:     8,
+		Description: "recurring transaction schedules",
+		SQL: `
+	CREATE TABLE recurring_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		note TEXT,
+		frequency TEXT NOT NULL,
+		interval_days INTEGER NOT NULL DEFAULT 0, -- Every N Days only
+		start_date DATE NOT NULL,
+		end_date DATE,
+		next_index INTEGER NOT NULL DEFAULT 0, -- Occurrences posted or skipped
+		auto_post BOOLEAN NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE recurring_splits (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		rule_id INTEGER NOT NULL,
+		account_id INTEGER NOT NULL,
+		category_id INTEGER,
+		amount INTEGER NOT NULL,
+		currency TEXT,
+		FOREIGN KEY(rule_id) REFERENCES recurring_rules(id) ON DELETE CASCADE,
+		FOREIGN KEY(account_id) REFERENCES accounts(id),
+		FOREIGN KEY(category_id) REFERENCES categories(id)
+	);
+
+	CREATE TABLE recurring_overrides (
+		rule_id INTEGER NOT NULL,
+		occurrence_index INTEGER NOT NULL,
+		skip BOOLEAN NOT NULL DEFAULT 0,
+		date DATE,
+		amount INTEGER,
+		note TEXT,
+		PRIMARY KEY(rule_id, occurrence_index),
+		FOREIGN KEY(rule_id) REFERENCES recurring_rules(id) ON DELETE CASCADE
+	);
+	`,
+	},
+	{
+		Version:     9,
+		Description: "dismissed anomalies",
+		SQL: `
+	CREATE TABLE anomaly_dismissals (
+		anomaly_key TEXT PRIMARY KEY, -- model.Anomaly.Key
+		dismissed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`,
+	},
+	{
+		Version:     10,
+		Description: "pairs marked as not duplicates",
+		SQL: `
+	CREATE TABLE duplicate_dismissals (
+		transaction_a INTEGER NOT NULL, -- the lower ID of the pair
+		transaction_b INTEGER NOT NULL,
+		dismissed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (transaction_a, transaction_b),
+		FOREIGN KEY(transaction_a) REFERENCES transactions(id) ON DELETE CASCADE,
+		FOREIGN KEY(transaction_b) REFERENCES transactions(id) ON DELETE CASCADE
+	);
+	`,
+	},
+	{
+		Version:     11,
+		Description: "rule conditions and actions, tags, undoable rule batches",
+		SQL: `
+	ALTER TABLE rules ADD COLUMN name TEXT NOT NULL DEFAULT '';
+	ALTER TABLE rules ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE rules ADD COLUMN is_regex BOOLEAN NOT NULL DEFAULT 0;
+	ALTER TABLE rules ADD COLUMN min_amount INTEGER;
+	ALTER TABLE rules ADD COLUMN max_amount INTEGER;
+	ALTER TABLE rules ADD COLUMN account_id INTEGER REFERENCES accounts(id);
+	ALTER TABLE rules ADD COLUMN start_date DATE;
+	ALTER TABLE rules ADD COLUMN end_date DATE;
+	ALTER TABLE rules ADD COLUMN target_tags TEXT NOT NULL DEFAULT ''; -- comma-separated
+	ALTER TABLE rules ADD COLUMN target_status TEXT NOT NULL DEFAULT '';
+	-- Existing rules keep the order they were matched in
+	UPDATE rules SET priority = id;
+
+	CREATE TABLE tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE COLLATE NOCASE
+	);
+
+	CREATE TABLE transaction_tags (
+		transaction_id INTEGER NOT NULL,
+		tag_id INTEGER NOT NULL,
+		PRIMARY KEY (transaction_id, tag_id),
+		FOREIGN KEY(transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
+		FOREIGN KEY(tag_id) REFERENCES tags(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE rule_batches (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		rule_id INTEGER, -- NULL once the rule is deleted
+		rule_name TEXT NOT NULL,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		undone_at DATETIME,
+		FOREIGN KEY(rule_id) REFERENCES rules(id) ON DELETE SET NULL
+	);
+
+	-- What each transaction looked like before a batch changed it
+	CREATE TABLE rule_batch_changes (
+		batch_id INTEGER NOT NULL,
+		transaction_id INTEGER NOT NULL,
+		description TEXT NOT NULL,
+		note TEXT,
+		status TEXT,
+		split_id INTEGER, -- The split whose category changed, if any
+		category_id INTEGER,
+		tags TEXT NOT NULL DEFAULT '', -- comma-separated
+		FOREIGN KEY(batch_id) REFERENCES rule_batches(id) ON DELETE CASCADE,
+		FOREIGN KEY(transaction_id) REFERENCES transactions(id) ON DELETE CASCADE
+	);
+	`,
+	},
+	{
+		Version:     12,
+		Description: "payees with aliases and defaults",
+		SQL: `
+	CREATE TABLE payees (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		name_key TEXT NOT NULL UNIQUE, -- NormalizePayee(name)
+		default_category_id INTEGER,
+		default_account_id INTEGER,
+		FOREIGN KEY(default_category_id) REFERENCES categories(id) ON DELETE SET NULL,
+		FOREIGN KEY(default_account_id) REFERENCES accounts(id) ON DELETE SET NULL
+	);
+
+	CREATE TABLE payee_aliases (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		payee_id INTEGER NOT NULL,
+		alias TEXT NOT NULL,
+		alias_key TEXT NOT NULL UNIQUE, -- NormalizePayee(alias)
+		FOREIGN KEY(payee_id) REFERENCES payees(id) ON DELETE CASCADE
+	);
+
+	-- Existing transactions are linked by LinkPayees on the next start
+	ALTER TABLE transactions ADD COLUMN payee_id INTEGER REFERENCES payees(id) ON DELETE SET NULL;
+	CREATE INDEX idx_transactions_payee ON transactions(payee_id);
+	`,
+	},
+	{
+		Version:     13,
+		Description: "split tags",
+		SQL: `
+	CREATE TABLE split_tags (
+		split_id INTEGER NOT NULL,
+		tag_id INTEGER NOT NULL,
+		PRIMARY KEY (split_id, tag_id),
+		FOREIGN KEY(split_id) REFERENCES splits(id) ON DELETE CASCADE,
+		FOREIGN KEY(tag_id) REFERENCES tags(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX idx_transaction_tags_tag ON transaction_tags(tag_id);
+	CREATE INDEX idx_split_tags_tag ON split_tags(tag_id);
+	`,
+	},
+	{
+		Version:     14,
+		Description: "transaction attachments",
+		SQL: `
+	CREATE TABLE attachment_files (
+		hash TEXT PRIMARY KEY, -- Hex SHA-256 of data
+		data BLOB NOT NULL,
+		size INTEGER NOT NULL
+	);
+
+	CREATE TABLE attachments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		transaction_id INTEGER NOT NULL,
+		hash TEXT NOT NULL,
+		name TEXT NOT NULL,
+		mime_type TEXT NOT NULL,
+		added_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(transaction_id, hash),
+		FOREIGN KEY(transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
+		FOREIGN KEY(hash) REFERENCES attachment_files(hash)
+	);
+
+	CREATE INDEX idx_attachments_hash ON attachments(hash);
+	`,
+	},
+	{
+		Version:     15,
+		Description: "full-text transaction search",
+		SQL: `
+	-- One row per transaction (rowid = transactions.id), rebuilt from
+	-- transaction_search_docs by the triggers below
+	CREATE VIRTUAL TABLE transactions_fts USING fts5(
+		description, note, payee, tags, categories,
+		tokenize = 'unicode61 remove_diacritics 2'
+	);
+
+	CREATE VIEW transaction_search_docs AS
+	SELECT t.id,
+		t.description,
+		COALESCE(t.note, '') AS note,
+		COALESCE(p.name, '') AS payee,
+		COALESCE((SELECT group_concat(g.name, ' ') FROM tags g
+			WHERE g.id IN (SELECT tag_id FROM transaction_tags WHERE transaction_id = t.id)
+			OR g.id IN (SELECT st.tag_id FROM split_tags st JOIN splits x ON x.id = st.split_id WHERE x.transaction_id = t.id)), '') AS tags,
+		COALESCE((SELECT group_concat(c.name, ' ') FROM categories c
+			WHERE c.id IN (SELECT category_id FROM splits WHERE transaction_id = t.id)), '') AS categories
+	FROM transactions t
+	LEFT JOIN payees p ON p.id = t.payee_id;
+
+	INSERT INTO transactions_fts (rowid, description, note, payee, tags, categories)
+	SELECT * FROM transaction_search_docs;
+
+	CREATE TRIGGER transactions_fts_insert AFTER INSERT ON transactions BEGIN
+		INSERT INTO transactions_fts (rowid, description, note, payee, tags, categories)
+		SELECT * FROM transaction_search_docs WHERE id = NEW.id;
+	END;
+
+	CREATE TRIGGER transactions_fts_update AFTER UPDATE OF description, note, payee_id ON transactions BEGIN
+		DELETE FROM transactions_fts WHERE rowid = NEW.id;
+		INSERT INTO transactions_fts (rowid, description, note, payee, tags, categories)
+		SELECT * FROM transaction_search_docs WHERE id = NEW.id;
+	END;
+
+	CREATE TRIGGER transactions_fts_delete AFTER DELETE ON transactions BEGIN
+		DELETE FROM transactions_fts WHERE rowid = OLD.id;
+	END;
+
+	CREATE TRIGGER splits_fts_insert AFTER INSERT ON splits BEGIN
+		DELETE FROM transactions_fts WHERE rowid = NEW.transaction_id;
+		INSERT INTO transactions_fts (rowid, description, note, payee, tags, categories)
+		SELECT * FROM transaction_search_docs WHERE id = NEW.transaction_id;
+	END;
+
+	CREATE TRIGGER splits_fts_update AFTER UPDATE OF category_id ON splits BEGIN
+		DELETE FROM transactions_fts WHERE rowid = NEW.transaction_id;
+		INSERT INTO transactions_fts (rowid, description, note, payee, tags, categories)
+		SELECT * FROM transaction_search_docs WHERE id = NEW.transaction_id;
+	END;
+
+	CREATE TRIGGER splits_fts_delete AFTER DELETE ON splits BEGIN
+		DELETE FROM transactions_fts WHERE rowid = OLD.transaction_id;
+		INSERT INTO transactions_fts (rowid, description, note, payee, tags, categories)
+		SELECT * FROM transaction_search_docs WHERE id = OLD.transaction_id;
+	END;
+
+	CREATE TRIGGER transaction_tags_fts_insert AFTER INSERT ON transaction_tags BEGIN
+		DELETE FROM transactions_fts WHERE rowid = NEW.transaction_id;
+		INSERT INTO transactions_fts (rowid, description, note, payee, tags, categories)
+		SELECT * FROM transaction_search_docs WHERE id = NEW.transaction_id;
+	END;
+
+	CREATE TRIGGER transaction_tags_fts_delete AFTER DELETE ON transaction_tags BEGIN
+		DELETE FROM transactions_fts WHERE rowid = OLD.transaction_id;
+		INSERT INTO transactions_fts (rowid, description, note, payee, tags, categories)
+		SELECT * FROM transaction_search_docs WHERE id = OLD.transaction_id;
+	END;
+
+	CREATE TRIGGER split_tags_fts_insert AFTER INSERT ON split_tags BEGIN
+		DELETE FROM transactions_fts WHERE rowid = (SELECT transaction_id FROM splits WHERE id = NEW.split_id);
+		INSERT INTO transactions_fts (rowid, description, note, payee, tags, categories)
+		SELECT * FROM transaction_search_docs WHERE id = (SELECT transaction_id FROM splits WHERE id = NEW.split_id);
+	END;
+
+	CREATE TRIGGER split_tags_fts_delete AFTER DELETE ON split_tags BEGIN
+		DELETE FROM transactions_fts WHERE rowid = (SELECT transaction_id FROM splits WHERE id = OLD.split_id);
+		INSERT INTO transactions_fts (rowid, description, note, payee, tags, categories)
+		SELECT * FROM transaction_search_docs WHERE id = (SELECT transaction_id FROM splits WHERE id = OLD.split_id);
+	END;
+
+	-- Renames reach every transaction using the payee, category or tag
+	CREATE TRIGGER payees_fts_update AFTER UPDATE OF name ON payees BEGIN
+		DELETE FROM transactions_fts WHERE rowid IN (SELECT id FROM transactions WHERE payee_id = NEW.id);
+		INSERT INTO transactions_fts (rowid, description, note, payee, tags, categories)
+		SELECT * FROM transaction_search_docs WHERE id IN (SELECT id FROM transactions WHERE payee_id = NEW.id);
+	END;
+
+	CREATE TRIGGER categories_fts_update AFTER UPDATE OF name ON categories BEGIN
+		DELETE FROM transactions_fts WHERE rowid IN (SELECT transaction_id FROM splits WHERE category_id = NEW.id);
+		INSERT INTO transactions_fts (rowid, description, note, payee, tags, categories)
+		SELECT * FROM transaction_search_docs WHERE id IN (SELECT transaction_id FROM splits WHERE category_id = NEW.id);
+	END;
+
+	CREATE TRIGGER tags_fts_update AFTER UPDATE OF name ON tags BEGIN
+		DELETE FROM transactions_fts WHERE rowid IN (
+			SELECT transaction_id FROM transaction_tags WHERE tag_id = NEW.id
+			UNION SELECT x.transaction_id FROM split_tags st JOIN splits x ON x.id = st.split_id WHERE st.tag_id = NEW.id);
+		INSERT INTO transactions_fts (rowid, description, note, payee, tags, categories)
+		SELECT * FROM transaction_search_docs WHERE id IN (
+			SELECT transaction_id FROM transaction_tags WHERE tag_id = NEW.id
+			UNION SELECT x.transaction_id FROM split_tags st JOIN splits x ON x.id = st.split_id WHERE st.tag_id = NEW.id);
+	END;
+	`,
+	},
+	{
+		Version:     16,
+		Description: "indexes for large ledgers",
+		SQL: `
+	CREATE INDEX IF NOT EXISTS idx_splits_transaction ON splits(transaction_id);
+	CREATE INDEX IF NOT EXISTS idx_splits_account ON splits(account_id);
+	-- Serves keyset pages ordered by (date, id); id is the rowid
+	CREATE INDEX IF NOT EXISTS idx_transactions_date ON transactions(date);
+	`,
+	},
+	{
+		Version:     17,
+		Description: "remove rows orphaned while foreign keys were not enforced",
+		SQL: `
+	-- Earlier builds enabled foreign keys on only one pooled connection, so
+	-- deletes on the others skipped ON DELETE CASCADE and SET NULL
+	DELETE FROM splits WHERE transaction_id NOT IN (SELECT id FROM transactions);
+	DELETE FROM recurring_splits WHERE rule_id NOT IN (SELECT id FROM recurring_rules);
+	DELETE FROM recurring_overrides WHERE rule_id NOT IN (SELECT id FROM recurring_rules);
+	DELETE FROM duplicate_dismissals
+	WHERE transaction_a NOT IN (SELECT id FROM transactions) OR transaction_b NOT IN (SELECT id FROM transactions);
+	DELETE FROM transaction_tags
+	WHERE transaction_id NOT IN (SELECT id FROM transactions) OR tag_id NOT IN (SELECT id FROM tags);
+	DELETE FROM split_tags WHERE split_id NOT IN (SELECT id FROM splits) OR tag_id NOT IN (SELECT id FROM tags);
+	DELETE FROM rule_batch_changes
+	WHERE batch_id NOT IN (SELECT id FROM rule_batches) OR transaction_id NOT IN (SELECT id FROM transactions);
+	UPDATE rule_batches SET rule_id = NULL WHERE rule_id NOT IN (SELECT id FROM rules);
+	DELETE FROM payee_aliases WHERE payee_id NOT IN (SELECT id FROM payees);
+	UPDATE payees SET default_category_id = NULL WHERE default_category_id NOT IN (SELECT id FROM categories);
+	UPDATE payees SET default_account_id = NULL WHERE default_account_id NOT IN (SELECT id FROM accounts);
+	UPDATE transactions SET payee_id = NULL WHERE payee_id NOT IN (SELECT id FROM payees);
+	DELETE FROM attachments WHERE transaction_id NOT IN (SELECT id FROM transactions);
+	DELETE FROM attachment_files WHERE hash NOT IN (SELECT hash FROM attachments);
+	`,
+	},
+	{
+		Version:     18,
+		Description: "cleared and reconciled state per split",
+		SQL: `
+	ALTER TABLE splits ADD COLUMN status TEXT NOT NULL DEFAULT 'Pending';
+	ALTER TABLE splits ADD COLUMN reconciliation_id INTEGER REFERENCES reconciliations(id);
+	CREATE INDEX IF NOT EXISTS idx_splits_reconciliation ON splits(reconciliation_id);
+
+	-- Only the leg in the reconciled account was on the statement; the
+	-- other legs of a cleared or reconciled transaction stay cleared
+	UPDATE splits SET status = 'Reconciled', reconciliation_id = (
+		SELECT t.reconciliation_id FROM transactions t WHERE t.id = splits.transaction_id)
+	WHERE account_id = (
+		SELECT r.account_id FROM transactions t JOIN reconciliations r ON r.id = t.reconciliation_id
+		WHERE t.id = splits.transaction_id);
+	UPDATE splits SET status = 'Reconciled'
+	WHERE status = 'Pending' AND transaction_id IN (
+		SELECT id FROM transactions WHERE status = 'Reconciled' AND reconciliation_id IS NULL);
+	UPDATE splits SET status = 'Cleared'
+	WHERE status = 'Pending' AND transaction_id IN (SELECT id FROM transactions WHERE status IN ('Cleared', 'Reconciled'));
+	`,
+	},
+}
+
+// ErrDatabaseTooNew is returned when the database was written by a newer
+// build of the app than the one currently running.
+type ErrDatabaseTooNew struct {
+	DBVersion     int
+	LatestVersion int
+}
+
+func (e *ErrDatabaseTooNew) Error() string {
+	return fmt.Sprintf("database schema is version %d but this build only supports up to version %d; please upgrade MyTrack",
+		e.DBVersion, e.LatestVersion)
+}
+
+// latestVersion returns the highest known migration version.
+func latestVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// migrate brings the database schema up to date.
+// Before any pending migration runs on an existing database, a copy of the
+// file is written next to it so a failed or unwanted upgrade can be undone.
+func migrate(db *sql.DB, dbPath string) error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		description TEXT,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`)
+	if err != nil {
+		return err
+	}
+
+	current, err := currentVersion(db)
+	if err != nil {
+		return err
+	}
+
+	latest := latestVersion()
+	if current > latest {
+		return &ErrDatabaseTooNew{DBVersion: current, LatestVersion: latest}
+	}
+	if current == latest {
+		return nil
+	}
+
+	hasData, err := hasUserTables(db)
+	if err != nil {
+		return err
+	}
+	if hasData {
+		backupPath, err := backupBeforeMigration(db, dbPath, current)
+		if err != nil {
+			return fmt.Errorf("pre-migration backup failed: %w", err)
+		}
+		if backupPath != "" {
+			log.Printf("Backed up database to %s before migrating", backupPath)
+		}
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+		}
+		log.Printf("Applied migration %d: %s", m.Version, m.Description)
+	}
+
+	return nil
+}
+
+func currentVersion(db *sql.DB) (int, error) {
+	var version sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, err
+	}
+	if !version.Valid {
+		return 0, nil
+	}
+	return int(version.Int64), nil
+}
+
+// hasUserTables reports whether the database holds anything besides the
+// migration bookkeeping table, i.e. whether there is data worth backing up.
+func hasUserTables(db *sql.DB) (bool, error) {
+	var count int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name != 'schema_migrations'
+	`).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// backupBeforeMigration writes a consistent copy of the database using
+// VACUUM INTO. In-memory databases are skipped and return an empty path.
+func backupBeforeMigration(db *sql.DB, dbPath string, fromVersion int) (string, error) {
+	if dbPath == "" || dbPath == ":memory:" {
+		return "", nil
+	}
+	backupPath := fmt.Sprintf("%s.v%d-%s.bak", dbPath, fromVersion, time.Now().Format("20060102-150405"))
+	if _, err := db.Exec("VACUUM INTO ?", backupPath); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
+
+// applyMigration runs one migration and records it atomically.
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, description) VALUES (?, ?)", m.Version, m.Description); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+// baselineSchema is the schema createSchema used to build before
+// migrations existed.
+const baselineSchema = `
+	CREATE TABLE accounts (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, type TEXT NOT NULL,
+		currency TEXT DEFAULT 'USD', is_closed BOOLEAN DEFAULT 0);
+	CREATE TABLE categories (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, icon TEXT, color TEXT,
+		parent_id INTEGER, FOREIGN KEY(parent_id) REFERENCES categories(id));
+	CREATE TABLE transactions (id INTEGER PRIMARY KEY AUTOINCREMENT, date DATETIME NOT NULL, description TEXT NOT NULL,
+		note TEXT, status TEXT DEFAULT 'Pending', created_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+	CREATE TABLE splits (id INTEGER PRIMARY KEY AUTOINCREMENT, transaction_id INTEGER NOT NULL, account_id INTEGER NOT NULL,
+		category_id INTEGER, amount INTEGER NOT NULL, currency TEXT DEFAULT 'USD', exchange_rate REAL DEFAULT 1.0,
+		FOREIGN KEY(transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
+		FOREIGN KEY(account_id) REFERENCES accounts(id), FOREIGN KEY(category_id) REFERENCES categories(id));
+	CREATE TABLE budgets (id INTEGER PRIMARY KEY AUTOINCREMENT, category_id INTEGER NOT NULL, amount INTEGER NOT NULL,
+		period TEXT DEFAULT 'Monthly', FOREIGN KEY(category_id) REFERENCES categories(id));
+	CREATE TABLE rules (id INTEGER PRIMARY KEY AUTOINCREMENT, pattern TEXT NOT NULL, target_category_id INTEGER,
+		target_payee TEXT, target_note TEXT, FOREIGN KEY(target_category_id) REFERENCES categories(id));
+
+	INSERT INTO accounts (id, name, type) VALUES (1, 'Cash', 'Cash'), (2, 'General Expenses', 'Expense');
+	INSERT INTO categories (id, name) VALUES (1, 'Food');
+	INSERT INTO transactions (id, date, description) VALUES (1, '2024-05-03 12:00:00', 'Corner Coffee');
+	INSERT INTO splits (transaction_id, account_id, category_id, amount) VALUES (1, 1, NULL, -450), (1, 2, 1, 450);
+	INSERT INTO budgets (category_id, amount) VALUES (1, 10000);
+`
+
+func TestMigrateBaselineDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.db")
+	old, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec(baselineSchema); err != nil {
+		t.Fatal(err)
+	}
+	old.Close()
+
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	version, err := currentVersion(db.DB)
+	if err != nil || version != latestVersion() {
+		t.Errorf("version = %d, %v; want %d", version, err, latestVersion())
+	}
+	var splits int
+	if err := db.QueryRow("SELECT COUNT(*) FROM splits WHERE transaction_id = 1").Scan(&splits); err != nil || splits != 2 {
+		t.Errorf("splits after migrating = %d, %v; want 2", splits, err)
+	}
+	db.Close()
+
+	// A second open has nothing to do, so it takes no second backup
+	db, err = NewDB(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	db.Close()
+	if backups, _ := filepath.Glob(path + ".v*.bak"); len(backups) != 1 {
+		t.Errorf("backups = %v, want one from before the first migration", backups)
+	}
+}
+
+func TestMigrateRefusesNewerDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "newer.db")
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO schema_migrations (version) VALUES (?)", latestVersion()+1); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	var tooNew *ErrDatabaseTooNew
+	if _, err := NewDB(path); !errors.As(err, &tooNew) {
+		t.Errorf("opening a newer database: %v, want ErrDatabaseTooNew", err)
+	}
+}
+
+func TestMigration7ExponentsMatchRegistry(t *testing.T) {
+	listed := make(map[string]int)
+	for exp, codes := range migration7Exponents {
+		for _, code := range codes {
+			listed[code] = exp
+		}
+	}
+	for _, code := range model.CurrencyCodes() {
+		got, ok := listed[code]
+		if !ok {
+			got = 2
+		}
+		if want := model.CurrencyExponent(code); got != want {
+			t.Errorf("%s: migration 7 uses %d decimals, model.Currencies has %d", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,548 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+var (
+	ErrPayeeNotFound     = errors.New("payee not found")
+	ErrPayeeNameRequired = errors.New("a payee needs a name")
+	ErrPayeeExists       = errors.New("a payee with this name or alias already exists")
+	ErrPayeeAliasTaken   = errors.New("alias already belongs to another payee")
+	ErrPayeeInUse        = errors.New("payee still has transactions; merge it into another payee instead")
+	ErrSamePayee         = errors.New("cannot merge a payee into itself")
+)
+
+// rowsQuerier is satisfied by both the database and an open *sql.Tx.
+type rowsQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// payeeKey is one name or alias a description can match.
+type payeeKey struct {
+	words   []string
+	payeeID int64
+}
+
+// payeeMatcher finds the payee for a description. A name or alias matches
+// when its words appear together in the normalized description; the
+// longest match wins, so "uber eats" beats "uber".
+type payeeMatcher struct {
+	keys     []payeeKey
+	nameKeys map[int64]string // payee ID to NormalizePayee(name)
+	taken    map[string]int64 // every key in use, to its payee
+}
+
+func loadPayeeMatcher(q rowsQuerier) (*payeeMatcher, error) {
+	m := &payeeMatcher{nameKeys: make(map[int64]string), taken: make(map[string]int64)}
+	rows, err := q.Query(`
+		SELECT id, name_key, 1 FROM payees
+		UNION ALL
+		SELECT payee_id, alias_key, 0 FROM payee_aliases
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var key string
+		var isName bool
+		if err := rows.Scan(&id, &key, &isName); err != nil {
+			return nil, err
+		}
+		if isName {
+			m.nameKeys[id] = key
+		}
+		m.add(id, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// add inserts a key, keeping longer keys first.
+func (m *payeeMatcher) add(payeeID int64, key string) {
+	if key == "" {
+		return
+	}
+	m.taken[key] = payeeID
+	k := payeeKey{words: strings.Fields(key), payeeID: payeeID}
+	size := func(w []string) (int, int) { return len(w), len(strings.Join(w, " ")) }
+	words, chars := size(k.words)
+	i := sort.Search(len(m.keys), func(i int) bool {
+		w, c := size(m.keys[i].words)
+		return w < words || w == words && c < chars
+	})
+	m.keys = append(m.keys, payeeKey{})
+	copy(m.keys[i+1:], m.keys[i:])
+	m.keys[i] = k
+}
+
+// match returns the payee a description belongs to, if any.
+func (m *payeeMatcher) match(description string) (int64, bool) {
+	words := strings.Fields(NormalizePayee(description))
+	for _, k := range m.keys {
+		if containsWords(words, k.words) {
+			return k.payeeID, true
+		}
+	}
+	return 0, false
+}
+
+// containsWords reports whether sub appears as a run within words.
+func containsWords(words, sub []string) bool {
+	for i := 0; i+len(sub) <= len(words); i++ {
+		j := 0
+		for j < len(sub) && words[i+j] == sub[j] {
+			j++
+		}
+		if j == len(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// linkPayee points a saved transaction at the payee its description
+// matches, or at none. It runs whenever a description is written, so the
+// link always follows the description; callers writing many transactions
+// load m once for all of them.
+func linkPayee(tx *sql.Tx, m *payeeMatcher, txID int64, description string) (*int64, error) {
+	var payeeID *int64
+	if id, ok := m.match(description); ok {
+		payeeID = &id
+	}
+	if _, err := tx.Exec("UPDATE transactions SET payee_id = ? WHERE id = ?", payeeID, txID); err != nil {
+		return nil, err
+	}
+	return payeeID, nil
+}
+
+// payeeScope selects the transactions a change to a payee can relink:
+// those linked to it and those whose description contains one of its
+// keys. Each key is looked for by its longest word, which LIKE can only
+// find ignoring case for ASCII words; otherwise every transaction is
+// checked.
+func payeeScope(payeeID int64, keys []string) (string, []interface{}) {
+	conds := []string{"payee_id = ?"}
+	args := []interface{}{payeeID}
+	for _, key := range keys {
+		longest := ""
+		for _, w := range strings.Fields(key) {
+			if len(w) > len(longest) {
+				longest = w
+			}
+		}
+		for i := 0; i < len(longest); i++ {
+			if longest[i] >= 0x80 {
+				return "", nil
+			}
+		}
+		if longest != "" {
+			conds = append(conds, `description LIKE ? ESCAPE '\'`)
+			args = append(args, likePattern(longest))
+		}
+	}
+	return "WHERE " + strings.Join(conds, " OR "), args
+}
+
+// relinkPayee relinks the transactions a change to a payee's name or
+// aliases can affect; keys are its name and alias keys.
+func relinkPayee(tx *sql.Tx, payeeID int64, nameKey string, keys []string) error {
+	where, args := payeeScope(payeeID, append([]string{nameKey}, keys...))
+	_, err := relinkPayees(tx, where, args...)
+	return err
+}
+
+// relinkPayees matches the transactions selected by where again after
+// names or aliases change, and returns how many links changed.
+func relinkPayees(tx *sql.Tx, where string, args ...interface{}) (int, error) {
+	m, err := loadPayeeMatcher(tx)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := tx.Query("SELECT id, description, payee_id FROM transactions "+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	type link struct {
+		txID        int64
+		description string
+		payeeID     *int64
+	}
+	var links []link
+	for rows.Next() {
+		var l link
+		if err := rows.Scan(&l.txID, &l.description, &l.payeeID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		links = append(links, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for _, l := range links {
+		var payeeID *int64
+		if id, ok := m.match(l.description); ok {
+			payeeID = &id
+		}
+		if payeeID == nil && l.payeeID == nil || payeeID != nil && l.payeeID != nil && *payeeID == *l.payeeID {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE transactions SET payee_id = ? WHERE id = ?", payeeID, l.txID); err != nil {
+			return 0, err
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+// LinkPayees links transactions without a payee to the payees their
+// descriptions match, and returns how many were linked.
+func (r *Repository) LinkPayees() (int, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	n, err := relinkPayees(tx, "WHERE payee_id IS NULL")
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// GetPayees returns every payee in name order with its aliases and the
+// number of linked transactions.
+func (r *Repository) GetPayees() ([]model.Payee, error) {
+	return r.queryPayees("")
+}
+
+// GetPayee returns one payee.
+func (r *Repository) GetPayee(id int64) (*model.Payee, error) {
+	payees, err := r.queryPayees("WHERE p.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(payees) == 0 {
+		return nil, ErrPayeeNotFound
+	}
+	return &payees[0], nil
+}
+
+func (r *Repository) queryPayees(where string, args ...interface{}) ([]model.Payee, error) {
+	rows, err := r.DB.Query(`
+		SELECT p.id, p.name, p.default_category_id, p.default_account_id,
+			(SELECT COUNT(*) FROM transactions t WHERE t.payee_id = p.id)
+		FROM payees p `+where+`
+		ORDER BY p.name COLLATE NOCASE
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	var payees []model.Payee
+	index := make(map[int64]int)
+	for rows.Next() {
+		var p model.Payee
+		if err := rows.Scan(&p.ID, &p.Name, &p.DefaultCategoryID, &p.DefaultAccountID, &p.Transactions); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[p.ID] = len(payees)
+		payees = append(payees, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.DB.Query("SELECT payee_id, alias FROM payee_aliases ORDER BY alias COLLATE NOCASE")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var payeeID int64
+		var alias string
+		if err := rows.Scan(&payeeID, &alias); err != nil {
+			return nil, err
+		}
+		if i, ok := index[payeeID]; ok {
+			payees[i].Aliases = append(payees[i].Aliases, alias)
+		}
+	}
+	return payees, rows.Err()
+}
+
+// MatchPayee returns the payee a description would be linked to, or nil
+// if it matches none.
+func (r *Repository) MatchPayee(description string) (*model.Payee, error) {
+	m, err := loadPayeeMatcher(r.DB)
+	if err != nil {
+		return nil, err
+	}
+	id, ok := m.match(description)
+	if !ok {
+		return nil, nil
+	}
+	return r.GetPayee(id)
+}
+
+// checkPayee validates a payee's name and aliases against the other
+// payees and returns the keys to store. Aliases that repeat the name or
+// each other are dropped.
+func checkPayee(m *payeeMatcher, p *model.Payee) (string, []string, error) {
+	p.Name = strings.TrimSpace(p.Name)
+	nameKey := NormalizePayee(p.Name)
+	if nameKey == "" {
+		return "", nil, ErrPayeeNameRequired
+	}
+	if owner, ok := m.taken[nameKey]; ok && owner != p.ID {
+		return "", nil, ErrPayeeExists
+	}
+
+	seen := map[string]bool{nameKey: true}
+	var aliases, keys []string
+	for _, alias := range p.Aliases {
+		alias = strings.TrimSpace(alias)
+		key := NormalizePayee(alias)
+		if key == "" || seen[key] {
+			continue
+		}
+		if owner, ok := m.taken[key]; ok && owner != p.ID {
+			return "", nil, fmt.Errorf("%w: %s", ErrPayeeAliasTaken, alias)
+		}
+		seen[key] = true
+		aliases = append(aliases, alias)
+		keys = append(keys, key)
+	}
+	p.Aliases = aliases
+	return nameKey, keys, nil
+}
+
+// setPayeeAliases replaces a payee's aliases.
+func setPayeeAliases(tx *sql.Tx, payeeID int64, aliases, keys []string) error {
+	if _, err := tx.Exec("DELETE FROM payee_aliases WHERE payee_id = ?", payeeID); err != nil {
+		return err
+	}
+	for i, alias := range aliases {
+		if _, err := tx.Exec("INSERT INTO payee_aliases (payee_id, alias, alias_key) VALUES (?, ?, ?)",
+			payeeID, alias, keys[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreatePayee adds a payee and links the transactions its name and
+// aliases match.
+func (r *Repository) CreatePayee(p *model.Payee) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	m, err := loadPayeeMatcher(tx)
+	if err != nil {
+		return err
+	}
+	nameKey, keys, err := checkPayee(m, p)
+	if err != nil {
+		return err
+	}
+	res, err := tx.Exec("INSERT INTO payees (name, name_key, default_category_id, default_account_id) VALUES (?, ?, ?, ?)",
+		p.Name, nameKey, p.DefaultCategoryID, p.DefaultAccountID)
+	if err != nil {
+		return err
+	}
+	if p.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+	if err := setPayeeAliases(tx, p.ID, p.Aliases, keys); err != nil {
+		return err
+	}
+	if err := relinkPayee(tx, p.ID, nameKey, keys); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdatePayee saves a payee's name, aliases and defaults. A renamed payee
+// keeps its old name as an alias so descriptions that matched it still do.
+func (r *Repository) UpdatePayee(p *model.Payee) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldName string
+	if err := tx.QueryRow("SELECT name FROM payees WHERE id = ?", p.ID).Scan(&oldName); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrPayeeNotFound
+		}
+		return err
+	}
+	m, err := loadPayeeMatcher(tx)
+	if err != nil {
+		return err
+	}
+	if NormalizePayee(oldName) != NormalizePayee(p.Name) {
+		p.Aliases = append(p.Aliases, oldName)
+	}
+	nameKey, keys, err := checkPayee(m, p)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE payees SET name = ?, name_key = ?, default_category_id = ?, default_account_id = ? WHERE id = ?",
+		p.Name, nameKey, p.DefaultCategoryID, p.DefaultAccountID, p.ID)
+	if err != nil {
+		return err
+	}
+	if err := setPayeeAliases(tx, p.ID, p.Aliases, keys); err != nil {
+		return err
+	}
+	if err := relinkPayee(tx, p.ID, nameKey, keys); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MergePayees folds source into target: source's name and aliases become
+// aliases of target, its transactions move over, target takes any
+// defaults it lacks, and source is removed.
+func (r *Repository) MergePayees(sourceID, targetID int64) error {
+	if sourceID == targetID {
+		return ErrSamePayee
+	}
+	source, err := r.GetPayee(sourceID)
+	if err != nil {
+		return err
+	}
+	target, err := r.GetPayee(targetID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Source's keys are freed before target claims them
+	if _, err := tx.Exec("DELETE FROM payees WHERE id = ?", sourceID); err != nil {
+		return err
+	}
+	m, err := loadPayeeMatcher(tx)
+	if err != nil {
+		return err
+	}
+	target.Aliases = append(append(target.Aliases, source.Name), source.Aliases...)
+	if target.DefaultCategoryID == nil {
+		target.DefaultCategoryID = source.DefaultCategoryID
+	}
+	if target.DefaultAccountID == nil {
+		target.DefaultAccountID = source.DefaultAccountID
+	}
+	nameKey, keys, err := checkPayee(m, target)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE payees SET default_category_id = ?, default_account_id = ? WHERE id = ?",
+		target.DefaultCategoryID, target.DefaultAccountID, targetID)
+	if err != nil {
+		return err
+	}
+	if err := setPayeeAliases(tx, targetID, target.Aliases, keys); err != nil {
+		return err
+	}
+	if err := relinkPayee(tx, targetID, nameKey, keys); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeletePayee removes a payee that no transaction uses.
+func (r *Repository) DeletePayee(id int64) error {
+	var n int
+	if err := r.DB.QueryRow("SELECT COUNT(*) FROM transactions WHERE payee_id = ?", id).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrPayeeInUse
+	}
+	res, err := r.DB.Exec("DELETE FROM payees WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(res, ErrPayeeNotFound)
+}
+
+// GetPayeeBreakdown returns expense totals per payee in the base
+// currency, largest first. A limit above zero keeps only the top payees.
+// Like the category breakdown, only expense-account legs count.
+func (r *Repository) GetPayeeBreakdown(startDate, endDate *time.Time, limit int) ([]model.PayeeSpending, error) {
+	base, err := r.GetBaseCurrency()
+	if err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT COALESCE(p.id, 0), COALESCE(p.name, t.description),
+			SUM(s.amount * s.exchange_rate), COUNT(DISTINCT t.id)
+		FROM splits s
+		JOIN transactions t ON s.transaction_id = t.id
+		JOIN accounts a ON s.account_id = a.id
+		LEFT JOIN payees p ON p.id = t.payee_id
+		WHERE s.amount > 0 AND a.type = 'Expense'
+	`
+	var args []interface{}
+	if startDate != nil {
+		query += " AND t.date >= ?"
+		args = append(args, startDate.Format("2006-01-02"))
+	}
+	if endDate != nil {
+		query += " AND t.date < ?"
+		args = append(args, endOfDay(*endDate))
+	}
+	// Transactions without a payee are grouped by description
+	query += " GROUP BY p.id, CASE WHEN p.id IS NULL THEN t.description END ORDER BY 3 DESC"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var spending []model.PayeeSpending
+	for rows.Next() {
+		var p model.PayeeSpending
+		var total sql.NullFloat64
+		if err := rows.Scan(&p.PayeeID, &p.Name, &total, &p.Count); err != nil {
+			return nil, err
+		}
+		p.Amount = model.NewMoney(int64(math.RoundToEven(total.Float64)), base)
+		spending = append(spending, p)
+	}
+	return spending, rows.Err()
+}
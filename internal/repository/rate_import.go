@@ -0,0 +1,390 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+// ecbBase is the currency every ECB reference rate is quoted against.
+const ecbBase = "EUR"
+
+// ImportExchangeRates loads a downloaded rate file into the exchange-rate
+// store. It accepts the ECB eurofxref files (CSV or XML, daily or
+// historical) and a generic CSV with date,from,to,rate columns. Rates
+// already stored for the same date and pair are replaced; days the file
+// skips (weekends, holidays) are forward-filled from the previous quote
+// without overwriting rates already on file.
+func (r *Repository) ImportExchangeRates(path string) (*model.RateImportReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.RateImportReport{}
+	var rates []model.ExchangeRate
+	switch trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))); {
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		report.Format = "ECB XML"
+		rates, err = parseECBXML(bytes.NewReader(trimmed), report)
+	case isECBHeader(trimmed):
+		report.Format = "ECB CSV"
+		rates, err = parseECBCSV(bytes.NewReader(trimmed), report)
+	default:
+		report.Format = "CSV"
+		rates, err = parseRateCSV(bytes.NewReader(trimmed), report)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("no exchange rates found in %s file", report.Format)
+	}
+
+	rates = dedupeRates(rates, report)
+	filled := forwardFillRates(rates)
+
+	if err := r.storeImportedRates(rates, filled, report); err != nil {
+		return nil, err
+	}
+
+	report.Uncovered, err = r.uncoveredCurrencies()
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// isECBHeader reports whether the first line looks like "Date,USD,JPY,...".
+func isECBHeader(data []byte) bool {
+	line, _, _ := bufio.NewReader(bytes.NewReader(data)).ReadLine()
+	cols := strings.Split(string(line), ",")
+	if len(cols) < 2 || !strings.EqualFold(strings.TrimSpace(cols[0]), "date") {
+		return false
+	}
+	_, err := normalizeCurrency(cols[1])
+	return err == nil
+}
+
+// parseECBCSV reads eurofxref(-hist).csv: one row per day, one column per
+// currency, each value being the number of units per 1 EUR.
+func parseECBCSV(rd io.Reader, report *model.RateImportReport) ([]model.ExchangeRate, error) {
+	cr := csv.NewReader(rd)
+	cr.FieldsPerRecord = -1 // ECB rows end with a trailing comma
+	cr.TrimLeadingSpace = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	header := records[0]
+	var rates []model.ExchangeRate
+	for _, row := range records[1:] {
+		date, err := parseRateDate(row[0])
+		if err != nil {
+			report.Skipped++
+			continue
+		}
+		for i := 1; i < len(row) && i < len(header); i++ {
+			to, err := normalizeCurrency(header[i])
+			if err != nil {
+				continue // The unnamed trailing column
+			}
+			rate, err := parseRateValue(row[i])
+			if err != nil {
+				report.Skipped++
+				continue
+			}
+			rates = append(rates, model.ExchangeRate{Date: date, From: ecbBase, To: to, Rate: rate, Source: "ECB"})
+		}
+	}
+	return rates, nil
+}
+
+type ecbEnvelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+// parseECBXML reads eurofxref(-hist).xml.
+func parseECBXML(rd io.Reader, report *model.RateImportReport) ([]model.ExchangeRate, error) {
+	var env ecbEnvelope
+	if err := xml.NewDecoder(rd).Decode(&env); err != nil {
+		return nil, err
+	}
+
+	var rates []model.ExchangeRate
+	for _, day := range env.Days {
+		date, err := parseRateDate(day.Time)
+		if err != nil {
+			report.Skipped++
+			continue
+		}
+		for _, q := range day.Rates {
+			to, err := normalizeCurrency(q.Currency)
+			if err != nil {
+				report.Skipped++
+				continue
+			}
+			rate, err := parseRateValue(q.Rate)
+			if err != nil {
+				report.Skipped++
+				continue
+			}
+			rates = append(rates, model.ExchangeRate{Date: date, From: ecbBase, To: to, Rate: rate, Source: "ECB"})
+		}
+	}
+	return rates, nil
+}
+
+// parseRateCSV reads date,from,to,rate rows. A header row is optional.
+func parseRateCSV(rd io.Reader, report *model.RateImportReport) ([]model.ExchangeRate, error) {
+	cr := csv.NewReader(rd)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	dateIdx, fromIdx, toIdx, rateIdx := 0, 1, 2, 3
+	if len(records) > 0 {
+		header := make(map[string]int)
+		for i, col := range records[0] {
+			header[strings.ToLower(strings.TrimSpace(col))] = i
+		}
+		if _, ok := header["date"]; ok {
+			for _, col := range []string{"date", "from", "to", "rate"} {
+				if _, ok := header[col]; !ok {
+					return nil, fmt.Errorf("rate CSV must have date, from, to and rate columns")
+				}
+			}
+			dateIdx, fromIdx, toIdx, rateIdx = header["date"], header["from"], header["to"], header["rate"]
+			records = records[1:]
+		}
+	}
+
+	var rates []model.ExchangeRate
+	for _, row := range records {
+		if len(row) <= dateIdx || len(row) <= fromIdx || len(row) <= toIdx || len(row) <= rateIdx {
+			report.Skipped++
+			continue
+		}
+		date, err := parseRateDate(row[dateIdx])
+		if err != nil {
+			report.Skipped++
+			continue
+		}
+		from, err := normalizeCurrency(row[fromIdx])
+		if err != nil {
+			report.Skipped++
+			continue
+		}
+		to, err := normalizeCurrency(row[toIdx])
+		if err != nil || to == from {
+			report.Skipped++
+			continue
+		}
+		rate, err := parseRateValue(row[rateIdx])
+		if err != nil {
+			report.Skipped++
+			continue
+		}
+		rates = append(rates, model.ExchangeRate{Date: date, From: from, To: to, Rate: rate, Source: "CSV import"})
+	}
+	return rates, nil
+}
+
+func parseRateDate(s string) (time.Time, error) {
+	return time.Parse(dateLayout, strings.TrimSpace(s))
+}
+
+// parseRateValue rejects blanks, ECB's "N/A" and non-positive rates.
+func parseRateValue(s string) (float64, error) {
+	rate, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, err
+	}
+	if rate <= 0 {
+		return 0, fmt.Errorf("rate must be positive: %s", s)
+	}
+	return rate, nil
+}
+
+type ratePairKey struct {
+	from, to string
+}
+
+// dedupeRates keeps the last row for each date and pair, sorted by pair
+// then date.
+func dedupeRates(rates []model.ExchangeRate, report *model.RateImportReport) []model.ExchangeRate {
+	type key struct {
+		pair ratePairKey
+		date time.Time
+	}
+	index := make(map[key]int, len(rates))
+	var out []model.ExchangeRate
+	for _, er := range rates {
+		k := key{ratePairKey{er.From, er.To}, er.Date}
+		if i, ok := index[k]; ok {
+			out[i] = er
+			report.Duplicates++
+			continue
+		}
+		index[k] = len(out)
+		out = append(out, er)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].From != out[j].From {
+			return out[i].From < out[j].From
+		}
+		if out[i].To != out[j].To {
+			return out[i].To < out[j].To
+		}
+		return out[i].Date.Before(out[j].Date)
+	})
+	return out
+}
+
+// forwardFillRates returns a rate for every day missing between two quotes
+// of the same pair, carrying the earlier quote forward. rates must be
+// sorted by pair then date.
+func forwardFillRates(rates []model.ExchangeRate) []model.ExchangeRate {
+	var filled []model.ExchangeRate
+	for i := 1; i < len(rates); i++ {
+		prev, cur := rates[i-1], rates[i]
+		if prev.From != cur.From || prev.To != cur.To {
+			continue
+		}
+		for day := prev.Date.AddDate(0, 0, 1); day.Before(cur.Date); day = day.AddDate(0, 0, 1) {
+			fill := prev
+			fill.Date = day
+			fill.Source = prev.Source + " (filled)"
+			filled = append(filled, fill)
+		}
+	}
+	return filled
+}
+
+// storeImportedRates writes quotes (replacing stored ones) and fills
+// (only where no rate is stored) in one transaction.
+func (r *Repository) storeImportedRates(rates, filled []model.ExchangeRate, report *model.RateImportReport) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, er := range rates {
+		date := er.Date.Format(dateLayout)
+		var existing float64
+		err := tx.QueryRow("SELECT rate FROM exchange_rates WHERE date = ? AND from_currency = ? AND to_currency = ?",
+			date, er.From, er.To).Scan(&existing)
+		switch {
+		case err == sql.ErrNoRows:
+			report.Imported++
+		case err != nil:
+			return err
+		default:
+			report.Updated++
+		}
+		_, err = tx.Exec(`
+			INSERT INTO exchange_rates (date, from_currency, to_currency, rate, source) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(date, from_currency, to_currency) DO UPDATE SET rate = excluded.rate, source = excluded.source
+		`, date, er.From, er.To, er.Rate, er.Source)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, er := range filled {
+		res, err := tx.Exec(`
+			INSERT INTO exchange_rates (date, from_currency, to_currency, rate, source) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(date, from_currency, to_currency) DO NOTHING
+		`, er.Date.Format(dateLayout), er.From, er.To, er.Rate, er.Source)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil {
+			report.Filled += int(n)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// uncoveredCurrencies lists account currencies that cannot be converted to
+// the base currency on the date of their earliest transaction (or today,
+// for currencies with no transactions yet).
+func (r *Repository) uncoveredCurrencies() ([]string, error) {
+	base, err := r.GetBaseCurrency()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT a.currency, MIN(t.date)
+		FROM accounts a
+		LEFT JOIN splits s ON s.account_id = a.id
+		LEFT JOIN transactions t ON s.transaction_id = t.id
+		WHERE a.currency IS NOT NULL AND a.currency != ''
+		GROUP BY a.currency
+		ORDER BY a.currency
+	`)
+	if err != nil {
+		return nil, err
+	}
+	type usage struct {
+		currency string
+		first    time.Time
+	}
+	var used []usage
+	for rows.Next() {
+		var u usage
+		var first sql.NullString
+		if err := rows.Scan(&u.currency, &first); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		u.first = time.Now()
+		if first.Valid && len(first.String) >= len(dateLayout) {
+			if d, err := time.Parse(dateLayout, first.String[:len(dateLayout)]); err == nil {
+				u.first = d
+			}
+		}
+		used = append(used, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var uncovered []string
+	for _, u := range used {
+		code, err := normalizeCurrency(u.currency)
+		if err != nil || code == base {
+			continue
+		}
+		if _, err := r.RateAsOf(code, base, u.first); err != nil {
+			uncovered = append(uncovered, code)
+		}
+	}
+	return uncovered, nil
+}
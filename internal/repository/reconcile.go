@@ -0,0 +1,352 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+// Reconciliation errors.
+var (
+	ErrTransactionNotFound      = errors.New("transaction not found")
+	ErrTransactionReconciled    = errors.New("transaction has been reconciled against a statement")
+	ErrReconciledStatusReserved = errors.New("transactions become Reconciled only by finishing a reconciliation")
+	ErrReconciliationNotFound   = errors.New("reconciliation not found or already finished")
+	ErrReconcileUnbalanced      = errors.New("cleared balance does not match the statement balance yet")
+)
+
+// queryRower is satisfied by both the database and an open *sql.Tx.
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// transactionStatus reads the stored status of a transaction.
+func transactionStatus(q queryRower, txID int64) (model.TransactionStatus, error) {
+	var status sql.NullString
+	err := q.QueryRow("SELECT status FROM transactions WHERE id = ?", txID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", ErrTransactionNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if !status.Valid {
+		return model.TransactionStatusPending, nil
+	}
+	return model.TransactionStatus(status.String), nil
+}
+
+// syncTransactionStatus sets a transaction's status from its splits:
+// Reconciled once any leg is on a statement, otherwise Cleared once any
+// leg has cleared.
+func syncTransactionStatus(tx *sql.Tx, txID int64) error {
+	_, err := tx.Exec(`
+		UPDATE transactions SET status = CASE
+			WHEN EXISTS (SELECT 1 FROM splits WHERE transaction_id = ? AND status = ?) THEN ?
+			WHEN EXISTS (SELECT 1 FROM splits WHERE transaction_id = ? AND status = ?) THEN ?
+			ELSE ? END
+		WHERE id = ?
+	`, txID, model.TransactionStatusReconciled, model.TransactionStatusReconciled,
+		txID, model.TransactionStatusCleared, model.TransactionStatusCleared,
+		model.TransactionStatusPending, txID)
+	return err
+}
+
+// setTransactionStatus gives every unreconciled leg of a transaction the
+// same status, as when the whole transaction is marked from the editor.
+func setTransactionStatus(tx *sql.Tx, txID int64, status model.TransactionStatus) error {
+	_, err := tx.Exec("UPDATE splits SET status = ? WHERE transaction_id = ? AND status != ?",
+		status, txID, model.TransactionStatusReconciled)
+	if err != nil {
+		return err
+	}
+	return syncTransactionStatus(tx, txID)
+}
+
+// splitLegState is the cleared state of a transaction's legs in one account.
+type splitLegState struct {
+	accountID        int64
+	status           model.TransactionStatus
+	reconciliationID *int64
+}
+
+func splitLegStates(tx *sql.Tx, txID int64) ([]splitLegState, error) {
+	rows, err := tx.Query(`SELECT account_id, MAX(status), MAX(reconciliation_id) FROM splits
+		WHERE transaction_id = ? GROUP BY account_id`, txID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var legs []splitLegState
+	for rows.Next() {
+		var l splitLegState
+		if err := rows.Scan(&l.accountID, &l.status, &l.reconciliationID); err != nil {
+			return nil, err
+		}
+		legs = append(legs, l)
+	}
+	return legs, rows.Err()
+}
+
+// restoreSplitLegStates puts back states saved by splitLegStates after a
+// transaction's splits were rewritten.
+func restoreSplitLegStates(tx *sql.Tx, txID int64, legs []splitLegState) error {
+	for _, l := range legs {
+		_, err := tx.Exec("UPDATE splits SET status = ?, reconciliation_id = ? WHERE transaction_id = ? AND account_id = ?",
+			l.status, l.reconciliationID, txID, l.accountID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartReconciliation opens a reconcile session for an account against a
+// statement. An account has at most one open session; starting again
+// updates its statement date and balance instead of opening a second one.
+func (r *Repository) StartReconciliation(accountID int64, statementDate time.Time, statementBalance int64) (*model.Reconciliation, error) {
+	acc, err := r.GetAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if acc == nil {
+		return nil, ErrAccountNotFound
+	}
+
+	y, m, d := statementDate.Date()
+	date := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	open, err := r.GetOpenReconciliation(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if open != nil {
+		_, err := r.DB.Exec("UPDATE reconciliations SET statement_date = ?, statement_balance = ? WHERE id = ?",
+			date.Format(dateLayout), statementBalance, open.ID)
+		if err != nil {
+			return nil, err
+		}
+		return r.getReconciliation(open.ID)
+	}
+
+	res, err := r.DB.Exec("INSERT INTO reconciliations (account_id, statement_date, statement_balance) VALUES (?, ?, ?)",
+		accountID, date.Format(dateLayout), statementBalance)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return r.getReconciliation(id)
+}
+
+const reconciliationColumns = `id, account_id, statement_date, statement_balance, cleared_balance, started_at, completed_at`
+
+func scanReconciliation(row interface{ Scan(...interface{}) error }) (*model.Reconciliation, error) {
+	var rec model.Reconciliation
+	var cleared sql.NullInt64
+	var started, completed sql.NullTime
+	err := row.Scan(&rec.ID, &rec.AccountID, &rec.StatementDate, &rec.StatementBalance, &cleared, &started, &completed)
+	if err != nil {
+		return nil, err
+	}
+	rec.ClearedBalance = cleared.Int64
+	rec.StartedAt = started.Time
+	if completed.Valid {
+		rec.CompletedAt = &completed.Time
+	}
+	return &rec, nil
+}
+
+func (r *Repository) getReconciliation(id int64) (*model.Reconciliation, error) {
+	rec, err := scanReconciliation(r.DB.QueryRow("SELECT "+reconciliationColumns+" FROM reconciliations WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrReconciliationNotFound
+	}
+	return rec, err
+}
+
+// GetOpenReconciliation returns the account's unfinished session, or nil.
+func (r *Repository) GetOpenReconciliation(accountID int64) (*model.Reconciliation, error) {
+	rec, err := scanReconciliation(r.DB.QueryRow(
+		"SELECT "+reconciliationColumns+" FROM reconciliations WHERE account_id = ? AND completed_at IS NULL", accountID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return rec, err
+}
+
+// GetReconciliations returns an account's finished reconciliations, newest first.
+func (r *Repository) GetReconciliations(accountID int64) ([]model.Reconciliation, error) {
+	rows, err := r.DB.Query("SELECT "+reconciliationColumns+` FROM reconciliations
+		WHERE account_id = ? AND completed_at IS NOT NULL
+		ORDER BY statement_date DESC, id DESC`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recs []model.Reconciliation
+	for rows.Next() {
+		rec, err := scanReconciliation(rows)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, *rec)
+	}
+	return recs, rows.Err()
+}
+
+// GetReconcileItems lists the transactions whose legs in the account are
+// not yet reconciled, dated on or before the statement date, oldest first.
+// Status is that of the account's legs, not of the whole transaction.
+func (r *Repository) GetReconcileItems(rec *model.Reconciliation) ([]model.ReconcileItem, error) {
+	end := rec.StatementDate.AddDate(0, 0, 1).Format(dateLayout)
+	rows, err := r.DB.Query(`
+		SELECT t.id, t.date, t.description, MIN(s.status), SUM(s.amount)
+		FROM transactions t
+		JOIN splits s ON s.transaction_id = t.id
+		WHERE s.account_id = ? AND s.status != ? AND t.date < ?
+		GROUP BY t.id
+		ORDER BY t.date, t.id
+	`, rec.AccountID, model.TransactionStatusReconciled, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []model.ReconcileItem
+	for rows.Next() {
+		var it model.ReconcileItem
+		if err := rows.Scan(&it.TransactionID, &it.Date, &it.Description, &it.Status, &it.Amount); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// SetTransactionCleared ticks (Cleared) or unticks (Pending) a
+// transaction's legs in one account; its legs in other accounts keep their
+// own state. Reconciled legs cannot be changed this way.
+func (r *Repository) SetTransactionCleared(txID, accountID int64, cleared bool) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var legs, reconciled int
+	err = tx.QueryRow("SELECT COUNT(*), COUNT(CASE WHEN status = ? THEN 1 END) FROM splits WHERE transaction_id = ? AND account_id = ?",
+		model.TransactionStatusReconciled, txID, accountID).Scan(&legs, &reconciled)
+	if err != nil {
+		return err
+	}
+	if legs == 0 {
+		return ErrTransactionNotFound
+	}
+	if reconciled > 0 {
+		return ErrTransactionReconciled
+	}
+
+	status := model.TransactionStatusPending
+	if cleared {
+		status = model.TransactionStatusCleared
+	}
+	if _, err := tx.Exec("UPDATE splits SET status = ? WHERE transaction_id = ? AND account_id = ?", status, txID, accountID); err != nil {
+		return err
+	}
+	if err := syncTransactionStatus(tx, txID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetReconcileSummary compares the account's cleared balance with the
+// statement. Cleared transactions dated after the statement belong to the
+// next statement and are left out.
+func (r *Repository) GetReconcileSummary(rec *model.Reconciliation) (model.ReconcileSummary, error) {
+	var sum model.ReconcileSummary
+	end := rec.StatementDate.AddDate(0, 0, 1).Format(dateLayout)
+
+	var opening, cleared sql.NullInt64
+	err := r.DB.QueryRow(`
+		SELECT
+			SUM(CASE WHEN s.status = ? THEN s.amount END),
+			SUM(CASE WHEN s.status = ? AND t.date < ? THEN s.amount END)
+		FROM splits s
+		JOIN transactions t ON s.transaction_id = t.id
+		WHERE s.account_id = ?
+	`, model.TransactionStatusReconciled, model.TransactionStatusCleared, end, rec.AccountID).Scan(&opening, &cleared)
+	if err != nil {
+		return sum, err
+	}
+
+	sum.OpeningBalance = opening.Int64
+	sum.ClearedAmount = cleared.Int64
+	sum.ClearedBalance = sum.OpeningBalance + sum.ClearedAmount
+	sum.Difference = rec.StatementBalance - sum.ClearedBalance
+	return sum, nil
+}
+
+// FinishReconciliation stamps the account's cleared legs covered by the
+// statement as Reconciled and records the session as complete. It refuses
+// while the cleared balance differs from the statement balance.
+func (r *Repository) FinishReconciliation(recID int64) error {
+	rec, err := r.getReconciliation(recID)
+	if err != nil {
+		return err
+	}
+	if rec.CompletedAt != nil {
+		return ErrReconciliationNotFound
+	}
+	sum, err := r.GetReconcileSummary(rec)
+	if err != nil {
+		return err
+	}
+	if sum.Difference != 0 {
+		return ErrReconcileUnbalanced
+	}
+
+	end := rec.StatementDate.AddDate(0, 0, 1).Format(dateLayout)
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE splits SET status = ?, reconciliation_id = ?
+		WHERE account_id = ? AND status = ?
+		AND transaction_id IN (SELECT id FROM transactions WHERE date < ?)
+	`, model.TransactionStatusReconciled, rec.ID, rec.AccountID, model.TransactionStatusCleared, end)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE transactions SET status = ? WHERE id IN (SELECT transaction_id FROM splits WHERE reconciliation_id = ?)",
+		model.TransactionStatusReconciled, rec.ID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE reconciliations SET cleared_balance = ?, completed_at = CURRENT_TIMESTAMP WHERE id = ?",
+		sum.ClearedBalance, rec.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CancelReconciliation discards an open session. Transactions ticked as
+// Cleared stay cleared.
+func (r *Repository) CancelReconciliation(recID int64) error {
+	res, err := r.DB.Exec("DELETE FROM reconciliations WHERE id = ? AND completed_at IS NULL", recID)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(res, ErrReconciliationNotFound)
+}
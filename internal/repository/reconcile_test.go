@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+func newTestRepository(t *testing.T) *Repository {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewRepository(db)
+}
+
+func TestReconcileOneLegOfTransfer(t *testing.T) {
+	r := newTestRepository(t)
+	checking := &model.Account{Name: "Checking", Type: model.AccountTypeBank, Currency: "USD"}
+	savings := &model.Account{Name: "Savings", Type: model.AccountTypeBank, Currency: "USD"}
+	for _, a := range []*model.Account{checking, savings} {
+		if err := r.CreateAccount(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	transfer := &model.Transaction{
+		Date:        time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC),
+		Description: "Transfer to savings",
+		Status:      model.TransactionStatusPending,
+		Splits: []model.Split{
+			{AccountID: checking.ID, Amount: -5000, Currency: "USD"},
+			{AccountID: savings.ID, Amount: 5000, Currency: "USD"},
+		},
+	}
+	if err := r.CreateTransaction(transfer); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := r.StartReconciliation(checking.ID, time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC), -5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetTransactionCleared(transfer.ID, checking.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.FinishReconciliation(rec.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// The savings leg was on no statement, so it is still open there
+	rec, err = r.StartReconciliation(savings.ID, time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC), 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := r.GetReconcileItems(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Status != model.TransactionStatusPending {
+		t.Fatalf("savings items = %+v, want the transfer still Pending", items)
+	}
+	sum, err := r.GetReconcileSummary(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.OpeningBalance != 0 || sum.Difference != 5000 {
+		t.Errorf("savings summary = %+v, want nothing reconciled or cleared yet", sum)
+	}
+}
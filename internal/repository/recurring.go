@@ -0,0 +1,535 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+// Recurring schedule errors.
+var (
+	ErrRecurringRuleNotFound = errors.New("recurring rule not found")
+	ErrInvalidFrequency      = errors.New("unknown recurrence frequency")
+	ErrOccurrenceOutOfOrder  = errors.New("post or skip the earlier occurrences of this schedule first")
+	ErrOccurrencePast        = errors.New("this occurrence has already been posted or skipped")
+)
+
+// validateRecurringRule checks the schedule and that the template balances.
+func validateRecurringRule(rule *model.RecurringRule) error {
+	if rule.Name == "" {
+		return errors.New("recurring rule needs a name")
+	}
+	valid := false
+	for _, f := range model.RecurrenceFrequencies {
+		if rule.Frequency == f {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return ErrInvalidFrequency
+	}
+	if rule.Frequency == model.FrequencyEveryNDays && rule.IntervalDays <= 0 {
+		return errors.New("every-N-days schedules need an interval of at least one day")
+	}
+	if rule.StartDate.IsZero() {
+		return errors.New("recurring rule needs a start date")
+	}
+	if rule.EndDate != nil && rule.EndDate.Before(rule.StartDate) {
+		return errors.New("recurring rule ends before it starts")
+	}
+	if len(rule.Splits) < 2 {
+		return errors.New("recurring rule needs at least two splits")
+	}
+	return checkBalanced(&model.Transaction{Splits: rule.Splits})
+}
+
+// CreateRecurringRule stores a schedule and its split template.
+func (r *Repository) CreateRecurringRule(rule *model.RecurringRule) error {
+	if err := validateRecurringRule(rule); err != nil {
+		return err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO recurring_rules (name, note, frequency, interval_days, start_date, end_date, next_index, auto_post)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, rule.Name, rule.Note, rule.Frequency, rule.IntervalDays, rule.StartDate.Format(dateLayout),
+		nullableDate(rule.EndDate), rule.NextIndex, rule.AutoPost)
+	if err != nil {
+		return err
+	}
+	if rule.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+	if err := insertRecurringSplits(tx, rule); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func nullableDate(d *time.Time) interface{} {
+	if d == nil {
+		return nil
+	}
+	return d.Format(dateLayout)
+}
+
+func insertRecurringSplits(tx *sql.Tx, rule *model.RecurringRule) error {
+	for _, s := range rule.Splits {
+		_, err := tx.Exec("INSERT INTO recurring_splits (rule_id, account_id, category_id, amount, currency) VALUES (?, ?, ?, ?, ?)",
+			rule.ID, s.AccountID, s.CategoryID, s.Amount, s.Currency)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateRecurringRule saves changes to a schedule and its template. If the
+// start date, frequency or interval changes, counting restarts at the first
+// new occurrence on or after the one that was due next, and single
+// occurrence overrides are dropped.
+func (r *Repository) UpdateRecurringRule(rule *model.RecurringRule) error {
+	if err := validateRecurringRule(rule); err != nil {
+		return err
+	}
+	old, err := r.GetRecurringRule(rule.ID)
+	if err != nil {
+		return err
+	}
+
+	rescheduled := !old.StartDate.Equal(rule.StartDate) || old.Frequency != rule.Frequency ||
+		old.IntervalDays != rule.IntervalDays
+	rule.NextIndex = old.NextIndex
+	if rescheduled {
+		due := old.OccurrenceDate(old.NextIndex)
+		rule.NextIndex = 0
+		for rule.OccurrenceDate(rule.NextIndex).Before(due) {
+			rule.NextIndex++
+		}
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE recurring_rules SET name = ?, note = ?, frequency = ?, interval_days = ?, start_date = ?,
+			end_date = ?, next_index = ?, auto_post = ?
+		WHERE id = ?
+	`, rule.Name, rule.Note, rule.Frequency, rule.IntervalDays, rule.StartDate.Format(dateLayout),
+		nullableDate(rule.EndDate), rule.NextIndex, rule.AutoPost, rule.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM recurring_splits WHERE rule_id = ?", rule.ID); err != nil {
+		return err
+	}
+	if err := insertRecurringSplits(tx, rule); err != nil {
+		return err
+	}
+	if rescheduled {
+		if _, err := tx.Exec("DELETE FROM recurring_overrides WHERE rule_id = ?", rule.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// EndRecurringRule stops a series: nothing is scheduled after end.
+func (r *Repository) EndRecurringRule(id int64, end time.Time) error {
+	res, err := r.DB.Exec("UPDATE recurring_rules SET end_date = ? WHERE id = ?", end.Format(dateLayout), id)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(res, ErrRecurringRuleNotFound)
+}
+
+// DeleteRecurringRule removes a schedule. Transactions it already posted
+// are kept.
+func (r *Repository) DeleteRecurringRule(id int64) error {
+	res, err := r.DB.Exec("DELETE FROM recurring_rules WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(res, ErrRecurringRuleNotFound)
+}
+
+const recurringRuleColumns = `id, name, note, frequency, interval_days, start_date, end_date, next_index, auto_post`
+
+func scanRecurringRule(row interface{ Scan(...interface{}) error }) (*model.RecurringRule, error) {
+	var rule model.RecurringRule
+	var note sql.NullString
+	var end sql.NullTime
+	err := row.Scan(&rule.ID, &rule.Name, &note, &rule.Frequency, &rule.IntervalDays, &rule.StartDate,
+		&end, &rule.NextIndex, &rule.AutoPost)
+	if err != nil {
+		return nil, err
+	}
+	rule.Note = note.String
+	if end.Valid {
+		rule.EndDate = &end.Time
+	}
+	return &rule, nil
+}
+
+// GetRecurringRules returns every schedule with its template.
+func (r *Repository) GetRecurringRules() ([]model.RecurringRule, error) {
+	rows, err := r.DB.Query("SELECT " + recurringRuleColumns + " FROM recurring_rules ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	var rules []model.RecurringRule
+	for rows.Next() {
+		rule, err := scanRecurringRule(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range rules {
+		if rules[i].Splits, err = r.getRecurringSplits(rules[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// GetRecurringRule returns one schedule with its template.
+func (r *Repository) GetRecurringRule(id int64) (*model.RecurringRule, error) {
+	rule, err := scanRecurringRule(r.DB.QueryRow("SELECT "+recurringRuleColumns+" FROM recurring_rules WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrRecurringRuleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rule.Splits, err = r.getRecurringSplits(id); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *Repository) getRecurringSplits(ruleID int64) ([]model.Split, error) {
+	rows, err := r.DB.Query("SELECT account_id, category_id, amount, currency FROM recurring_splits WHERE rule_id = ? ORDER BY id", ruleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var splits []model.Split
+	for rows.Next() {
+		var s model.Split
+		var catID sql.NullInt64
+		var currency sql.NullString
+		if err := rows.Scan(&s.AccountID, &catID, &s.Amount, &currency); err != nil {
+			return nil, err
+		}
+		if catID.Valid {
+			id := catID.Int64
+			s.CategoryID = &id
+		}
+		s.Currency = currency.String
+		splits = append(splits, s)
+	}
+	return splits, rows.Err()
+}
+
+// SetRecurringOverride skips, moves or changes a single upcoming occurrence.
+func (r *Repository) SetRecurringOverride(o *model.RecurringOverride) error {
+	rule, err := r.GetRecurringRule(o.RuleID)
+	if err != nil {
+		return err
+	}
+	if o.Index < rule.NextIndex {
+		return ErrOccurrencePast
+	}
+	if o.Amount != nil && *o.Amount <= 0 {
+		return errors.New("occurrence amount must be greater than zero")
+	}
+
+	_, err = r.DB.Exec(`
+		INSERT INTO recurring_overrides (rule_id, occurrence_index, skip, date, amount, note) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(rule_id, occurrence_index) DO UPDATE SET
+			skip = excluded.skip, date = excluded.date, amount = excluded.amount, note = excluded.note
+	`, o.RuleID, o.Index, o.Skip, nullableDate(o.Date), o.Amount, o.Note)
+	return err
+}
+
+// ClearRecurringOverride puts an occurrence back to the rule's defaults.
+func (r *Repository) ClearRecurringOverride(ruleID int64, index int) error {
+	_, err := r.DB.Exec("DELETE FROM recurring_overrides WHERE rule_id = ? AND occurrence_index = ?", ruleID, index)
+	return err
+}
+
+func (r *Repository) getRecurringOverrides(ruleID int64) (map[int]model.RecurringOverride, error) {
+	rows, err := r.DB.Query("SELECT occurrence_index, skip, date, amount, note FROM recurring_overrides WHERE rule_id = ?", ruleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := make(map[int]model.RecurringOverride)
+	for rows.Next() {
+		o := model.RecurringOverride{RuleID: ruleID}
+		var date sql.NullTime
+		var amount sql.NullInt64
+		var note sql.NullString
+		if err := rows.Scan(&o.Index, &o.Skip, &date, &amount, &note); err != nil {
+			return nil, err
+		}
+		if date.Valid {
+			o.Date = &date.Time
+		}
+		if amount.Valid {
+			a := amount.Int64
+			o.Amount = &a
+		}
+		o.Note = note.String
+		overrides[o.Index] = o
+	}
+	return overrides, rows.Err()
+}
+
+// occurrence builds the n-th occurrence of a rule with its override applied.
+func occurrence(rule *model.RecurringRule, n int, overrides map[int]model.RecurringOverride) (model.RecurringOccurrence, error) {
+	occ := model.RecurringOccurrence{
+		RuleID:    rule.ID,
+		RuleName:  rule.Name,
+		Index:     n,
+		Scheduled: rule.OccurrenceDate(n),
+	}
+	t := model.Transaction{
+		Date:        occ.Scheduled,
+		Description: rule.Name,
+		Note:        rule.Note,
+		Status:      model.TransactionStatusPending,
+		Splits:      append([]model.Split(nil), rule.Splits...),
+	}
+
+	if o, ok := overrides[n]; ok {
+		occ.Overridden = true
+		occ.Skip = o.Skip
+		if o.Date != nil {
+			t.Date = *o.Date
+		}
+		if o.Note != "" {
+			t.Note = o.Note
+		}
+		if o.Amount != nil {
+			splits, err := ScaleTemplate(rule.Splits, *o.Amount)
+			if err != nil {
+				return occ, err
+			}
+			t.Splits = splits
+		}
+	}
+	occ.Transaction = t
+	return occ, nil
+}
+
+// ScaleTemplate spreads a new total over a template in proportion to its
+// splits, keeping it balanced: debits share total and credits share -total.
+func ScaleTemplate(template []model.Split, total int64) ([]model.Split, error) {
+	var debits, credits []int64
+	currency := ""
+	for i, s := range template {
+		if i > 0 && s.Currency != currency {
+			return nil, errors.New("cannot change the amount of a schedule that mixes currencies")
+		}
+		currency = s.Currency
+		if s.Amount > 0 {
+			debits = append(debits, s.Amount)
+		} else {
+			credits = append(credits, -s.Amount)
+		}
+	}
+	if len(debits) == 0 || len(credits) == 0 {
+		return nil, errors.New("schedule has nothing to scale")
+	}
+
+	debitParts, err := model.NewMoney(total, currency).Allocate(debits...)
+	if err != nil {
+		return nil, err
+	}
+	creditParts, err := model.NewMoney(total, currency).Allocate(credits...)
+	if err != nil {
+		return nil, err
+	}
+
+	splits := append([]model.Split(nil), template...)
+	for i := range splits {
+		if splits[i].Amount > 0 {
+			splits[i].Amount, debitParts = debitParts[0].Amount, debitParts[1:]
+		} else {
+			splits[i].Amount, creditParts = -creditParts[0].Amount, creditParts[1:]
+		}
+	}
+	return splits, nil
+}
+
+// GetUpcomingOccurrences lists the next count occurrences of a rule that
+// have not been posted or skipped yet, ending early if the series ends.
+func (r *Repository) GetUpcomingOccurrences(ruleID int64, count int) ([]model.RecurringOccurrence, error) {
+	rule, err := r.GetRecurringRule(ruleID)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := r.getRecurringOverrides(ruleID)
+	if err != nil {
+		return nil, err
+	}
+
+	var occs []model.RecurringOccurrence
+	for n := rule.NextIndex; len(occs) < count && rule.HasOccurrence(n); n++ {
+		occ, err := occurrence(rule, n, overrides)
+		if err != nil {
+			return nil, err
+		}
+		occs = append(occs, occ)
+	}
+	return occs, nil
+}
+
+// GetDueOccurrences lists, rule by rule and in order, every occurrence due
+// on or before asOf. An occurrence moved past asOf holds back the ones
+// after it.
+func (r *Repository) GetDueOccurrences(asOf time.Time) ([]model.RecurringOccurrence, error) {
+	rules, err := r.GetRecurringRules()
+	if err != nil {
+		return nil, err
+	}
+
+	y, m, d := asOf.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	var due []model.RecurringOccurrence
+	for i := range rules {
+		rule := &rules[i]
+		overrides, err := r.getRecurringOverrides(rule.ID)
+		if err != nil {
+			return nil, err
+		}
+		for n := rule.NextIndex; rule.HasOccurrence(n) && !rule.OccurrenceDate(n).After(today); n++ {
+			occ, err := occurrence(rule, n, overrides)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", rule.Name, err)
+			}
+			if !occ.Skip && occ.Transaction.Date.After(today) {
+				break
+			}
+			due = append(due, occ)
+		}
+	}
+	return due, nil
+}
+
+// PostOccurrence creates the transaction for an occurrence and moves the
+// schedule on. Occurrences of a rule must be handled in order.
+func (r *Repository) PostOccurrence(occ *model.RecurringOccurrence) error {
+	t := &occ.Transaction
+	if err := r.fillSplitRates(t); err != nil {
+		return err
+	}
+	if err := checkBalanced(t); err != nil {
+		return err
+	}
+	return r.advanceRecurring(occ, t)
+}
+
+// SkipOccurrence moves the schedule past an occurrence without posting it.
+func (r *Repository) SkipOccurrence(occ *model.RecurringOccurrence) error {
+	return r.advanceRecurring(occ, nil)
+}
+
+func (r *Repository) advanceRecurring(occ *model.RecurringOccurrence, t *model.Transaction) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE recurring_rules SET next_index = ? WHERE id = ? AND next_index = ?",
+		occ.Index+1, occ.RuleID, occ.Index)
+	if err != nil {
+		return err
+	}
+	if err := requireRowAffected(res, ErrOccurrenceOutOfOrder); err != nil {
+		return err
+	}
+	if t != nil {
+		m, err := loadPayeeMatcher(tx)
+		if err != nil {
+			return err
+		}
+		if err := insertTransaction(tx, m, t); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM recurring_overrides WHERE rule_id = ? AND occurrence_index = ?", occ.RuleID, occ.Index); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ProcessDueRecurring is run at startup. It posts due occurrences of
+// auto-post rules, passes over occurrences marked to skip, and returns the
+// remaining due occurrences for the user to confirm.
+func (r *Repository) ProcessDueRecurring(asOf time.Time) (int, []model.RecurringOccurrence, error) {
+	rules, err := r.GetRecurringRules()
+	if err != nil {
+		return 0, nil, err
+	}
+	autoPost := make(map[int64]bool, len(rules))
+	for _, rule := range rules {
+		autoPost[rule.ID] = rule.AutoPost
+	}
+
+	due, err := r.GetDueOccurrences(asOf)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	posted := 0
+	var pending []model.RecurringOccurrence
+	waiting := make(map[int64]bool) // Rules with an earlier occurrence awaiting confirmation
+	for i := range due {
+		occ := &due[i]
+		switch {
+		case waiting[occ.RuleID]:
+			if !occ.Skip {
+				pending = append(pending, *occ)
+			}
+		case occ.Skip:
+			if err := r.SkipOccurrence(occ); err != nil {
+				return posted, nil, err
+			}
+		case autoPost[occ.RuleID]:
+			if err := r.PostOccurrence(occ); err != nil {
+				return posted, nil, fmt.Errorf("%s: %w", occ.RuleName, err)
+			}
+			posted++
+		default:
+			waiting[occ.RuleID] = true
+			pending = append(pending, *occ)
+		}
+	}
+	return posted, pending, nil
+}
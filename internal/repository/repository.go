@@ -22,8 +22,8 @@ func NewRepository(db *DB) *Repository {
 // --- Accounts ---
 
 func (r *Repository) CreateAccount(account *model.Account) error {
-	query := `INSERT INTO accounts (name, type, currency) VALUES (?, ?, ?)`
-	res, err := r.DB.Exec(query, account.Name, account.Type, account.Currency)
+	query := `INSERT INTO accounts (name, type, currency, is_closed) VALUES (?, ?, ?, ?)`
+	res, err := r.DB.Exec(query, account.Name, account.Type, account.Currency, account.IsClosed)
 	if err != nil {
 		return err
 	}
@@ -35,8 +35,10 @@ func (r *Repository) CreateAccount(account *model.Account) error {
 	return nil
 }
 
+// GetAllAccounts returns every account, including closed ones, so that
+// reports and history can still resolve them. Pickers should filter on IsClosed.
 func (r *Repository) GetAllAccounts() ([]model.Account, error) {
-	rows, err := r.DB.Query("SELECT id, name, type, currency FROM accounts")
+	rows, err := r.DB.Query("SELECT id, name, type, currency, is_closed FROM accounts")
 	if err != nil {
 		return nil, err
 	}
@@ -45,7 +47,7 @@ func (r *Repository) GetAllAccounts() ([]model.Account, error) {
 	var accounts []model.Account
 	for rows.Next() {
 		var a model.Account
-		if err := rows.Scan(&a.ID, &a.Name, &a.Type, &a.Currency); err != nil {
+		if err := rows.Scan(&a.ID, &a.Name, &a.Type, &a.Currency, &a.IsClosed); err != nil {
 			return nil, err
 		}
 		accounts = append(accounts, a)
@@ -54,7 +56,7 @@ func (r *Repository) GetAllAccounts() ([]model.Account, error) {
 }
 
 func (r *Repository) GetAllCategories() ([]model.Category, error) {
-	rows, err := r.DB.Query("SELECT id, name, icon, color FROM categories")
+	rows, err := r.DB.Query("SELECT id, name, icon, color, parent_id FROM categories")
 	if err != nil {
 		return nil, err
 	}
@@ -64,7 +66,7 @@ func (r *Repository) GetAllCategories() ([]model.Category, error) {
 	for rows.Next() {
 		var c model.Category
 		var icon, color sql.NullString
-		if err := rows.Scan(&c.ID, &c.Name, &icon, &color); err != nil {
+		if err := rows.Scan(&c.ID, &c.Name, &icon, &color, &c.ParentID); err != nil {
 			return nil, err
 		}
 		if icon.Valid {
@@ -78,19 +80,50 @@ func (r *Repository) GetAllCategories() ([]model.Category, error) {
 	return categories, nil
 }
 
-func (r *Repository) GetAccountBalance(accountID int64) (float64, error) {
-	// Dynamically calculate balance from splits
-	// Sum(amount) where account_id = ?
-	// Since we store in cents, convert to float (div 100) for display.
-	var balanceCents sql.NullInt64
-	err := r.DB.QueryRow("SELECT SUM(amount) FROM splits WHERE account_id = ?", accountID).Scan(&balanceCents)
+// GetAccountBalance returns an account's current balance in its own
+// currency. Splits booked in another currency are converted at today's rate.
+func (r *Repository) GetAccountBalance(accountID int64) (model.Money, error) {
+	v, err := r.GetAccountValuation(accountID, time.Now())
 	if err != nil {
-		return 0, err
+		return model.Money{}, err
 	}
-	if !balanceCents.Valid {
-		return 0, nil
+	return model.NewMoney(v.Balance, v.Currency), nil
+}
+
+// GetAccountBalances returns the current balance of every balance-sheet
+// account with activity, keyed by account ID, valuing them all at once.
+func (r *Repository) GetAccountBalances() (map[int64]model.Money, error) {
+	vals, err := r.GetAccountValuations(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	balances := make(map[int64]model.Money, len(vals))
+	for _, v := range vals {
+		balances[v.AccountID] = model.NewMoney(v.Balance, v.Currency)
 	}
-	return float64(balanceCents.Int64) / 100.0, nil
+	return balances, nil
+}
+
+// GetAccountValuation values one account in the base currency as of asOf.
+func (r *Repository) GetAccountValuation(accountID int64, asOf time.Time) (model.AccountValuation, error) {
+	vals, err := r.GetAccountValuations(asOf)
+	if err != nil {
+		return model.AccountValuation{}, err
+	}
+	for _, v := range vals {
+		if v.AccountID == accountID {
+			return v, nil
+		}
+	}
+	// No activity yet
+	acc, err := r.GetAccountByID(accountID)
+	if err != nil {
+		return model.AccountValuation{}, err
+	}
+	if acc == nil {
+		return model.AccountValuation{}, ErrAccountNotFound
+	}
+	return model.AccountValuation{AccountID: acc.ID, AccountName: acc.Name, Type: acc.Type, Currency: acc.Currency}, nil
 }
 
 // --- Transactions (Double Entry) ---
@@ -98,13 +131,22 @@ func (r *Repository) GetAccountBalance(accountID int64) (float64, error) {
 // CreateTransaction inserts a header and its splits transactionally.
 // It explicitly checks that debits match credits (Sum of amounts == 0).
 func (r *Repository) CreateTransaction(t *model.Transaction) error {
+	m, err := loadPayeeMatcher(r.DB)
+	if err != nil {
+		return err
+	}
+	return r.createTransaction(m, t)
+}
+
+// createTransaction is CreateTransaction with the payee matcher loaded by
+// the caller, so imports load it once.
+func (r *Repository) createTransaction(m *payeeMatcher, t *model.Transaction) error {
 	// 1. Validate Balance
-	var sum int64 = 0
-	for _, s := range t.Splits {
-		sum += s.Amount
+	if err := r.fillSplitRates(t); err != nil {
+		return err
 	}
-	if sum != 0 {
-		return errors.New("transaction is not balanced (splits sum != 0)")
+	if err := checkBalanced(t); err != nil {
+		return err
 	}
 
 	tx, err := r.DB.Begin()
@@ -113,6 +155,14 @@ func (r *Repository) CreateTransaction(t *model.Transaction) error {
 	}
 	defer tx.Rollback()
 
+	if err := insertTransaction(tx, m, t); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertTransaction writes a validated transaction and its splits.
+func insertTransaction(tx *sql.Tx, m *payeeMatcher, t *model.Transaction) error {
 	// 2. Insert Header
 	query := `INSERT INTO transactions (date, description, note, status) VALUES (?, ?, ?, ?)`
 	res, err := tx.Exec(query, t.Date, t.Description, t.Note, t.Status)
@@ -126,76 +176,156 @@ func (r *Repository) CreateTransaction(t *model.Transaction) error {
 	t.ID = txID
 
 	// 3. Insert Splits
-	splitQuery := `INSERT INTO splits (transaction_id, account_id, category_id, amount, currency, exchange_rate) VALUES (?, ?, ?, ?, ?, ?)`
+	if err := insertSplits(tx, t); err != nil {
+		return err
+	}
+	if t.PayeeID, err = linkPayee(tx, m, txID, t.Description); err != nil {
+		return err
+	}
+	if len(t.Tags) > 0 {
+		return setTransactionTags(tx, txID, t.Tags)
+	}
+	return nil
+}
+
+// insertSplits writes a transaction's splits and their tags, each leg
+// taking the transaction's status.
+func insertSplits(tx *sql.Tx, t *model.Transaction) error {
+	status := t.Status
+	if status == "" {
+		status = model.TransactionStatusPending
+	}
+	splitQuery := `INSERT INTO splits (transaction_id, account_id, category_id, amount, currency, exchange_rate, status) VALUES (?, ?, ?, ?, ?, ?, ?)`
 	for i := range t.Splits {
 		s := &t.Splits[i]
-		s.TransactionID = txID
-		_, err = tx.Exec(splitQuery, s.TransactionID, s.AccountID, s.CategoryID, s.Amount, s.Currency, s.ExchangeRate)
+		s.TransactionID = t.ID
+		res, err := tx.Exec(splitQuery, s.TransactionID, s.AccountID, s.CategoryID, s.Amount, s.Currency, s.ExchangeRate, status)
 		if err != nil {
 			return err
 		}
+		if s.ID, err = res.LastInsertId(); err != nil {
+			return err
+		}
+		if len(s.Tags) > 0 {
+			if err := setSplitTags(tx, s.ID, s.Tags); err != nil {
+				return err
+			}
+		}
 	}
+	return nil
+}
 
-	return tx.Commit()
+// checkBalanced verifies that debits match credits. Splits in a single
+// currency must sum to exactly zero; a transaction that mixes currencies
+// (such as a foreign-currency transfer) must balance in the base currency,
+// allowing one minor unit of rounding per split.
+func checkBalanced(t *model.Transaction) error {
+	currencies := make(map[string]bool)
+	var sum int64
+	var baseSum float64
+	for _, s := range t.Splits {
+		currencies[s.Currency] = true
+		sum += s.Amount
+		baseSum += float64(s.Amount) * s.ExchangeRate
+	}
+	if len(currencies) <= 1 {
+		if sum != 0 {
+			return errors.New("transaction is not balanced (splits sum != 0)")
+		}
+		return nil
+	}
+	if math.Abs(baseSum) > float64(len(t.Splits)) {
+		return errors.New("transaction is not balanced (splits differ in base currency value)")
+	}
+	return nil
 }
 
-// GetRecentTransactions fetches transactions with their splits (simple version loading only headers first).
-// For a full UI, we often need the splits to know "Amount" (which is ambiguous in double entry)
-// Usually we show the sum of positive splits as "Amount" or specific account impact.
+// GetRecentTransactions returns the newest transactions with their splits.
+// Use SearchTransactions with a cursor to page further back.
 func (r *Repository) GetRecentTransactions(limit int) ([]model.Transaction, error) {
-	// Fetch Headers
-	query := `SELECT id, date, description, note, status FROM transactions ORDER BY date DESC LIMIT ?`
-	rows, err := r.DB.Query(query, limit)
+	transactions, _, err := r.SearchTransactions(&model.SearchQuery{}, nil, limit)
+	return transactions, err
+}
+
+func (r *Repository) GetSplitsForTransaction(txID int64) ([]model.Split, error) {
+	splits, err := r.splitsByTransaction([]int64{txID})
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return splits[txID], nil
+}
 
-	var transactions []model.Transaction
-	for rows.Next() {
-		var t model.Transaction
-		if err := rows.Scan(&t.ID, &t.Date, &t.Description, &t.Note, &t.Status); err != nil {
-			return nil, err
-		}
-		transactions = append(transactions, t)
+// splitsByTransaction loads the splits of several transactions, with their
+// tags, in one query for the splits and one for the tags.
+func (r *Repository) splitsByTransaction(txIDs []int64) (map[int64][]model.Split, error) {
+	splits := make(map[int64][]model.Split, len(txIDs))
+	if len(txIDs) == 0 {
+		return splits, nil
 	}
-
-	// Fetch splits for these transactions (N+1 query for simplicity now, optimize later with JOIN)
-	for i := range transactions {
-		splits, err := r.GetSplitsForTransaction(transactions[i].ID)
-		if err != nil {
-			return nil, err
-		}
-		transactions[i].Splits = splits
+	ids := make([]interface{}, len(txIDs))
+	for i, id := range txIDs {
+		ids[i] = id
 	}
+	in := "(?" + strings.Repeat(", ?", len(ids)-1) + ")"
 
-	return transactions, nil
-}
-
-func (r *Repository) GetSplitsForTransaction(txID int64) ([]model.Split, error) {
-	query := `SELECT id, transaction_id, account_id, category_id, amount, currency, exchange_rate FROM splits WHERE transaction_id = ?`
-	rows, err := r.DB.Query(query, txID)
+	rows, err := r.DB.Query(`SELECT id, transaction_id, account_id, category_id, amount, currency, exchange_rate
+		FROM splits WHERE transaction_id IN `+in+` ORDER BY transaction_id, id`, ids...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var splits []model.Split
 	for rows.Next() {
 		var s model.Split
 		if err := rows.Scan(&s.ID, &s.TransactionID, &s.AccountID, &s.CategoryID, &s.Amount, &s.Currency, &s.ExchangeRate); err != nil {
 			return nil, err
 		}
-		splits = append(splits, s)
+		splits[s.TransactionID] = append(splits[s.TransactionID], s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	tags, err := r.tagsByOwner(`
+		SELECT st.split_id, g.name FROM split_tags st
+		JOIN splits s ON s.id = st.split_id
+		JOIN tags g ON g.id = st.tag_id
+		WHERE s.transaction_id IN `+in+`
+		ORDER BY g.name COLLATE NOCASE
+	`, ids...)
+	if err != nil {
+		return nil, err
+	}
+	for _, list := range splits {
+		for i := range list {
+			list[i].Tags = tags[list[i].ID]
+		}
 	}
 	return splits, nil
 }
 
+// attachSplits loads the splits and tags of a page of transactions.
+func (r *Repository) attachSplits(transactions []model.Transaction) error {
+	ids := make([]int64, len(transactions))
+	for i, t := range transactions {
+		ids[i] = t.ID
+	}
+	splits, err := r.splitsByTransaction(ids)
+	if err != nil {
+		return err
+	}
+	for i := range transactions {
+		transactions[i].Splits = splits[transactions[i].ID]
+	}
+	return r.attachTags(transactions)
+}
+
 // GetTransactionByID retrieves a single transaction with its splits
 func (r *Repository) GetTransactionByID(txID int64) (*model.Transaction, error) {
-	query := `SELECT id, date, description, note, status FROM transactions WHERE id = ?`
+	query := `SELECT id, date, description, note, status, payee_id FROM transactions WHERE id = ?`
 	var t model.Transaction
-	err := r.DB.QueryRow(query, txID).Scan(&t.ID, &t.Date, &t.Description, &t.Note, &t.Status)
+	err := r.DB.QueryRow(query, txID).Scan(&t.ID, &t.Date, &t.Description, &t.Note, &t.Status, &t.PayeeID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -208,19 +338,23 @@ func (r *Repository) GetTransactionByID(txID int64) (*model.Transaction, error)
 		return nil, err
 	}
 	t.Splits = splits
+	if t.Tags, err = r.GetTransactionTags(txID); err != nil {
+		return nil, err
+	}
 	return &t, nil
 }
 
 // UpdateTransaction updates a transaction and its splits
-// It validates that splits sum to zero and performs the update atomically
-func (r *Repository) UpdateTransaction(t *model.Transaction) error {
+// It validates that splits sum to zero and performs the update atomically.
+// A Reconciled transaction is only changed when allowReconciled is set;
+// otherwise ErrTransactionReconciled is returned.
+func (r *Repository) UpdateTransaction(t *model.Transaction, allowReconciled bool) error {
 	// Validate balance
-	var sum int64 = 0
-	for _, s := range t.Splits {
-		sum += s.Amount
+	if err := r.fillSplitRates(t); err != nil {
+		return err
 	}
-	if sum != 0 {
-		return errors.New("transaction is not balanced (splits sum != 0)")
+	if err := checkBalanced(t); err != nil {
+		return err
 	}
 
 	tx, err := r.DB.Begin()
@@ -229,12 +363,40 @@ func (r *Repository) UpdateTransaction(t *model.Transaction) error {
 	}
 	defer tx.Rollback()
 
-	// Update transaction header
-	updateQuery := `UPDATE transactions SET date = ?, description = ?, note = ?, status = ? WHERE id = ?`
-	_, err = tx.Exec(updateQuery, t.Date, t.Description, t.Note, t.Status, t.ID)
+	current, err := transactionStatus(tx, t.ID)
+	if err != nil {
+		return err
+	}
+	if current == model.TransactionStatusReconciled && !allowReconciled {
+		return ErrTransactionReconciled
+	}
+	if t.Status == model.TransactionStatusReconciled && current != model.TransactionStatusReconciled {
+		return ErrReconciledStatusReserved
+	}
+
+	// Update transaction header; leaving Reconciled also detaches it from its statement
+	updateQuery := `UPDATE transactions SET date = ?, description = ?, note = ?, status = ?,
+		reconciliation_id = CASE WHEN ? = 'Reconciled' THEN reconciliation_id END
+		WHERE id = ?`
+	_, err = tx.Exec(updateQuery, t.Date, t.Description, t.Note, t.Status, t.Status, t.ID)
+	if err != nil {
+		return err
+	}
+	m, err := loadPayeeMatcher(tx)
 	if err != nil {
 		return err
 	}
+	if t.PayeeID, err = linkPayee(tx, m, t.ID, t.Description); err != nil {
+		return err
+	}
+
+	// Keep each account's cleared or reconciled state unless the status was changed
+	var legs []splitLegState
+	if t.Status == current {
+		if legs, err = splitLegStates(tx, t.ID); err != nil {
+			return err
+		}
+	}
 
 	// Delete existing splits
 	_, err = tx.Exec("DELETE FROM splits WHERE transaction_id = ?", t.ID)
@@ -243,21 +405,25 @@ func (r *Repository) UpdateTransaction(t *model.Transaction) error {
 	}
 
 	// Insert new splits
-	splitQuery := `INSERT INTO splits (transaction_id, account_id, category_id, amount, currency, exchange_rate) VALUES (?, ?, ?, ?, ?, ?)`
-	for i := range t.Splits {
-		s := &t.Splits[i]
-		s.TransactionID = t.ID
-		_, err = tx.Exec(splitQuery, s.TransactionID, s.AccountID, s.CategoryID, s.Amount, s.Currency, s.ExchangeRate)
-		if err != nil {
-			return err
-		}
+	if err := insertSplits(tx, t); err != nil {
+		return err
+	}
+	if err := restoreSplitLegStates(tx, t.ID, legs); err != nil {
+		return err
+	}
+	if err := syncTransactionStatus(tx, t.ID); err != nil {
+		return err
+	}
+	if err := setTransactionTags(tx, t.ID, t.Tags); err != nil {
+		return err
 	}
 
 	return tx.Commit()
 }
 
 // DeleteTransaction deletes a transaction and all its splits (CASCADE should handle splits, but we'll be explicit)
-func (r *Repository) DeleteTransaction(txID int64) error {
+// Like UpdateTransaction, a Reconciled transaction needs allowReconciled.
+func (r *Repository) DeleteTransaction(txID int64, allowReconciled bool) error {
 	// Foreign key CASCADE should handle splits, but let's be explicit for safety
 	tx, err := r.DB.Begin()
 	if err != nil {
@@ -265,12 +431,29 @@ func (r *Repository) DeleteTransaction(txID int64) error {
 	}
 	defer tx.Rollback()
 
+	current, err := transactionStatus(tx, txID)
+	if err != nil {
+		return err
+	}
+	if current == model.TransactionStatusReconciled && !allowReconciled {
+		return ErrTransactionReconciled
+	}
+
 	// Delete splits first
 	_, err = tx.Exec("DELETE FROM splits WHERE transaction_id = ?", txID)
 	if err != nil {
 		return err
 	}
 
+	// Delete attachments, and the stored files nothing else uses
+	_, err = tx.Exec("DELETE FROM attachments WHERE transaction_id = ?", txID)
+	if err != nil {
+		return err
+	}
+	if err := deleteOrphanAttachmentFiles(tx); err != nil {
+		return err
+	}
+
 	// Delete transaction
 	_, err = tx.Exec("DELETE FROM transactions WHERE id = ?", txID)
 	if err != nil {
@@ -280,24 +463,49 @@ func (r *Repository) DeleteTransaction(txID int64) error {
 	return tx.Commit()
 }
 
-// SearchTransactions searches transactions by description, date range, or amount range
-func (r *Repository) SearchTransactions(description string, startDate, endDate *time.Time, minAmount, maxAmount *float64, limit int) ([]model.Transaction, error) {
-	var conditions []string
-	var args []interface{}
-
-	if description != "" {
-		conditions = append(conditions, "t.description LIKE ?")
-		args = append(args, "%"+description+"%")
-	}
-
-	if startDate != nil {
-		conditions = append(conditions, "t.date >= ?")
-		args = append(args, startDate.Format("2006-01-02"))
-	}
+// TransactionCursor marks the last transaction of a page; pass it back to
+// SearchTransactions for the next page. It keeps the date as stored so the
+// comparison is exact.
+type TransactionCursor struct {
+	date string
+	id   int64
+	rank float64 // Relevance, for full-text searches
+}
 
-	if endDate != nil {
-		conditions = append(conditions, "t.date <= ?")
-		args = append(args, endDate.Format("2006-01-02"))
+// SearchTransactions returns a page of transactions matching every term
+// of a parsed search (see model.ParseSearchQuery and searchSQL), with the
+// cursor for the next page, or nil after the last one. Pass a nil cursor
+// for the first page.
+//
+// Full-text terms are looked up in transactions_fts, covering the
+// description, note, payee, tags and category names; results are then
+// ordered by relevance with a SearchMatch for highlighting. Otherwise they
+// are newest first. Pages are keyed on (date, id), so they stay consistent
+// however far back they go.
+func (r *Repository) SearchTransactions(q *model.SearchQuery, after *TransactionCursor, limit int) ([]model.Transaction, *TransactionCursor, error) {
+	match, conditions, args := searchSQL(q)
+
+	from := "transactions t"
+	columns := "t.id, t.date, t.description, t.note, t.status, t.payee_id, CAST(t.date AS TEXT)"
+	order := "t.date DESC, t.id DESC"
+	// Weights follow the column order: description, note, payee, tags, categories
+	const relevance = "bm25(transactions_fts, 10.0, 2.0, 8.0, 4.0, 3.0)"
+	if match != "" {
+		from = "transactions_fts JOIN transactions t ON t.id = transactions_fts.rowid"
+		columns += `, highlight(transactions_fts, 0, ?, ?), snippet(transactions_fts, -1, ?, ?, '…', 10), ` + relevance
+		conditions = append([]string{"transactions_fts MATCH ?"}, conditions...)
+		args = append([]interface{}{model.MatchStart, model.MatchEnd, model.MatchStart, model.MatchEnd, match}, args...)
+		order = relevance + ", " + order
+	}
+
+	if after != nil {
+		if match != "" {
+			conditions = append(conditions, "("+relevance+" > ? OR ("+relevance+" = ? AND (t.date, t.id) < (?, ?)))")
+			args = append(args, after.rank, after.rank, after.date, after.id)
+		} else {
+			conditions = append(conditions, "(t.date, t.id) < (?, ?)")
+			args = append(args, after.date, after.id)
+		}
 	}
 
 	whereClause := ""
@@ -305,78 +513,73 @@ func (r *Repository) SearchTransactions(description string, startDate, endDate *
 		whereClause = "WHERE " + strings.Join(conditions, " AND ")
 	}
 
-	// Base query
-	query := fmt.Sprintf(`SELECT DISTINCT t.id, t.date, t.description, t.note, t.status
-		FROM transactions t
-		LEFT JOIN splits s ON t.id = s.transaction_id
-		%s
-		ORDER BY t.date DESC LIMIT ?`, whereClause)
-
-	// If amount filters are specified, we need to join with splits and filter
-	if minAmount != nil || maxAmount != nil {
-		amountConditions := []string{}
-		if minAmount != nil {
-			amountConditions = append(amountConditions, "ABS(s.amount) >= ?")
-			args = append(args, int64(*minAmount*100))
-		}
-		if maxAmount != nil {
-			amountConditions = append(amountConditions, "ABS(s.amount) <= ?")
-			args = append(args, int64(*maxAmount*100))
-		}
-		if len(amountConditions) > 0 {
-			if whereClause == "" {
-				whereClause = "WHERE " + strings.Join(amountConditions, " AND ")
-			} else {
-				whereClause += " AND " + strings.Join(amountConditions, " AND ")
-			}
-			query = fmt.Sprintf(`SELECT DISTINCT t.id, t.date, t.description, t.note, t.status
-				FROM transactions t
-				JOIN splits s ON t.id = s.transaction_id
-				%s
-				ORDER BY t.date DESC LIMIT ?`, whereClause)
-		}
-	}
-
-	args = append(args, limit)
+	// One extra row tells whether there is another page
+	query := fmt.Sprintf("SELECT %s FROM %s %s ORDER BY %s LIMIT ?", columns, from, whereClause, order)
+	args = append(args, limit+1)
 
 	rows, err := r.DB.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
 	var transactions []model.Transaction
+	var next *TransactionCursor
+	more := false
 	for rows.Next() {
 		var t model.Transaction
-		if err := rows.Scan(&t.ID, &t.Date, &t.Description, &t.Note, &t.Status); err != nil {
-			return nil, err
+		var c TransactionCursor
+		dest := []interface{}{&t.ID, &t.Date, &t.Description, &t.Note, &t.Status, &t.PayeeID, &c.date}
+		if match != "" {
+			t.Match = &model.SearchMatch{}
+			dest = append(dest, &t.Match.Description, &t.Match.Snippet, &t.Match.Rank)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, nil, err
+		}
+		if len(transactions) == limit {
+			more = true
+			break
+		}
+		c.id = t.ID
+		if t.Match != nil {
+			c.rank = t.Match.Rank
 		}
+		next = &c
 		transactions = append(transactions, t)
 	}
-
-	// Fetch splits for these transactions
-	for i := range transactions {
-		splits, err := r.GetSplitsForTransaction(transactions[i].ID)
-		if err != nil {
-			return nil, err
-		}
-		transactions[i].Splits = splits
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
 	}
+	rows.Close()
 
-	return transactions, nil
+	if !more {
+		next = nil
+	}
+	if err := r.attachSplits(transactions); err != nil {
+		return nil, nil, err
+	}
+	return transactions, next, nil
 }
 
 // Stats (Updated for Double Entry)
 
 type DashboardStats struct {
-	TotalIncome    float64
-	TotalExpense   float64
-	TotalAssets    float64
-	TotalLiability float64
-	NetWorth       float64
+	TotalIncome    model.Money
+	TotalExpense   model.Money
+	TotalAssets    model.Money
+	TotalLiability model.Money
+	NetWorth       model.Money
 }
 
-func (r *Repository) GetDashboardStats() (*DashboardStats, error) {
+// GetDashboardStats values income, expenses, assets and liabilities in the
+// base currency as of asOf. Income and expenses are summed at the rates in
+// force when each transaction happened; balances use the asOf rate.
+func (r *Repository) GetDashboardStats(asOf time.Time) (*DashboardStats, error) {
+	base, err := r.GetBaseCurrency()
+	if err != nil {
+		return nil, err
+	}
 	stats := &DashboardStats{}
 
 	// We need to join splits with accounts to check account type
@@ -384,7 +587,7 @@ func (r *Repository) GetDashboardStats() (*DashboardStats, error) {
 	// Expense: Sum of splits where Account Type = Expense. (Positive debit)
 
 	// Helper query
-	sumByType := func(accType model.AccountType) (float64, error) {
+	sumByType := func(accType model.AccountType) (model.Money, error) {
 		var val sql.NullFloat64
 		// SUM(amount * exchange_rate)
 		// Note: Splits amount is Integer (cents). Exchange Rate is Real.
@@ -393,240 +596,58 @@ func (r *Repository) GetDashboardStats() (*DashboardStats, error) {
 			SELECT SUM(s.amount * s.exchange_rate)
 			FROM splits s
 			JOIN accounts a ON s.account_id = a.id
-			WHERE a.type = ?
+			JOIN transactions t ON s.transaction_id = t.id
+			WHERE a.type = ? AND t.date < ?
 		`
-		err := r.DB.QueryRow(query, accType).Scan(&val)
+		err := r.DB.QueryRow(query, accType, endOfDay(asOf)).Scan(&val)
 		if err != nil {
-			return 0, err
+			return model.Money{}, err
 		}
-		if !val.Valid { return 0, nil }
-		return val.Float64 / 100.0, nil
+		return baseCents(val, base), nil
 	}
 
 	inc, err := sumByType(model.AccountTypeIncome)
 	if err != nil { return nil, err }
-	stats.TotalIncome = math.Abs(inc) // Display positive
+	if stats.TotalIncome, err = inc.Abs(); err != nil { return nil, err } // Display positive
 
-	exp, err := sumByType(model.AccountTypeExpense)
-	if err != nil { return nil, err }
-	stats.TotalExpense = exp
-
-	// Assets (Sum of Cash, Bank, Invest)
-	// We can't use the simple helper for multiple types easily without modification.
-	// Let's do a manual query for assets.
-	var assetVal sql.NullFloat64
-	assetQuery := `
-		SELECT SUM(s.amount * s.exchange_rate)
-		FROM splits s
-		JOIN accounts a ON s.account_id = a.id
-		WHERE a.type IN (?, ?, ?)
-	`
-	err = r.DB.QueryRow(assetQuery, model.AccountTypeCash, model.AccountTypeBank, model.AccountTypeInvest).Scan(&assetVal)
+	stats.TotalExpense, err = sumByType(model.AccountTypeExpense)
 	if err != nil { return nil, err }
-	stats.TotalAssets = 0
-	if assetVal.Valid {
-		stats.TotalAssets = assetVal.Float64 / 100.0
-	}
 
-	liab, err := sumByType(model.AccountTypeLiability)
+	// Assets and liabilities are balances, revalued at the asOf rate
+	assets, liabilities, err := r.netWorthAt(asOf)
 	if err != nil { return nil, err }
-	stats.TotalLiability = math.Abs(liab) // Usually negative balance implies debt, but standard liability is Credit normal.
+	stats.TotalAssets = model.NewMoney(assets, base)
+	stats.TotalLiability = model.NewMoney(liabilities, base)
 
-	stats.NetWorth = stats.TotalAssets - stats.TotalLiability
+	stats.NetWorth, err = stats.TotalAssets.Sub(stats.TotalLiability)
+	if err != nil { return nil, err }
 
 	return stats, nil
 }
 
-// --- Budgets ---
-
-func (r *Repository) CreateBudget(b *model.Budget) error {
-	query := `INSERT INTO budgets (category_id, amount, period) VALUES (?, ?, ?)`
-	res, err := r.DB.Exec(query, b.CategoryID, b.Amount, b.Period)
-	if err != nil {
-		return err
+// baseCents rounds a SUM(amount * exchange_rate) to whole base-currency
+// cents, half to even.
+func baseCents(v sql.NullFloat64, base string) model.Money {
+	if !v.Valid {
+		return model.NewMoney(0, base)
 	}
-	id, err := res.LastInsertId()
-	if err != nil {
-		return err
-	}
-	b.ID = id
-	return nil
-}
-
-func (r *Repository) GetBudgetsWithProgress(month int, year int) ([]model.BudgetProgress, error) {
-	// For each budget, calculate spent amount in that category for the given month/year.
-	// We need to join splits -> transactions to filter by date.
-
-	// Get all budgets first
-	rows, err := r.DB.Query("SELECT b.category_id, c.name, b.amount FROM budgets b JOIN categories c ON b.category_id = c.id")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var progress []model.BudgetProgress
-
-	type budgetItem struct {
-		CatID int64
-		Name  string
-		Amount int64
-	}
-	var items []budgetItem
-
-	for rows.Next() {
-		var bi budgetItem
-		if err := rows.Scan(&bi.CatID, &bi.Name, &bi.Amount); err != nil {
-			return nil, err
-		}
-		items = append(items, bi)
-	}
-	rows.Close()
-
-	// For each, calculate spent
-	// Start/End of month
-	// Date string filter: start <= date < end
-	// SQLite date format YYYY-MM-DD
-	// Simple string match 'YYYY-MM%' works for month
-	dateFilter := fmt.Sprintf("%04d-%02d%%", year, month) // e.g. 2025-01%
-
-	for _, item := range items {
-		var spentCents sql.NullInt64
-		// Join splits -> transactions
-		// Filter by category_id AND date
-		// Amount is Debit (Positive) for Expenses.
-		query := `
-			SELECT SUM(s.amount)
-			FROM splits s
-			JOIN transactions t ON s.transaction_id = t.id
-			WHERE s.category_id = ?
-			AND t.date LIKE ?
-			AND s.amount > 0 -- Only sum debits (expenses)
-		`
-		err := r.DB.QueryRow(query, item.CatID, dateFilter).Scan(&spentCents)
-		if err != nil { return nil, err }
-
-		spent := 0.0
-		if spentCents.Valid {
-			spent = float64(spentCents.Int64) / 100.0
-		}
-
-		budgeted := float64(item.Amount) / 100.0
-		remaining := budgeted - spent
-		percent := 0.0
-		if budgeted > 0 {
-			percent = spent / budgeted
-		}
-
-		progress = append(progress, model.BudgetProgress{
-			CategoryName: item.Name,
-			Budgeted:     budgeted,
-			Spent:        spent,
-			Remaining:    remaining,
-			Percent:      percent,
-		})
-	}
-	return progress, nil
-}
-
-// --- Recurring Logic (Simple Heuristic) ---
-
-func (r *Repository) DetectRecurringPatterns() ([]model.Subscription, error) {
-	// 1. Group transactions by Description (Payee)
-	// 2. If count >= 2 and amounts are similar -> Candidate
-
-	// This is analytical, can be heavy.
-	query := `
-		SELECT t.description, COUNT(*) as cnt, AVG(s.amount) as avg_amt, MAX(t.date) as last_date
-		FROM transactions t
-		JOIN splits s ON s.transaction_id = t.id
-		WHERE s.amount > 0 -- Expenses only (debits)
-		AND t.date > date('now', '-3 months') -- Look back 3 months
-		GROUP BY t.description
-		HAVING cnt >= 2
-	`
-	rows, err := r.DB.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var subs []model.Subscription
-
-	for rows.Next() {
-		var desc string
-		var cnt int
-		var avgAmt float64
-		var lastDate time.Time
-
-		if err := rows.Scan(&desc, &cnt, &avgAmt, &lastDate); err != nil {
-			continue
-		}
-
-		// Naive: If found, assume Monthly for now
-		subs = append(subs, model.Subscription{
-			Name:      desc,
-			Amount:    avgAmt / 100.0,
-			Frequency: "Monthly?", // Heuristic needed for real frequency
-			NextDueDate: lastDate.AddDate(0, 1, 0).Format("2006-01-02"), // Assume +1 month
-		})
-	}
-
-	return subs, nil
-}
-
-// --- Anomaly Detection ---
-
-func (r *Repository) DetectAnomalies() ([]model.Anomaly, error) {
-	// 1. Large Transactions (Threshold: > $500 or just > $200 for demo)
-	// In production, this should be (Avg + 2*StdDev) per user.
-	// We'll use a hard threshold of $200.00 (20000 cents) for now.
-
-	query := `
-		SELECT t.date, t.description, s.amount
-		FROM transactions t
-		JOIN splits s ON s.transaction_id = t.id
-		WHERE s.amount > 20000 -- $200
-		AND t.date > date('now', '-1 month') -- Recent only
-		ORDER BY t.date DESC
-	`
-
-	rows, err := r.DB.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var anomalies []model.Anomaly
-
-	for rows.Next() {
-		var date time.Time
-		var desc string
-		var amt int64
-
-		if err := rows.Scan(&date, &desc, &amt); err != nil {
-			continue
-		}
-
-		anomalies = append(anomalies, model.Anomaly{
-			Type:        "Large Transaction",
-			Description: fmt.Sprintf("%s: $%.2f", desc, float64(amt)/100.0),
-			Severity:    model.SeverityMedium,
-			Date:        date.Format("2006-01-02"),
-		})
-	}
-
-	return anomalies, nil
+	return model.NewMoney(int64(math.RoundToEven(v.Float64)), base)
 }
 
 func (r *Repository) GetMonthlyStats(months int) ([]model.MonthlyStat, error) {
 	// Aggregate Income vs Expense for last N months.
 	// We need 12 rows (or N), with 0 if no data.
-	// SQLite date grouping: strftime('%Y-%m', date)
+	// Only income/expense legs count, so transfers between own accounts are left out
+	// Dates are stored as text starting YYYY-MM-DD, so group on the prefix
+	base, err := r.GetBaseCurrency()
+	if err != nil {
+		return nil, err
+	}
 
 	query := `
-		SELECT strftime('%Y-%m', t.date) as month_key,
-			   SUM(CASE WHEN a.type = 'Income' THEN ABS(s.amount) ELSE 0 END) as income,
-			   SUM(CASE WHEN a.type = 'Expense' THEN s.amount ELSE 0 END) as expense
+		SELECT substr(t.date, 1, 7) as month_key,
+			   SUM(CASE WHEN a.type = 'Income' THEN ABS(s.amount) * s.exchange_rate ELSE 0 END) as income,
+			   SUM(CASE WHEN a.type = 'Expense' THEN s.amount * s.exchange_rate ELSE 0 END) as expense
 		FROM transactions t
 		JOIN splits s ON s.transaction_id = t.id
 		JOIN accounts a ON s.account_id = a.id
@@ -657,8 +678,8 @@ func (r *Repository) GetMonthlyStats(months int) ([]model.MonthlyStat, error) {
 
 		stats = append(stats, model.MonthlyStat{
 			Month: monthName,
-			Income: inc.Float64 / 100.0,
-			Expense: exp.Float64 / 100.0,
+			Income: baseCents(inc, base),
+			Expense: baseCents(exp, base),
 		})
 	}
 
@@ -669,31 +690,30 @@ func (r *Repository) GetMonthlyStats(months int) ([]model.MonthlyStat, error) {
 
 type ProjectionPoint struct {
 	Month string
-	Value float64
+	Value model.Money
 }
 
-func (r *Repository) GetNetWorthProjection(monthsAhead int) ([]ProjectionPoint, float64, error) {
+func (r *Repository) GetNetWorthProjection(monthsAhead int) ([]ProjectionPoint, model.Money, error) {
 	// 1. Get Current Net Worth
-	stats, err := r.GetDashboardStats()
-	if err != nil { return nil, 0, err }
+	stats, err := r.GetDashboardStats(time.Now())
+	if err != nil { return nil, model.Money{}, err }
 	currentNW := stats.NetWorth
 
 	// 2. Calculate Avg Monthly Savings (Last 3 months)
 	// Query already exists essentially in GetMonthlyStats
 	monthlyData, err := r.GetMonthlyStats(3)
-	if err != nil { return nil, 0, err }
+	if err != nil { return nil, model.Money{}, err }
 
-	var totalSavings float64
-	var count float64
+	totalSavings := model.NewMoney(0, currentNW.Currency)
 	for _, m := range monthlyData {
-		savings := m.Income - m.Expense
-		totalSavings += savings
-		count++
+		savings, err := m.Income.Sub(m.Expense)
+		if err != nil { return nil, model.Money{}, err }
+		if totalSavings, err = totalSavings.Add(savings); err != nil { return nil, model.Money{}, err }
 	}
 
-	avgSavings := 0.0
-	if count > 0 {
-		avgSavings = totalSavings / count
+	avgSavings := totalSavings
+	if len(monthlyData) > 0 {
+		if avgSavings, err = totalSavings.Div(int64(len(monthlyData))); err != nil { return nil, model.Money{}, err }
 	}
 
 	// 3. Project
@@ -703,7 +723,7 @@ func (r *Repository) GetNetWorthProjection(monthsAhead int) ([]ProjectionPoint,
 	runningNW := currentNW
 
 	for i := 1; i <= monthsAhead; i++ {
-		runningNW += avgSavings
+		if runningNW, err = runningNW.Add(avgSavings); err != nil { return nil, model.Money{}, err }
 		futureDate := now.AddDate(0, i, 0)
 		points = append(points, ProjectionPoint{
 			Month: futureDate.Format("Jan 06"),
@@ -715,68 +735,10 @@ func (r *Repository) GetNetWorthProjection(monthsAhead int) ([]ProjectionPoint,
 }
 
 
-func (r *Repository) CreateRule(rule *model.Rule) error {
-	query := `INSERT INTO rules (pattern, target_category_id, target_payee, target_note) VALUES (?, ?, ?, ?)`
-	res, err := r.DB.Exec(query, rule.Pattern, rule.TargetCategoryID, rule.TargetPayee, rule.TargetNote)
-	if err != nil {
-		return err
-	}
-	id, err := res.LastInsertId()
-	if err != nil {
-		return err
-	}
-	rule.ID = id
-	return nil
-}
-
-func (r *Repository) EnrichTransaction(description string) (string, *int64, string) {
-	// Returns: (NormalizedPayee, CategoryID, Note)
-	// Naive implementation: fetch all rules and regex match.
-	// For small rule set this is fine.
-
-	rows, err := r.DB.Query("SELECT pattern, target_category_id, target_payee, target_note FROM rules")
-	if err != nil {
-		return description, nil, ""
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var pattern string
-		var catID *int64
-		var payee sql.NullString
-		var note sql.NullString
-
-		if err := rows.Scan(&pattern, &catID, &payee, &note); err != nil {
-			continue
-		}
-
-		// Simple substring match for now (strings.Contains)
-		// Ideally use Regex if pattern starts with ^ or similar
-		if contains(description, pattern) {
-			newDesc := description
-			if payee.Valid && payee.String != "" {
-				newDesc = payee.String
-			}
-			newNote := ""
-			if note.Valid && note.String != "" {
-				newNote = note.String
-			}
-			return newDesc, catID, newNote
-		}
-	}
-
-	return description, nil, ""
-}
-
-// Helper
-func contains(s, substr string) bool {
-	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
-}
-
 func (r *Repository) GetAccountByName(name string) (*model.Account, error) {
-	query := `SELECT id, name, type, currency FROM accounts WHERE name = ?`
+	query := `SELECT id, name, type, currency, is_closed FROM accounts WHERE name = ?`
 	var a model.Account
-	err := r.DB.QueryRow(query, name).Scan(&a.ID, &a.Name, &a.Type, &a.Currency)
+	err := r.DB.QueryRow(query, name).Scan(&a.ID, &a.Name, &a.Type, &a.Currency, &a.IsClosed)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Not found
@@ -786,114 +748,62 @@ func (r *Repository) GetAccountByName(name string) (*model.Account, error) {
 	return &a, nil
 }
 
-// GetCategoryBreakdown returns spending by category for a given time period
+// GetCategoryBreakdown returns spending by top-level category for a given
+// time period. Spending on subcategories is rolled up into their parents.
 func (r *Repository) GetCategoryBreakdown(startDate, endDate *time.Time) ([]model.CategoryBreakdown, error) {
-	query := `
-		SELECT c.id, c.name, c.color, SUM(s.amount) as total
-		FROM categories c
-		JOIN splits s ON s.category_id = c.id
-		JOIN transactions t ON s.transaction_id = t.id
-		WHERE s.amount > 0
-	`
-	var args []interface{}
+	return r.GetSubcategoryBreakdown(0, startDate, endDate)
+}
 
-	if startDate != nil {
-		query += " AND t.date >= ?"
-		args = append(args, startDate.Format("2006-01-02"))
-	}
-	if endDate != nil {
-		query += " AND t.date <= ?"
-		args = append(args, endDate.Format("2006-01-02"))
+// GetSubcategoryBreakdown drills into one category: it returns spending for
+// each direct child (with their own children rolled up), plus a row for
+// anything booked directly against the parent. A parentID of 0 means the top level.
+func (r *Repository) GetSubcategoryBreakdown(parentID int64, startDate, endDate *time.Time) ([]model.CategoryBreakdown, error) {
+	tree, err := r.loadCategoryTree()
+	if err != nil {
+		return nil, err
 	}
-
-	query += " GROUP BY c.id, c.name, c.color ORDER BY total DESC"
-
-	rows, err := r.DB.Query(query, args...)
+	totals, err := r.spendingByCategory(startDate, endDate)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var breakdowns []model.CategoryBreakdown
-	for rows.Next() {
-		var cb model.CategoryBreakdown
-		var totalCents sql.NullInt64
-		var color sql.NullString
-		if err := rows.Scan(&cb.CategoryID, &cb.CategoryName, &color, &totalCents); err != nil {
-			continue
-		}
-		if color.Valid {
-			cb.Color = color.String
-		}
-		if totalCents.Valid {
-			cb.Amount = float64(totalCents.Int64) / 100.0
-		}
-		breakdowns = append(breakdowns, cb)
+	base, err := r.GetBaseCurrency()
+	if err != nil {
+		return nil, err
 	}
-	return breakdowns, nil
+	return tree.breakdownUnder(parentID, totals, base), nil
 }
 
-// GetNetWorthHistory returns net worth values over time
-func (r *Repository) GetNetWorthHistory(months int) ([]model.NetWorthPoint, error) {
-	// Calculate net worth at the end of each month using cumulative sums
-	// SQLite doesn't support window functions in older versions, so we calculate in Go
-	query := `
-		SELECT
-			strftime('%Y-%m', t.date) as month_key,
-			SUM(CASE WHEN a.type IN ('Cash', 'Bank', 'Investment') THEN s.amount * s.exchange_rate ELSE 0 END) as assets,
-			SUM(CASE WHEN a.type = 'Liability' THEN ABS(s.amount * s.exchange_rate) ELSE 0 END) as liabilities
-		FROM transactions t
-		JOIN splits s ON t.id = s.transaction_id
-		JOIN accounts a ON s.account_id = a.id
-		WHERE t.date >= date('now', ?)
-		GROUP BY month_key
-		ORDER BY month_key ASC
-	`
-
-	dateParam := fmt.Sprintf("-%d months", months)
-	rows, err := r.DB.Query(query, dateParam)
+// GetNetWorthHistory returns month-end net worth for the months months
+// ending with the month containing asOf, valued in the base currency at
+// each month-end's exchange rates. The last point is valued on asOf itself.
+func (r *Repository) GetNetWorthHistory(months int, asOf time.Time) ([]model.NetWorthPoint, error) {
+	base, err := r.GetBaseCurrency()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	type monthlyData struct {
-		MonthKey   string
-		Assets     float64
-		Liabilities float64
-	}
+	var points []model.NetWorthPoint
+	first := monthStart(asOf).AddDate(0, -(months - 1), 0)
+	for i := 0; i < months; i++ {
+		month := first.AddDate(0, i, 0)
+		day := month.AddDate(0, 1, -1)
+		if day.After(asOf) {
+			day = asOf
+		}
 
-	var monthlyDataList []monthlyData
-	for rows.Next() {
-		var md monthlyData
-		var assetsCents, liabilitiesCents sql.NullInt64
-		if err := rows.Scan(&md.MonthKey, &assetsCents, &liabilitiesCents); err != nil {
-			continue
+		assets, liabilities, err := r.netWorthAt(day)
+		if err != nil {
+			return nil, err
 		}
-		if assetsCents.Valid {
-			md.Assets = float64(assetsCents.Int64) / 100.0
+		p := model.NetWorthPoint{
+			Month:       month.Format("Jan 06"),
+			Assets:      model.NewMoney(assets, base),
+			Liabilities: model.NewMoney(liabilities, base),
 		}
-		if liabilitiesCents.Valid {
-			md.Liabilities = float64(liabilitiesCents.Int64) / 100.0
+		if p.NetWorth, err = p.Assets.Sub(p.Liabilities); err != nil {
+			return nil, err
 		}
-		monthlyDataList = append(monthlyDataList, md)
-	}
-
-	// Calculate cumulative values
-	var points []model.NetWorthPoint
-	var cumulativeAssets, cumulativeLiabilities float64
-
-	for _, md := range monthlyDataList {
-		cumulativeAssets += md.Assets
-		cumulativeLiabilities += md.Liabilities
-
-		t, _ := time.Parse("2006-01", md.MonthKey)
-		points = append(points, model.NetWorthPoint{
-			Month:       t.Format("Jan 06"),
-			Assets:       cumulativeAssets,
-			Liabilities: cumulativeLiabilities,
-			NetWorth:    cumulativeAssets - cumulativeLiabilities,
-		})
+		points = append(points, p)
 	}
 
 	return points, nil
@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+// RecomputeBudgetRollovers saves the rollover ledger for auditing.
+// Every closed period of a rollover budget gets one row recording what was
+// budgeted, what was spent and what carried in and out. Budget progress
+// works the carry-over out from splits on each read, so it already
+// reflects edits to past transactions; this stores the same figures.
+// It returns the number of ledger rows written.
+func (r *Repository) RecomputeBudgetRollovers() (int, error) {
+	series, err := r.budgetSeries()
+	if err != nil {
+		return 0, err
+	}
+	tree, err := r.loadCategoryTree()
+	if err != nil {
+		return 0, err
+	}
+
+	y, m, d := time.Now().Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	var entries []model.RolloverEntry
+	for _, s := range series {
+		catEntries, _, err := r.rolloverSeries(s, tree.subtree(s[0].CategoryID), today)
+		if err != nil {
+			return 0, err
+		}
+		entries = append(entries, catEntries...)
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM budget_rollovers"); err != nil {
+		return 0, err
+	}
+	stmt, err := tx.Prepare(`
+		INSERT INTO budget_rollovers (category_id, budget_id, period_start, period_end, budgeted, spent, carried_in, carried_out)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for _, e := range entries {
+		_, err := stmt.Exec(e.CategoryID, e.BudgetID, e.PeriodStart.Format(dateLayout), e.PeriodEnd.Format(dateLayout),
+			e.Budgeted, e.Spent, e.CarriedIn, e.CarriedOut)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// budgetSeries groups the recurring budgets of each category that has a
+// rollover budget, oldest first.
+func (r *Repository) budgetSeries() ([][]model.Budget, error) {
+	budgets, err := r.GetBudgets()
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[int64]int)
+	var series [][]model.Budget
+	for _, b := range budgets {
+		if b.Period == model.BudgetPeriodCustom {
+			continue
+		}
+		i, ok := index[b.CategoryID]
+		if !ok {
+			i = len(series)
+			index[b.CategoryID] = i
+			series = append(series, nil)
+		}
+		series[i] = append(series[i], b)
+	}
+
+	kept := series[:0]
+	for _, s := range series {
+		for _, b := range s {
+			if b.Rollover {
+				kept = append(kept, s)
+				break
+			}
+		}
+	}
+	return kept, nil
+}
+
+// dailySpending returns expense debits per day in the base currency for a
+// set of categories from start on, oldest first.
+func (r *Repository) dailySpending(catIDs []int64, start time.Time) ([]time.Time, []int64, error) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(catIDs)), ",")
+	args := make([]interface{}, 0, len(catIDs)+1)
+	for _, id := range catIDs {
+		args = append(args, id)
+	}
+	args = append(args, start.Format(dateLayout))
+
+	rows, err := r.DB.Query(`
+		SELECT substr(t.date, 1, 10) AS day, CAST(SUM(ROUND(s.amount * s.exchange_rate)) AS INTEGER)
+		FROM splits s
+		JOIN transactions t ON s.transaction_id = t.id
+		WHERE s.category_id IN (`+placeholders+`)
+		AND t.date >= ?
+		AND s.amount > 0
+		GROUP BY day
+		ORDER BY day
+	`, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	var amounts []int64
+	for rows.Next() {
+		var day string
+		var amount int64
+		if err := rows.Scan(&day, &amount); err != nil {
+			return nil, nil, err
+		}
+		d, err := time.Parse(dateLayout, day)
+		if err != nil {
+			return nil, nil, err
+		}
+		days = append(days, d)
+		amounts = append(amounts, amount)
+	}
+	return days, amounts, rows.Err()
+}
+
+// rolloverSeries walks the closed periods of one category's recurring
+// budgets before until, carrying each period's surplus or deficit into
+// the next, and returns the ledger with the amount carried out of the
+// last closed period. A period whose budget has rollover off resets the
+// carry to zero. The walk starts at the first period with spending, so
+// budgets predating any transactions do not pile up unspent periods.
+func (r *Repository) rolloverSeries(series []model.Budget, catIDs []int64, until time.Time) ([]model.RolloverEntry, int64, error) {
+	days, amounts, err := r.dailySpending(catIDs, series[0].EffectiveFrom)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(days) == 0 {
+		return nil, 0, nil
+	}
+
+	budgetOn := func(day time.Time) model.Budget {
+		b := series[0]
+		for _, candidate := range series {
+			if candidate.EffectiveFrom.After(day) {
+				break
+			}
+			b = candidate
+		}
+		return b
+	}
+	cursor, _ := periodWindow(budgetOn(days[0]).Period, days[0])
+	if cursor.Before(series[0].EffectiveFrom) {
+		cursor = series[0].EffectiveFrom
+	}
+
+	var entries []model.RolloverEntry
+	var carry int64
+	next := 0 // First day in days not yet counted
+	for cursor.Before(until) {
+		b := budgetOn(cursor)
+		start, end := periodWindow(b.Period, cursor)
+		if end.After(until) {
+			break // The current period is still open
+		}
+		// Switching to a longer period mid-way must not count days twice
+		if start.Before(cursor) {
+			start = cursor
+		}
+
+		var spent int64
+		for next < len(days) && days[next].Before(end) {
+			spent += amounts[next]
+			next++
+		}
+		if !b.Rollover {
+			carry = 0
+			cursor = end
+			continue
+		}
+
+		e := model.RolloverEntry{
+			CategoryID:  b.CategoryID,
+			BudgetID:    b.ID,
+			PeriodStart: start,
+			PeriodEnd:   end.AddDate(0, 0, -1),
+			Budgeted:    b.Amount,
+			Spent:       spent,
+			CarriedIn:   carry,
+			CarriedOut:  carry + b.Amount - spent,
+			ComputedAt:  time.Now(),
+		}
+		entries = append(entries, e)
+		carry = e.CarriedOut
+		cursor = end
+	}
+	return entries, carry, nil
+}
+
+// rolloverCarries returns, per category with a rollover budget, the ledger
+// of periods closed before asOf and the amount carried into the open one.
+func (r *Repository) rolloverCarries(tree *categoryTree, asOf time.Time) (map[int64][]model.RolloverEntry, map[int64]int64, error) {
+	series, err := r.budgetSeries()
+	if err != nil {
+		return nil, nil, err
+	}
+	ledgers := make(map[int64][]model.RolloverEntry, len(series))
+	carries := make(map[int64]int64, len(series))
+	for _, s := range series {
+		catID := s[0].CategoryID
+		entries, carry, err := r.rolloverSeries(s, tree.subtree(catID), asOf)
+		if err != nil {
+			return nil, nil, err
+		}
+		ledgers[catID], carries[catID] = entries, carry
+	}
+	return ledgers, carries, nil
+}
+
+// GetRolloverLedger returns a category's rollover history up to today,
+// newest first. It is worked out from splits, so it follows edits to past
+// transactions without waiting for RecomputeBudgetRollovers.
+func (r *Repository) GetRolloverLedger(categoryID int64) ([]model.RolloverEntry, error) {
+	tree, err := r.loadCategoryTree()
+	if err != nil {
+		return nil, err
+	}
+	y, m, d := time.Now().Date()
+	ledgers, _, err := r.rolloverCarries(tree, time.Date(y, m, d, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		return nil, err
+	}
+	entries := ledgers[categoryID]
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
@@ -0,0 +1,750 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+var (
+	ErrRuleNotFound       = errors.New("rule not found")
+	ErrRuleNoConditions   = errors.New("a rule needs at least one condition")
+	ErrRuleNoActions      = errors.New("a rule needs at least one action")
+	ErrInvalidRulePattern = errors.New("invalid regular expression")
+	ErrInvalidRuleRange   = errors.New("amount and date ranges must start before they end")
+	ErrInvalidRuleStatus  = errors.New("rules can only mark transactions Pending or Cleared")
+	ErrRuleBatchNotFound  = errors.New("rule batch not found")
+	ErrRuleBatchUndone    = errors.New("this batch has already been undone")
+)
+
+// compiledRule is a validated rule ready to match transactions.
+type compiledRule struct {
+	model.Rule
+	re *regexp.Regexp
+}
+
+// compileRule validates a rule and compiles its pattern. Regular
+// expressions ignore case, like substring patterns.
+func compileRule(rule model.Rule) (*compiledRule, error) {
+	c := &compiledRule{Rule: rule}
+	if rule.IsRegex && rule.Pattern != "" {
+		re, err := regexp.Compile("(?i)" + rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRulePattern, err)
+		}
+		c.re = re
+	}
+	return c, nil
+}
+
+func validateRule(rule *model.Rule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if rule.Pattern == "" && rule.MinAmount == nil && rule.MaxAmount == nil && rule.AccountID == nil &&
+		rule.StartDate == nil && rule.EndDate == nil {
+		return ErrRuleNoConditions
+	}
+	if rule.MinAmount != nil && rule.MaxAmount != nil && *rule.MinAmount > *rule.MaxAmount {
+		return ErrInvalidRuleRange
+	}
+	if rule.StartDate != nil && rule.EndDate != nil && rule.StartDate.After(*rule.EndDate) {
+		return ErrInvalidRuleRange
+	}
+	switch rule.TargetStatus {
+	case "", model.TransactionStatusPending, model.TransactionStatusCleared:
+	case model.TransactionStatusReconciled:
+		return ErrReconciledStatusReserved
+	default:
+		return ErrInvalidRuleStatus
+	}
+	tags, err := cleanTags(rule.TargetTags)
+	if err != nil {
+		return err
+	}
+	rule.TargetTags = tags
+	if rule.TargetCategoryID == nil && rule.TargetPayee == "" && rule.TargetNote == "" &&
+		len(rule.TargetTags) == 0 && rule.TargetStatus == "" {
+		return ErrRuleNoActions
+	}
+	_, err = compileRule(*rule)
+	return err
+}
+
+// matches reports whether t meets every condition of the rule.
+func (c *compiledRule) matches(t *model.Transaction) bool {
+	if c.Pattern != "" {
+		if c.re != nil {
+			if !c.re.MatchString(t.Description) {
+				return false
+			}
+		} else if !strings.Contains(strings.ToLower(t.Description), strings.ToLower(c.Pattern)) {
+			return false
+		}
+	}
+
+	amount := transactionAmount(t)
+	if c.MinAmount != nil && amount < *c.MinAmount {
+		return false
+	}
+	if c.MaxAmount != nil && amount > *c.MaxAmount {
+		return false
+	}
+
+	if c.AccountID != nil {
+		found := false
+		for _, s := range t.Splits {
+			if s.AccountID == *c.AccountID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	day := t.Date.Format(dateLayout)
+	if c.StartDate != nil && day < c.StartDate.Format(dateLayout) {
+		return false
+	}
+	if c.EndDate != nil && day > c.EndDate.Format(dateLayout) {
+		return false
+	}
+	return true
+}
+
+// transactionAmount is the total of a transaction's positive splits, the
+// amount a user would call it.
+func transactionAmount(t *model.Transaction) int64 {
+	var total int64
+	for _, s := range t.Splits {
+		if s.Amount > 0 {
+			total += s.Amount
+		}
+	}
+	return total
+}
+
+// ruleEffect is what a list of rules does to one transaction.
+type ruleEffect struct {
+	categoryID *int64
+	payee      string
+	note       string
+	tags       []string
+	status     model.TransactionStatus
+}
+
+// add folds in a matching rule's actions; earlier rules win.
+func (e *ruleEffect) add(rule model.Rule) {
+	if e.categoryID == nil {
+		e.categoryID = rule.TargetCategoryID
+	}
+	if e.payee == "" {
+		e.payee = rule.TargetPayee
+	}
+	if e.note == "" {
+		e.note = rule.TargetNote
+	}
+	if e.status == "" {
+		e.status = rule.TargetStatus
+	}
+	for _, tag := range rule.TargetTags {
+		if !hasTag(e.tags, tag) {
+			e.tags = append(e.tags, tag)
+		}
+	}
+}
+
+// ruleCategorySplit picks the split a rule's category goes on: the largest
+// categorized split, or else the largest income or expense split.
+func ruleCategorySplit(t *model.Transaction, types map[int64]model.AccountType) int {
+	if i := largestSplit(t.Splits, func(s model.Split) bool { return s.CategoryID != nil }); i >= 0 {
+		return i
+	}
+	return largestSplit(t.Splits, func(s model.Split) bool { return !isBalanceSheet(types[s.AccountID]) })
+}
+
+// applyRuleEffect changes t and describes each change. Reconciled
+// transactions are left as they are.
+func applyRuleEffect(t *model.Transaction, e ruleEffect, types map[int64]model.AccountType, categoryName func(int64) string) []string {
+	if t.Status == model.TransactionStatusReconciled {
+		return nil
+	}
+	var changes []string
+	if e.payee != "" && e.payee != t.Description {
+		changes = append(changes, fmt.Sprintf("Payee: %s → %s", t.Description, e.payee))
+		t.Description = e.payee
+	}
+	if e.note != "" && e.note != t.Note {
+		changes = append(changes, fmt.Sprintf("Note: %q → %q", t.Note, e.note))
+		t.Note = e.note
+	}
+	if e.status != "" && e.status != t.Status {
+		changes = append(changes, fmt.Sprintf("Status: %s → %s", t.Status, e.status))
+		t.Status = e.status
+	}
+	if e.categoryID != nil {
+		if i := ruleCategorySplit(t, types); i >= 0 {
+			current := t.Splits[i].CategoryID
+			if current == nil || *current != *e.categoryID {
+				from := "none"
+				if current != nil {
+					from = categoryName(*current)
+				}
+				changes = append(changes, fmt.Sprintf("Category: %s → %s", from, categoryName(*e.categoryID)))
+				id := *e.categoryID
+				t.Splits[i].CategoryID = &id
+			}
+		}
+	}
+	var added []string
+	for _, tag := range e.tags {
+		if !hasTag(t.Tags, tag) {
+			t.Tags = append(t.Tags, tag)
+			added = append(added, tag)
+		}
+	}
+	if len(added) > 0 {
+		changes = append(changes, "Tags: +"+strings.Join(added, ", +"))
+	}
+	return changes
+}
+
+// ruleContext holds the lookups applying rules needs.
+type ruleContext struct {
+	types      map[int64]model.AccountType
+	categories map[int64]string
+}
+
+func (r *Repository) loadRuleContext() (*ruleContext, error) {
+	accounts, err := r.GetAllAccounts()
+	if err != nil {
+		return nil, err
+	}
+	categories, err := r.GetAllCategories()
+	if err != nil {
+		return nil, err
+	}
+	ctx := &ruleContext{
+		types:      make(map[int64]model.AccountType, len(accounts)),
+		categories: make(map[int64]string, len(categories)),
+	}
+	for _, a := range accounts {
+		ctx.types[a.ID] = a.Type
+	}
+	for _, c := range categories {
+		ctx.categories[c.ID] = c.Name
+	}
+	return ctx, nil
+}
+
+func (ctx *ruleContext) categoryName(id int64) string {
+	if name, ok := ctx.categories[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("#%d", id)
+}
+
+// ApplyRules runs every rule over a transaction that is about to be
+// saved, filling in its category, payee, note, tags and status.
+func (r *Repository) ApplyRules(t *model.Transaction) error {
+	rules, err := r.GetRules()
+	if err != nil {
+		return err
+	}
+	var effect ruleEffect
+	for _, rule := range rules {
+		c, err := compileRule(rule)
+		if err != nil {
+			continue // Saved rules are valid; skip any that predate validation
+		}
+		if c.matches(t) {
+			effect.add(rule)
+		}
+	}
+	ctx, err := r.loadRuleContext()
+	if err != nil {
+		return err
+	}
+	applyRuleEffect(t, effect, ctx.types, ctx.categoryName)
+	return nil
+}
+
+// --- Rule storage ---
+
+const ruleColumns = `id, name, priority, pattern, is_regex, min_amount, max_amount, account_id, start_date, end_date,
+	target_category_id, target_payee, target_note, target_tags, target_status`
+
+func scanRule(row interface{ Scan(...interface{}) error }) (model.Rule, error) {
+	var rule model.Rule
+	var payee, note sql.NullString
+	var tags, status string
+	err := row.Scan(&rule.ID, &rule.Name, &rule.Priority, &rule.Pattern, &rule.IsRegex, &rule.MinAmount, &rule.MaxAmount,
+		&rule.AccountID, &rule.StartDate, &rule.EndDate, &rule.TargetCategoryID, &payee, &note, &tags, &status)
+	if err != nil {
+		return model.Rule{}, err
+	}
+	rule.TargetPayee = payee.String
+	rule.TargetNote = note.String
+	rule.TargetTags = splitTags(tags)
+	rule.TargetStatus = model.TransactionStatus(status)
+	return rule, nil
+}
+
+// GetRules returns every rule in the order they apply.
+func (r *Repository) GetRules() ([]model.Rule, error) {
+	rows, err := r.DB.Query("SELECT " + ruleColumns + " FROM rules ORDER BY priority, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []model.Rule
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r *Repository) GetRule(id int64) (*model.Rule, error) {
+	rule, err := scanRule(r.DB.QueryRow("SELECT "+ruleColumns+" FROM rules WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrRuleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// CreateRule saves a new rule. A rule without a priority goes last.
+func (r *Repository) CreateRule(rule *model.Rule) error {
+	if err := validateRule(rule); err != nil {
+		return err
+	}
+	if rule.Priority == 0 {
+		var last sql.NullInt64
+		if err := r.DB.QueryRow("SELECT MAX(priority) FROM rules").Scan(&last); err != nil {
+			return err
+		}
+		rule.Priority = int(last.Int64) + 1
+	}
+
+	res, err := r.DB.Exec(`
+		INSERT INTO rules (name, priority, pattern, is_regex, min_amount, max_amount, account_id, start_date, end_date,
+			target_category_id, target_payee, target_note, target_tags, target_status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, rule.Name, rule.Priority, rule.Pattern, rule.IsRegex, rule.MinAmount, rule.MaxAmount, rule.AccountID,
+		rule.StartDate, rule.EndDate, rule.TargetCategoryID, rule.TargetPayee, rule.TargetNote,
+		joinTags(rule.TargetTags), rule.TargetStatus)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	rule.ID = id
+	return nil
+}
+
+func (r *Repository) UpdateRule(rule *model.Rule) error {
+	if err := validateRule(rule); err != nil {
+		return err
+	}
+	res, err := r.DB.Exec(`
+		UPDATE rules SET name = ?, priority = ?, pattern = ?, is_regex = ?, min_amount = ?, max_amount = ?,
+			account_id = ?, start_date = ?, end_date = ?, target_category_id = ?, target_payee = ?,
+			target_note = ?, target_tags = ?, target_status = ?
+		WHERE id = ?
+	`, rule.Name, rule.Priority, rule.Pattern, rule.IsRegex, rule.MinAmount, rule.MaxAmount, rule.AccountID,
+		rule.StartDate, rule.EndDate, rule.TargetCategoryID, rule.TargetPayee, rule.TargetNote,
+		joinTags(rule.TargetTags), rule.TargetStatus, rule.ID)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(res, ErrRuleNotFound)
+}
+
+// DeleteRule removes a rule. Batches it applied can still be undone.
+func (r *Repository) DeleteRule(id int64) error {
+	res, err := r.DB.Exec("DELETE FROM rules WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(res, ErrRuleNotFound)
+}
+
+// ReorderRules sets the priorities so the rules apply in the order of ids.
+func (r *Repository) ReorderRules(ids []int64) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for i, id := range ids {
+		res, err := tx.Exec("UPDATE rules SET priority = ? WHERE id = ?", i+1, id)
+		if err != nil {
+			return err
+		}
+		if err := requireRowAffected(res, ErrRuleNotFound); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// --- Applying rules to history ---
+
+// ruleCandidates loads the unreconciled transactions a rule could match,
+// narrowed by its date range and account, with their splits and tags.
+func (r *Repository) ruleCandidates(rule model.Rule) ([]model.Transaction, error) {
+	conditions := []string{"t.status != ?"}
+	args := []interface{}{model.TransactionStatusReconciled}
+	if rule.StartDate != nil {
+		conditions = append(conditions, "t.date >= ?")
+		args = append(args, rule.StartDate.Format(dateLayout))
+	}
+	if rule.EndDate != nil {
+		conditions = append(conditions, "t.date < ?")
+		args = append(args, rule.EndDate.AddDate(0, 0, 1).Format(dateLayout))
+	}
+	if rule.AccountID != nil {
+		conditions = append(conditions, "t.id IN (SELECT transaction_id FROM splits WHERE account_id = ?)")
+		args = append(args, *rule.AccountID)
+	}
+	where := "WHERE " + strings.Join(conditions, " AND ")
+
+	rows, err := r.DB.Query("SELECT t.id, t.date, t.description, t.note, t.status FROM transactions t "+where+" ORDER BY t.date DESC, t.id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	var transactions []model.Transaction
+	index := make(map[int64]int)
+	for rows.Next() {
+		var t model.Transaction
+		var note, status sql.NullString
+		if err := rows.Scan(&t.ID, &t.Date, &t.Description, &note, &status); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		t.Note = note.String
+		t.Status = model.TransactionStatus(status.String)
+		index[t.ID] = len(transactions)
+		transactions = append(transactions, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	subquery := "SELECT t.id FROM transactions t " + where
+	splitRows, err := r.DB.Query(`SELECT id, transaction_id, account_id, category_id, amount, currency, exchange_rate
+		FROM splits WHERE transaction_id IN (`+subquery+`) ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for splitRows.Next() {
+		var s model.Split
+		if err := splitRows.Scan(&s.ID, &s.TransactionID, &s.AccountID, &s.CategoryID, &s.Amount, &s.Currency, &s.ExchangeRate); err != nil {
+			splitRows.Close()
+			return nil, err
+		}
+		if i, ok := index[s.TransactionID]; ok {
+			transactions[i].Splits = append(transactions[i].Splits, s)
+		}
+	}
+	splitRows.Close()
+	if err := splitRows.Err(); err != nil {
+		return nil, err
+	}
+
+	tagRows, err := r.DB.Query(`SELECT tt.transaction_id, g.name FROM transaction_tags tt
+		JOIN tags g ON g.id = tt.tag_id
+		WHERE tt.transaction_id IN (`+subquery+`) ORDER BY g.name COLLATE NOCASE`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer tagRows.Close()
+	for tagRows.Next() {
+		var txID int64
+		var tag string
+		if err := tagRows.Scan(&txID, &tag); err != nil {
+			return nil, err
+		}
+		if i, ok := index[txID]; ok {
+			transactions[i].Tags = append(transactions[i].Tags, tag)
+		}
+	}
+	return transactions, tagRows.Err()
+}
+
+// PreviewRule tests a rule, saved or not, against existing transactions
+// and returns every match with what applying it would change, newest first.
+func (r *Repository) PreviewRule(rule model.Rule) ([]model.RuleMatch, error) {
+	if err := validateRule(&rule); err != nil {
+		return nil, err
+	}
+	c, err := compileRule(rule)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := r.ruleCandidates(rule)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := r.loadRuleContext()
+	if err != nil {
+		return nil, err
+	}
+
+	var effect ruleEffect
+	effect.add(rule)
+	var matches []model.RuleMatch
+	for _, t := range candidates {
+		if !c.matches(&t) {
+			continue
+		}
+		after := cloneTransaction(t)
+		matches = append(matches, model.RuleMatch{
+			Transaction: t,
+			Changes:     applyRuleEffect(&after, effect, ctx.types, ctx.categoryName),
+		})
+	}
+	return matches, nil
+}
+
+func cloneTransaction(t model.Transaction) model.Transaction {
+	c := t
+	c.Splits = append([]model.Split(nil), t.Splits...)
+	c.Tags = append([]string(nil), t.Tags...)
+	return c
+}
+
+// ApplyRuleToHistory applies a saved rule to every existing transaction it
+// matches, as one batch that UndoRuleBatch can reverse. Transactions the
+// rule would not change are left out of the batch; if none change, nothing
+// is recorded and the returned batch has no ID.
+func (r *Repository) ApplyRuleToHistory(ruleID int64) (*model.RuleBatch, error) {
+	rule, err := r.GetRule(ruleID)
+	if err != nil {
+		return nil, err
+	}
+	c, err := compileRule(*rule)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := r.ruleCandidates(*rule)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := r.loadRuleContext()
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &model.RuleBatch{RuleID: &rule.ID, RuleName: rule.Name, AppliedAt: time.Now()}
+	if batch.RuleName == "" {
+		batch.RuleName = rule.Pattern
+	}
+	var effect ruleEffect
+	effect.add(*rule)
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	payees, err := loadPayeeMatcher(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, before := range candidates {
+		if !c.matches(&before) {
+			continue
+		}
+		after := cloneTransaction(before)
+		if len(applyRuleEffect(&after, effect, ctx.types, ctx.categoryName)) == 0 {
+			continue
+		}
+
+		if batch.ID == 0 {
+			res, err := tx.Exec("INSERT INTO rule_batches (rule_id, rule_name, applied_at) VALUES (?, ?, ?)",
+				rule.ID, batch.RuleName, batch.AppliedAt)
+			if err != nil {
+				return nil, err
+			}
+			if batch.ID, err = res.LastInsertId(); err != nil {
+				return nil, err
+			}
+		}
+
+		// Remember the old category of the one split that can change
+		var splitID, oldCategory *int64
+		for i := range before.Splits {
+			b, a := before.Splits[i].CategoryID, after.Splits[i].CategoryID
+			if (b == nil) != (a == nil) || (b != nil && *b != *a) {
+				splitID, oldCategory = &before.Splits[i].ID, b
+				if _, err := tx.Exec("UPDATE splits SET category_id = ? WHERE id = ?", a, before.Splits[i].ID); err != nil {
+					return nil, err
+				}
+			}
+		}
+		_, err = tx.Exec(`
+			INSERT INTO rule_batch_changes (batch_id, transaction_id, description, note, status, split_id, category_id, tags)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, batch.ID, before.ID, before.Description, before.Note, before.Status, splitID, oldCategory, joinTags(before.Tags))
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := tx.Exec("UPDATE transactions SET description = ?, note = ? WHERE id = ?",
+			after.Description, after.Note, after.ID); err != nil {
+			return nil, err
+		}
+		if after.Status != before.Status {
+			if err := setTransactionStatus(tx, after.ID, after.Status); err != nil {
+				return nil, err
+			}
+		}
+		if after.Description != before.Description {
+			if _, err := linkPayee(tx, payees, after.ID, after.Description); err != nil {
+				return nil, err
+			}
+		}
+		if len(after.Tags) != len(before.Tags) {
+			if err := setTransactionTags(tx, after.ID, after.Tags); err != nil {
+				return nil, err
+			}
+		}
+		batch.Changed++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// GetRuleBatches lists the most recent rule batches, newest first.
+func (r *Repository) GetRuleBatches(limit int) ([]model.RuleBatch, error) {
+	rows, err := r.DB.Query(`
+		SELECT b.id, b.rule_id, b.rule_name, b.applied_at, b.undone_at,
+			(SELECT COUNT(*) FROM rule_batch_changes c WHERE c.batch_id = b.id)
+		FROM rule_batches b
+		ORDER BY b.id DESC LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []model.RuleBatch
+	for rows.Next() {
+		var b model.RuleBatch
+		if err := rows.Scan(&b.ID, &b.RuleID, &b.RuleName, &b.AppliedAt, &b.UndoneAt, &b.Changed); err != nil {
+			return nil, err
+		}
+		batches = append(batches, b)
+	}
+	return batches, rows.Err()
+}
+
+// UndoRuleBatch puts back the descriptions, notes, statuses, categories
+// and tags a batch replaced. A transaction reconciled since is left as it
+// is; one deleted since is simply gone.
+func (r *Repository) UndoRuleBatch(batchID int64) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var undone *time.Time
+	err = tx.QueryRow("SELECT undone_at FROM rule_batches WHERE id = ?", batchID).Scan(&undone)
+	if err == sql.ErrNoRows {
+		return ErrRuleBatchNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if undone != nil {
+		return ErrRuleBatchUndone
+	}
+
+	rows, err := tx.Query(`
+		SELECT transaction_id, description, note, status, split_id, category_id, tags
+		FROM rule_batch_changes WHERE batch_id = ?
+	`, batchID)
+	if err != nil {
+		return err
+	}
+	type change struct {
+		txID                 int64
+		description          string
+		note, status         sql.NullString
+		splitID, oldCategory *int64
+		tags                 string
+	}
+	var changes []change
+	for rows.Next() {
+		var c change
+		if err := rows.Scan(&c.txID, &c.description, &c.note, &c.status, &c.splitID, &c.oldCategory, &c.tags); err != nil {
+			rows.Close()
+			return err
+		}
+		changes = append(changes, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	payees, err := loadPayeeMatcher(tx)
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		status, err := transactionStatus(tx, c.txID)
+		if err == ErrTransactionNotFound || status == model.TransactionStatusReconciled {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE transactions SET description = ?, note = ? WHERE id = ?", c.description, c.note, c.txID)
+		if err != nil {
+			return err
+		}
+		if c.status.Valid {
+			if err := setTransactionStatus(tx, c.txID, model.TransactionStatus(c.status.String)); err != nil {
+				return err
+			}
+		}
+		if _, err := linkPayee(tx, payees, c.txID, c.description); err != nil {
+			return err
+		}
+		if c.splitID != nil {
+			if _, err := tx.Exec("UPDATE splits SET category_id = ? WHERE id = ?", c.oldCategory, *c.splitID); err != nil {
+				return err
+			}
+		}
+		if err := setTransactionTags(tx, c.txID, splitTags(c.tags)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE rule_batches SET undone_at = ? WHERE id = ?", time.Now(), batchID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
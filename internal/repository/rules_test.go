@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+func TestApplyRuleToHistorySkipsReconciled(t *testing.T) {
+	r := newTestRepository(t)
+	bank := &model.Account{Name: "Checking", Type: model.AccountTypeBank, Currency: "USD"}
+	food := &model.Account{Name: "Eating Out", Type: model.AccountTypeExpense, Currency: "USD"}
+	for _, a := range []*model.Account{bank, food} {
+		if err := r.CreateAccount(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	coffee := func(day int) *model.Transaction {
+		tx := &model.Transaction{
+			Date:        time.Date(2025, 4, day, 8, 0, 0, 0, time.UTC),
+			Description: "SQ *COFFEE",
+			Status:      model.TransactionStatusPending,
+			Splits: []model.Split{
+				{AccountID: bank.ID, Amount: -450, Currency: "USD"},
+				{AccountID: food.ID, Amount: 450, Currency: "USD"},
+			},
+		}
+		if err := r.CreateTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	reconciled, open := coffee(1), coffee(20)
+
+	rec, err := r.StartReconciliation(bank.ID, time.Date(2025, 4, 10, 0, 0, 0, 0, time.UTC), -450)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetTransactionCleared(reconciled.ID, bank.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.FinishReconciliation(rec.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	rule := &model.Rule{Pattern: "coffee", TargetPayee: "Corner Coffee", TargetNote: "morning", TargetTags: []string{"habit"}}
+	if err := r.CreateRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := r.PreviewRule(*rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Transaction.ID != open.ID {
+		t.Fatalf("preview matched %d transactions, want only the unreconciled one", len(matches))
+	}
+	batch, err := r.ApplyRuleToHistory(rule.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Changed != 1 {
+		t.Errorf("batch changed %d transactions, want 1", batch.Changed)
+	}
+
+	got, err := r.GetTransactionByID(reconciled.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Description != "SQ *COFFEE" || got.Note != "" || len(got.Tags) != 0 || got.Status != model.TransactionStatusReconciled {
+		t.Errorf("reconciled transaction changed to %q, note %q, tags %v, %s", got.Description, got.Note, got.Tags, got.Status)
+	}
+	if got, _ := r.GetTransactionByID(open.ID); got == nil || got.Description != "Corner Coffee" {
+		t.Errorf("unreconciled transaction was not renamed: %+v", got)
+	}
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+// ftsPhrase quotes a text term for an FTS5 MATCH, so FTS5 operators and
+// punctuation in it are taken literally.
+func ftsPhrase(t model.SearchTerm) string {
+	phrase := `"` + strings.ReplaceAll(t.Text, `"`, "") + `"`
+	if t.Prefix {
+		phrase += "*"
+	}
+	return phrase
+}
+
+// likePattern matches values containing s, ignoring case; use it with
+// ESCAPE '\'.
+func likePattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+	return "%" + s + "%"
+}
+
+// searchSQL turns a parsed search into SQL conditions on transactions t.
+// Text terms that must match are joined into one FTS5 query, returned
+// separately so the caller can rank by it; excluded ones become
+// conditions.
+func searchSQL(q *model.SearchQuery) (match string, conditions []string, args []interface{}) {
+	var phrases []string
+	for _, term := range q.Terms {
+		var cond string
+		var condArgs []interface{}
+		switch term.Field {
+		case model.SearchText:
+			if !term.Negate {
+				phrases = append(phrases, ftsPhrase(term))
+				continue
+			}
+			cond = "t.id IN (SELECT rowid FROM transactions_fts WHERE transactions_fts MATCH ?)"
+			condArgs = []interface{}{ftsPhrase(term)}
+
+		case model.SearchDescription:
+			cond = `t.description LIKE ? ESCAPE '\'`
+			condArgs = []interface{}{likePattern(term.Text)}
+
+		case model.SearchNote:
+			cond = `COALESCE(t.note, '') LIKE ? ESCAPE '\'`
+			condArgs = []interface{}{likePattern(term.Text)}
+
+		case model.SearchPayee:
+			cond = `(t.description LIKE ? ESCAPE '\'
+				OR EXISTS (SELECT 1 FROM payees p WHERE p.id = t.payee_id AND p.name LIKE ? ESCAPE '\'))`
+			condArgs = []interface{}{likePattern(term.Text), likePattern(term.Text)}
+
+		case model.SearchCategory:
+			cond = `EXISTS (SELECT 1 FROM splits s WHERE s.transaction_id = t.id AND s.category_id IN (
+				WITH RECURSIVE sub(id) AS (
+					SELECT id FROM categories WHERE name = ? COLLATE NOCASE
+					UNION SELECT c.id FROM categories c JOIN sub ON c.parent_id = sub.id
+				) SELECT id FROM sub))`
+			condArgs = []interface{}{term.Text}
+
+		case model.SearchAccount:
+			cond = `EXISTS (SELECT 1 FROM splits s JOIN accounts a ON a.id = s.account_id
+				WHERE s.transaction_id = t.id AND a.name = ? COLLATE NOCASE)`
+			condArgs = []interface{}{term.Text}
+
+		case model.SearchTag:
+			cond = `EXISTS (
+				SELECT 1 FROM tags g WHERE g.name = ? AND (
+					g.id IN (SELECT tag_id FROM transaction_tags WHERE transaction_id = t.id)
+					OR g.id IN (SELECT st.tag_id FROM split_tags st JOIN splits x ON x.id = st.split_id WHERE x.transaction_id = t.id)))`
+			condArgs = []interface{}{term.Text}
+
+		case model.SearchStatus:
+			cond = "t.status = ?"
+			condArgs = []interface{}{term.Status}
+
+		case model.SearchDate:
+			// Dates are stored with a time, so compare against day boundaries
+			day := term.Date.Format(dateLayout)
+			next := term.Date.AddDate(0, 0, 1).Format(dateLayout)
+			switch term.Op {
+			case model.SearchLt:
+				cond, condArgs = "t.date < ?", []interface{}{day}
+			case model.SearchLe:
+				cond, condArgs = "t.date < ?", []interface{}{next}
+			case model.SearchGt:
+				cond, condArgs = "t.date >= ?", []interface{}{next}
+			case model.SearchGe:
+				cond, condArgs = "t.date >= ?", []interface{}{day}
+			default:
+				cond, condArgs = "(t.date >= ? AND t.date < ?)", []interface{}{day, next}
+			}
+
+		case model.SearchAmount:
+			op := term.Op
+			if op == model.SearchIs {
+				op = model.SearchEq
+			}
+			cond = fmt.Sprintf(`(SELECT ROUND(COALESCE(SUM(s.amount * s.exchange_rate), 0))
+				FROM splits s WHERE s.transaction_id = t.id AND s.amount > 0) %s ?`, op)
+			condArgs = []interface{}{term.Amount.Amount}
+
+		default:
+			continue
+		}
+
+		if term.Negate {
+			cond = "NOT " + cond
+		}
+		conditions = append(conditions, cond)
+		args = append(args, condArgs...)
+	}
+	return strings.Join(phrases, " "), conditions, args
+}
@@ -0,0 +1,373 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+// Recurring-pattern detection settings.
+const (
+	// subscriptionLookbackMonths covers at least two yearly payments.
+	subscriptionLookbackMonths = 25
+	// subscriptionAmountDrift is how far a payment may stray from the
+	// payee's typical amount and still count as the same subscription.
+	subscriptionAmountDrift = 0.10
+	// minSubscriptionConfidence hides weak matches.
+	minSubscriptionConfidence = 0.5
+)
+
+// subscriptionPeriods are the intervals detection recognizes, with the
+// range of gaps in days that count as each.
+var subscriptionPeriods = []struct {
+	frequency model.RecurrenceFrequency
+	minDays   float64
+	maxDays   float64
+}{
+	{model.FrequencyWeekly, 6, 8},
+	{model.FrequencyBiweekly, 12, 16},
+	{model.FrequencyMonthly, 26, 35},
+	{model.FrequencyQuarterly, 82, 100},
+	{model.FrequencyYearly, 350, 380},
+}
+
+// subscriptionGap returns the range of gaps in days that count as one
+// period of freq.
+func subscriptionGap(freq model.RecurrenceFrequency) (float64, float64) {
+	if freq == model.FrequencyMonthEnd {
+		freq = model.FrequencyMonthly
+	}
+	for _, p := range subscriptionPeriods {
+		if p.frequency == freq {
+			return p.minDays, p.maxDays
+		}
+	}
+	return 0, math.MaxFloat64
+}
+
+// payeePrefixes are card-processor and bank noise in front of a merchant name.
+var payeePrefixes = []string{
+	"pos ", "debit ", "credit ", "purchase ", "card ", "recurring ", "ach ", "dd ",
+	"sq *", "sq*", "tst* ", "tst*", "paypal *", "pp*",
+}
+
+// payeeNoise are words dropped when comparing payees.
+var payeeNoise = map[string]bool{"www": true, "com": true, "inc": true, "llc": true, "ltd": true, "co": true}
+
+// NormalizePayee reduces a bank description to a comparable payee name:
+// lower case, without processor prefixes, reference numbers, punctuation or
+// company suffixes. "POS NETFLIX.COM #4471" becomes "netflix".
+func NormalizePayee(desc string) string {
+	s := strings.ToLower(strings.TrimSpace(desc))
+	for trimmed := true; trimmed; {
+		trimmed = false
+		for _, p := range payeePrefixes {
+			if strings.HasPrefix(s, p) {
+				s = strings.TrimSpace(s[len(p):])
+				trimmed = true
+			}
+		}
+	}
+
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '&'
+	})
+	var words []string
+	for _, f := range fields {
+		if payeeNoise[f] || strings.IndexFunc(f, unicode.IsDigit) >= 0 {
+			continue
+		}
+		words = append(words, f)
+	}
+	if len(words) == 0 {
+		return strings.Join(fields, " ")
+	}
+	return strings.Join(words, " ")
+}
+
+// payment is one expense, with split purchases counted once.
+type payment struct {
+	txID             int64
+	date             time.Time
+	description      string
+	payee            string // Linked payee's key, or NormalizePayee(description)
+	payeeName        string // Linked payee's name, or the description
+	amount           int64
+	currency         string
+	base             float64 // Amount in base minor units
+	accountID        int64
+	expenseAccountID int64
+	categoryID       *int64
+}
+
+// DetectRecurringPatterns looks for payees paid at a regular interval
+// (weekly, biweekly, monthly, quarterly or yearly) for roughly the same
+// amount. Payees already on a schedule and patterns that have lapsed are
+// left out. Results are ordered by confidence.
+func (r *Repository) DetectRecurringPatterns() ([]model.Subscription, error) {
+	return r.detectRecurringPatterns(time.Now())
+}
+
+func (r *Repository) detectRecurringPatterns(asOf time.Time) ([]model.Subscription, error) {
+	groups, err := r.paymentsByPayee(asOf.AddDate(0, -subscriptionLookbackMonths, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := r.GetRecurringRules()
+	if err != nil {
+		return nil, err
+	}
+	payees, err := loadPayeeMatcher(r.DB)
+	if err != nil {
+		return nil, err
+	}
+	scheduled := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if id, ok := payees.match(rule.Name); ok {
+			scheduled[payees.nameKeys[id]] = true
+		} else {
+			scheduled[NormalizePayee(rule.Name)] = true
+		}
+	}
+
+	var subs []model.Subscription
+	for key, payments := range groups {
+		if scheduled[strings.SplitN(key, "|", 2)[0]] {
+			continue
+		}
+		if sub, _, ok := detectSubscription(payments, asOf); ok {
+			subs = append(subs, sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		if subs[i].Confidence != subs[j].Confidence {
+			return subs[i].Confidence > subs[j].Confidence
+		}
+		return subs[i].Name < subs[j].Name
+	})
+	return subs, nil
+}
+
+// paymentsByPayee loads expenses since from, grouped by payee
+// and currency and sorted by date.
+func (r *Repository) paymentsByPayee(from time.Time) (map[string][]payment, error) {
+	payments, err := r.loadPayments(from)
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[string][]payment)
+	for _, p := range payments {
+		key := p.payee + "|" + p.currency
+		groups[key] = append(groups[key], p)
+	}
+	return groups, nil
+}
+
+// loadPayments returns every expense since from in date order.
+func (r *Repository) loadPayments(from time.Time) ([]payment, error) {
+	rows, err := r.DB.Query(`
+		SELECT t.id, t.date, t.description, s.account_id, s.category_id, s.amount, s.currency, s.exchange_rate,
+			(SELECT f.account_id FROM splits f WHERE f.transaction_id = t.id AND f.amount < 0 ORDER BY f.amount LIMIT 1),
+			p.name, p.name_key
+		FROM transactions t
+		JOIN splits s ON s.transaction_id = t.id
+		JOIN accounts a ON a.id = s.account_id
+		LEFT JOIN payees p ON p.id = t.payee_id
+		WHERE a.type = 'Expense' AND s.amount > 0 AND t.date >= ?
+		ORDER BY t.date, t.id
+	`, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// A split purchase is one payment; its largest leg names the category
+	byTx := make(map[int64]*payment)
+	var order []int64
+	largest := make(map[int64]int64)
+	for rows.Next() {
+		var p payment
+		var rate float64
+		var fundingID *int64
+		var payeeName, payeeKey sql.NullString
+		if err := rows.Scan(&p.txID, &p.date, &p.description, &p.expenseAccountID, &p.categoryID, &p.amount,
+			&p.currency, &rate, &fundingID, &payeeName, &payeeKey); err != nil {
+			return nil, err
+		}
+		if fundingID != nil {
+			p.accountID = *fundingID
+		}
+		p.base = float64(p.amount) * rate
+		existing, ok := byTx[p.txID]
+		if !ok {
+			p.payee, p.payeeName = NormalizePayee(p.description), p.description
+			if payeeKey.Valid {
+				p.payee, p.payeeName = payeeKey.String, payeeName.String
+			}
+			byTx[p.txID] = &p
+			order = append(order, p.txID)
+			largest[p.txID] = p.amount
+			continue
+		}
+		existing.base += p.base
+		if p.currency != existing.currency {
+			continue
+		}
+		existing.amount += p.amount
+		if p.amount > largest[p.txID] {
+			largest[p.txID] = p.amount
+			existing.categoryID = p.categoryID
+			existing.expenseAccountID = p.expenseAccountID
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	payments := make([]payment, len(order))
+	for i, id := range order {
+		payments[i] = *byTx[id]
+	}
+	return payments, nil
+}
+
+// detectSubscription decides whether one payee's payments recur. Payments
+// far from the payee's median amount are treated as one-off purchases and
+// ignored, then the median gap between the rest picks the frequency. The
+// payments that make up the pattern are returned with it.
+func detectSubscription(payments []payment, asOf time.Time) (model.Subscription, []payment, bool) {
+	if len(payments) < 2 {
+		return model.Subscription{}, nil, false
+	}
+
+	amounts := make([]float64, len(payments))
+	for i, p := range payments {
+		amounts[i] = float64(p.amount)
+	}
+	typical := median(amounts)
+
+	var kept []payment
+	var drift float64
+	for _, p := range payments {
+		d := math.Abs(float64(p.amount)-typical) / typical
+		if d <= subscriptionAmountDrift {
+			kept = append(kept, p)
+			drift += d
+		}
+	}
+	if len(kept) < 2 {
+		return model.Subscription{}, nil, false
+	}
+	drift /= float64(len(kept))
+
+	gaps := make([]float64, 0, len(kept)-1)
+	for i := 1; i < len(kept); i++ {
+		gaps = append(gaps, kept[i].date.Sub(kept[i-1].date).Hours()/24)
+	}
+	gap := median(gaps)
+
+	period := -1
+	for i, p := range subscriptionPeriods {
+		if gap >= p.minDays && gap <= p.maxDays {
+			period = i
+			break
+		}
+	}
+	if period < 0 {
+		return model.Subscription{}, nil, false
+	}
+	freq := subscriptionPeriods[period].frequency
+
+	// A pattern that has missed two payments has probably been cancelled
+	last := kept[len(kept)-1]
+	if asOf.Sub(last.date).Hours()/24 > 2*subscriptionPeriods[period].maxDays {
+		return model.Subscription{}, nil, false
+	}
+
+	regular := 0
+	for _, g := range gaps {
+		if g >= subscriptionPeriods[period].minDays && g <= subscriptionPeriods[period].maxDays {
+			regular++
+		}
+	}
+	regularity := float64(regular) / float64(len(gaps))
+	// Three gaps in a row are convincing; a single gap is only a hint
+	history := 0.5 + 0.5*math.Min(1, float64(len(gaps))/3)
+	stability := 1 - 0.5*drift/subscriptionAmountDrift
+	confidence := regularity * history * stability
+	if confidence < minSubscriptionConfidence {
+		return model.Subscription{}, nil, false
+	}
+
+	if freq == model.FrequencyMonthly {
+		monthEnd := true
+		for _, p := range kept {
+			if p.date.AddDate(0, 0, 1).Day() != 1 {
+				monthEnd = false
+				break
+			}
+		}
+		if monthEnd {
+			freq = model.FrequencyMonthEnd
+		}
+	}
+	next := model.RecurringRule{Frequency: freq, StartDate: last.date}.OccurrenceDate(1)
+
+	return model.Subscription{
+		Name:             last.payeeName,
+		Payee:            last.payee,
+		Amount:           model.NewMoney(last.amount, last.currency),
+		Frequency:        freq,
+		NextDueDate:      next.Format(dateLayout),
+		LastDate:         last.date,
+		Occurrences:      len(kept),
+		Confidence:       confidence,
+		AccountID:        last.accountID,
+		ExpenseAccountID: last.expenseAccountID,
+		CategoryID:       last.categoryID,
+	}, kept, true
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// ConfirmSubscription turns a detected subscription into a recurring
+// schedule whose first occurrence is the next expected payment.
+func (r *Repository) ConfirmSubscription(sub model.Subscription, autoPost bool) (*model.RecurringRule, error) {
+	if sub.AccountID == 0 || sub.ExpenseAccountID == 0 {
+		return nil, errors.New("subscription has no account to schedule it from")
+	}
+	start, err := time.Parse(dateLayout, sub.NextDueDate)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &model.RecurringRule{
+		Name:      sub.Name,
+		Frequency: sub.Frequency,
+		StartDate: start,
+		AutoPost:  autoPost,
+		Splits: []model.Split{
+			{AccountID: sub.AccountID, Amount: -sub.Amount.Amount, Currency: sub.Amount.Currency},
+			{AccountID: sub.ExpenseAccountID, CategoryID: sub.CategoryID, Amount: sub.Amount.Amount, Currency: sub.Amount.Currency},
+		},
+	}
+	if err := r.CreateRecurringRule(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
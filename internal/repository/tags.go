@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+// ErrInvalidTag is returned for tag names that cannot be stored.
+var ErrInvalidTag = errors.New("tag names cannot contain commas")
+
+// cleanTags trims tag names and drops blanks and repeats (ignoring case),
+// keeping the first spelling.
+func cleanTags(tags []string) ([]string, error) {
+	var cleaned []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if strings.Contains(tag, ",") {
+			return nil, ErrInvalidTag
+		}
+		key := strings.ToLower(tag)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		cleaned = append(cleaned, tag)
+	}
+	return cleaned, nil
+}
+
+// joinTags and splitTags store a tag list in one column.
+func joinTags(tags []string) string {
+	return strings.Join(tags, ",")
+}
+
+func splitTags(s string) []string {
+	tags, _ := cleanTags(strings.Split(s, ","))
+	return tags
+}
+
+// hasTag reports whether tags holds tag, ignoring case.
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// setTags replaces the tags in a link table (transaction_tags or
+// split_tags) for one owner, creating tags that don't exist yet.
+func setTags(tx *sql.Tx, table, column string, ownerID int64, tags []string) error {
+	tags, err := cleanTags(tags)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM "+table+" WHERE "+column+" = ?", ownerID); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO tags (name) VALUES (?)", tag); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO `+table+` (`+column+`, tag_id)
+			SELECT ?, id FROM tags WHERE name = ?
+		`, ownerID, tag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setTransactionTags replaces a transaction's tags.
+func setTransactionTags(tx *sql.Tx, txID int64, tags []string) error {
+	return setTags(tx, "transaction_tags", "transaction_id", txID, tags)
+}
+
+// setSplitTags replaces a split's tags.
+func setSplitTags(tx *sql.Tx, splitID int64, tags []string) error {
+	return setTags(tx, "split_tags", "split_id", splitID, tags)
+}
+
+// GetTransactionTags returns a transaction's tags in name order.
+func (r *Repository) GetTransactionTags(txID int64) ([]string, error) {
+	tags, err := r.tagsByOwner(`
+		SELECT tt.transaction_id, g.name FROM transaction_tags tt
+		JOIN tags g ON g.id = tt.tag_id
+		WHERE tt.transaction_id = ?
+		ORDER BY g.name COLLATE NOCASE
+	`, txID)
+	if err != nil {
+		return nil, err
+	}
+	return tags[txID], nil
+}
+
+// tagsByOwner runs a query returning (owner ID, tag name) rows.
+func (r *Repository) tagsByOwner(query string, args ...interface{}) (map[int64][]string, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[int64][]string)
+	for rows.Next() {
+		var id int64
+		var tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return nil, err
+		}
+		tags[id] = append(tags[id], tag)
+	}
+	return tags, rows.Err()
+}
+
+// attachTags loads the transaction tags of a list in one query.
+func (r *Repository) attachTags(transactions []model.Transaction) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+	ids := make([]interface{}, len(transactions))
+	for i, t := range transactions {
+		ids[i] = t.ID
+	}
+	tags, err := r.tagsByOwner(`
+		SELECT tt.transaction_id, g.name FROM transaction_tags tt
+		JOIN tags g ON g.id = tt.tag_id
+		WHERE tt.transaction_id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)
+		ORDER BY g.name COLLATE NOCASE
+	`, ids...)
+	if err != nil {
+		return err
+	}
+	for i := range transactions {
+		transactions[i].Tags = tags[transactions[i].ID]
+	}
+	return nil
+}
+
+// GetAllTags returns every tag name in use, in name order.
+func (r *Repository) GetAllTags() ([]string, error) {
+	rows, err := r.DB.Query(`
+		SELECT name FROM tags g
+		WHERE EXISTS (SELECT 1 FROM transaction_tags WHERE tag_id = g.id)
+			OR EXISTS (SELECT 1 FROM split_tags WHERE tag_id = g.id)
+		ORDER BY name COLLATE NOCASE
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// GetTagBreakdown returns expense totals per tag in the base currency,
+// largest first. A split counts toward every tag on it or on its
+// transaction, so the totals can add up to more than the spending. Like
+// GetCategoryBreakdown, only expense-account legs count.
+func (r *Repository) GetTagBreakdown(startDate, endDate *time.Time) ([]model.TagBreakdown, error) {
+	base, err := r.GetBaseCurrency()
+	if err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT g.name, SUM(s.amount * s.exchange_rate), COUNT(DISTINCT t.id)
+		FROM splits s
+		JOIN transactions t ON t.id = s.transaction_id
+		JOIN accounts a ON a.id = s.account_id
+		JOIN tags g ON g.id IN (
+			SELECT tag_id FROM split_tags WHERE split_id = s.id
+			UNION
+			SELECT tag_id FROM transaction_tags WHERE transaction_id = t.id
+		)
+		WHERE s.amount > 0 AND a.type = 'Expense'
+	`
+	var args []interface{}
+	if startDate != nil {
+		query += " AND t.date >= ?"
+		args = append(args, startDate.Format("2006-01-02"))
+	}
+	if endDate != nil {
+		query += " AND t.date < ?"
+		args = append(args, endOfDay(*endDate))
+	}
+	query += " GROUP BY g.id ORDER BY 2 DESC"
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var breakdown []model.TagBreakdown
+	for rows.Next() {
+		var b model.TagBreakdown
+		var total sql.NullFloat64
+		if err := rows.Scan(&b.Tag, &total, &b.Transactions); err != nil {
+			return nil, err
+		}
+		b.Amount = model.NewMoney(int64(math.RoundToEven(total.Float64)), base)
+		breakdown = append(breakdown, b)
+	}
+	return breakdown, rows.Err()
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
+)
+
+// Transfer errors.
+var (
+	ErrTransferSameAccount = errors.New("choose two different accounts for a transfer")
+	ErrTransferAccountType = errors.New("transfers can only move money between asset and liability accounts")
+	ErrTransferAccountOpen = errors.New("cannot transfer to or from a closed account")
+	ErrTransferAmount      = errors.New("transfer amount must be greater than zero")
+)
+
+// CreateTransfer records money moving between two of the user's own
+// accounts as a two-leg transaction with no category, so it never counts as
+// income or spending. When the accounts use different currencies, ToAmount
+// is what arrives; if it is zero the amount is converted at the stored rate
+// for the transfer date. Both legs are stamped with the same base-currency
+// value.
+func (r *Repository) CreateTransfer(tr *model.Transfer) (*model.Transaction, error) {
+	if tr.FromAccountID == tr.ToAccountID {
+		return nil, ErrTransferSameAccount
+	}
+	if tr.Amount.Amount <= 0 {
+		return nil, ErrTransferAmount
+	}
+
+	from, err := r.transferAccount(tr.FromAccountID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := r.transferAccount(tr.ToAccountID)
+	if err != nil {
+		return nil, err
+	}
+	fromCur, err := normalizeCurrency(from.Currency)
+	if err != nil {
+		return nil, err
+	}
+	toCur, err := normalizeCurrency(to.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if tr.Amount.Currency != "" && tr.Amount.Currency != fromCur {
+		return nil, fmt.Errorf("%w: amount is in %s but %s is kept in %s", model.ErrCurrencyMismatch, tr.Amount.Currency, from.Name, fromCur)
+	}
+	amount := model.NewMoney(tr.Amount.Amount, fromCur)
+
+	received := amount
+	received.Currency = toCur
+	if fromCur != toCur {
+		received = tr.ToAmount
+		if received.IsZero() {
+			rate, err := r.rateNear(fromCur, toCur, tr.Date)
+			if err != nil {
+				return nil, err
+			}
+			if received, err = amount.Convert(rate, toCur); err != nil {
+				return nil, err
+			}
+		}
+		if received.Amount <= 0 {
+			return nil, ErrTransferAmount
+		}
+		received.Currency = toCur
+	}
+
+	desc := tr.Description
+	if desc == "" {
+		desc = fmt.Sprintf("Transfer from %s to %s", from.Name, to.Name)
+	}
+	t := &model.Transaction{
+		Date:        tr.Date,
+		Description: desc,
+		Note:        tr.Note,
+		Status:      model.TransactionStatusPending,
+		Tags:        tr.Tags,
+		Splits: []model.Split{
+			{AccountID: from.ID, Amount: -amount.Amount, Currency: fromCur},
+			{AccountID: to.ID, Amount: received.Amount, Currency: toCur},
+		},
+	}
+
+	if fromCur != toCur {
+		// Price the received leg at the value that left, so the difference
+		// from the market rate shows up in that account's FX gain or loss
+		if err := r.fillSplitRates(t); err != nil {
+			return nil, err
+		}
+		src := t.Splits[0]
+		t.Splits[1].ExchangeRate = float64(amount.Amount) * src.ExchangeRate / float64(received.Amount)
+	}
+
+	if err := r.CreateTransaction(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// transferAccount loads an account that can take part in a transfer.
+func (r *Repository) transferAccount(id int64) (*model.Account, error) {
+	acc, err := r.GetAccountByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if acc == nil {
+		return nil, ErrAccountNotFound
+	}
+	if !isBalanceSheet(acc.Type) {
+		return nil, ErrTransferAccountType
+	}
+	if acc.IsClosed {
+		return nil, ErrTransferAccountOpen
+	}
+	return acc, nil
+}
+
+// IsTransfer reports whether a transaction only moves money between
+// balance-sheet accounts, i.e. touches no income or expense account.
+func IsTransfer(t *model.Transaction, accounts []model.Account) bool {
+	types := make(map[int64]model.AccountType, len(accounts))
+	for _, a := range accounts {
+		types[a.ID] = a.Type
+	}
+	if len(t.Splits) == 0 {
+		return false
+	}
+	for _, s := range t.Splits {
+		accType, ok := types[s.AccountID]
+		if !ok || !isBalanceSheet(accType) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,11 +1,14 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
+	"github.com/nabinkatwal7/go-eila/internal/model"
 	"github.com/nabinkatwal7/go-eila/internal/repository"
 )
 
@@ -17,13 +20,43 @@ func NewAccountsView(repo *repository.Repository, a *App) fyne.CanvasObject {
 		a.ShowCreateAccountModal()
 	})
 
+	showClosed := false
+	var accounts []model.Account
+	var balances map[int64]model.Money
+	var list *widget.List
+
+	load := func() error {
+		all, err := repo.GetAllAccounts()
+		if err != nil {
+			return err
+		}
+		if balances, err = repo.GetAccountBalances(); err != nil {
+			return err
+		}
+		accounts = accounts[:0]
+		for _, acc := range all {
+			if acc.IsClosed && !showClosed {
+				continue
+			}
+			accounts = append(accounts, acc)
+		}
+		return nil
+	}
+
+	reload := func() {
+		if err := load(); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		list.Refresh()
+	}
+
 	// List
-	accounts, err := repo.GetAllAccounts()
-	if err != nil {
+	if err := load(); err != nil {
 		return widget.NewLabel("Error loading accounts: " + err.Error())
 	}
 
-	list := widget.NewList(
+	list = widget.NewList(
 		func() int {
 			return len(accounts)
 		},
@@ -33,20 +66,182 @@ func NewAccountsView(repo *repository.Repository, a *App) fyne.CanvasObject {
 				widget.NewLabel("Type"),
 				widget.NewLabel("Currency"),
 				widget.NewLabel("Balance"),
+				widget.NewButton("Reconcile", nil),
+				widget.NewButton("Edit", nil),
+				widget.NewButton("Close", nil),
+				widget.NewButton("Delete", nil),
 			)
 		},
 		func(i int, o fyne.CanvasObject) {
 			ac := accounts[i]
 			box := o.(*fyne.Container)
-			box.Objects[0].(*widget.Label).SetText(ac.Name)
+			name := ac.Name
+			if ac.IsClosed {
+				name += " (closed)"
+			}
+			box.Objects[0].(*widget.Label).SetText(name)
 			box.Objects[1].(*widget.Label).SetText(string(ac.Type))
 			box.Objects[2].(*widget.Label).SetText(ac.Currency)
 
-			// Balance check (Need separate query or eager load)
-			bal, _ := repo.GetAccountBalance(ac.ID)
-			box.Objects[3].(*widget.Label).SetText(fmt.Sprintf("%.2f", bal))
+			bal, ok := balances[ac.ID]
+			if !ok {
+				bal = model.NewMoney(0, ac.Currency)
+			}
+			box.Objects[3].(*widget.Label).SetText(bal.Format())
+
+			reconcileBtn := box.Objects[4].(*widget.Button)
+			if ac.IsClosed {
+				reconcileBtn.Disable()
+			} else {
+				reconcileBtn.Enable()
+			}
+			reconcileBtn.OnTapped = func() {
+				a.ContentContainer.Objects = []fyne.CanvasObject{NewReconcileView(repo, a, ac)}
+				a.ContentContainer.Refresh()
+			}
+
+			box.Objects[5].(*widget.Button).OnTapped = func() {
+				showEditAccountModal(repo, a, ac, reload)
+			}
+
+			closeBtn := box.Objects[6].(*widget.Button)
+			if ac.IsClosed {
+				closeBtn.SetText("Reopen")
+				closeBtn.OnTapped = func() {
+					if err := repo.ReopenAccount(ac.ID); err != nil {
+						dialog.ShowError(err, a.Window)
+						return
+					}
+					reload()
+				}
+			} else {
+				closeBtn.SetText("Close")
+				closeBtn.OnTapped = func() {
+					if err := repo.CloseAccount(ac.ID); err != nil {
+						if errors.Is(err, repository.ErrAccountHasBalance) {
+							err = fmt.Errorf("'%s' still has a balance of %s. Transfer it out before closing", ac.Name, bal.Format())
+						}
+						dialog.ShowError(err, a.Window)
+						return
+					}
+					reload()
+				}
+			}
+
+			box.Objects[7].(*widget.Button).OnTapped = func() {
+				showDeleteAccountDialog(repo, a, ac, reload)
+			}
 		},
 	)
 
-	return container.NewBorder(container.NewHBox(header, addBtn), nil, nil, nil, list)
+	showClosedCheck := widget.NewCheck("Show closed", func(checked bool) {
+		showClosed = checked
+		reload()
+	})
+
+	fxBtn := widget.NewButton("FX Gains/Losses", func() {
+		showFXGainLossDialog(repo, a.Window)
+	})
+
+	return container.NewBorder(container.NewHBox(header, addBtn, showClosedCheck, fxBtn), nil, nil, nil, list)
+}
+
+func showEditAccountModal(repo *repository.Repository, a *App, acc model.Account, onDone func()) {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(acc.Name)
+
+	currencySelect := widget.NewSelect(currencyOptions, nil)
+	currencySelect.SetSelected(acc.Currency)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Currency", currencySelect),
+	}
+
+	dialog.ShowForm("Edit Account", "Save", "Cancel", items, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		if err := ValidateRequired(nameEntry.Text, "Account Name"); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+
+		if nameEntry.Text != acc.Name {
+			if err := repo.RenameAccount(acc.ID, nameEntry.Text); err != nil {
+				dialog.ShowError(err, a.Window)
+				return
+			}
+		}
+		if currencySelect.Selected != acc.Currency {
+			if err := repo.ChangeAccountCurrency(acc.ID, currencySelect.Selected); err != nil {
+				dialog.ShowError(err, a.Window)
+				onDone()
+				return
+			}
+		}
+		onDone()
+	}, a.Window)
+}
+
+func showDeleteAccountDialog(repo *repository.Repository, a *App, acc model.Account, onDone func()) {
+	dialog.ShowConfirm("Delete Account",
+		fmt.Sprintf("Are you sure you want to delete account '%s'?", acc.Name),
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			err := repo.DeleteAccount(acc.ID, 0)
+			if err == nil {
+				onDone()
+				return
+			}
+			if !errors.Is(err, repository.ErrAccountHasHistory) {
+				dialog.ShowError(err, a.Window)
+				return
+			}
+			showReassignAndDeleteDialog(repo, a, acc, onDone)
+		}, a.Window)
+}
+
+// showReassignAndDeleteDialog asks where an account's splits should go
+// before deleting it, so existing transactions stay balanced.
+func showReassignAndDeleteDialog(repo *repository.Repository, a *App, acc model.Account, onDone func()) {
+	open, err := repo.GetOpenAccounts()
+	if err != nil {
+		dialog.ShowError(err, a.Window)
+		return
+	}
+	var targetNames []string
+	targetNameToID := make(map[string]int64)
+	for _, other := range open {
+		if other.ID == acc.ID || other.Currency != acc.Currency {
+			continue
+		}
+		targetNames = append(targetNames, other.Name)
+		targetNameToID[other.Name] = other.ID
+	}
+	if len(targetNames) == 0 {
+		dialog.ShowError(fmt.Errorf("create another %s account to move these transactions to first", acc.Currency), a.Window)
+		return
+	}
+
+	targetSelect := widget.NewSelect(targetNames, nil)
+	targetSelect.SetSelected(targetNames[0])
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Move transactions to", targetSelect),
+	}
+
+	title := fmt.Sprintf("Delete '%s'", acc.Name)
+	dialog.ShowForm(title, "Delete", "Cancel", items, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		if err := repo.DeleteAccount(acc.ID, targetNameToID[targetSelect.Selected]); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		onDone()
+	}, a.Window)
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -13,6 +14,7 @@ import (
 	"fyne.io/fyne/v2/widget"
 
 	"github.com/nabinkatwal7/go-eila/internal/model"
+	"github.com/nabinkatwal7/go-eila/internal/repository"
 )
 
 func (a *App) ShowAddTransactionModal() {
@@ -45,9 +47,12 @@ func (a *App) createSimpleForm(w fyne.Window) fyne.CanvasObject {
 		return widget.NewLabel("Error loading categories: " + err.Error())
 	}
 
-	// Filter accounts by type for dropdowns
+	// Filter accounts by type for dropdowns (closed accounts can't take new entries)
 	var assetAccounts []model.Account
 	for _, acc := range accounts {
+		if acc.IsClosed {
+			continue
+		}
 		if acc.Type == model.AccountTypeCash || acc.Type == model.AccountTypeBank ||
 		   acc.Type == model.AccountTypeCard || acc.Type == model.AccountTypeInvest {
 			assetAccounts = append(assetAccounts, acc)
@@ -62,13 +67,20 @@ func (a *App) createSimpleForm(w fyne.Window) fyne.CanvasObject {
 		accountNameToID[acc.Name] = acc.ID
 	}
 
-	categoryNames := make([]string, len(categories))
-	categoryNameToID := make(map[string]int64)
-	for i, cat := range categories {
-		categoryNames[i] = cat.Name
-		categoryNameToID[cat.Name] = cat.ID
+	// Transfers can also go to a liability, e.g. paying down a loan
+	var transferNames []string
+	for _, acc := range accounts {
+		if acc.IsClosed {
+			continue
+		}
+		if acc.Type != model.AccountTypeIncome && acc.Type != model.AccountTypeExpense {
+			transferNames = append(transferNames, acc.Name)
+			accountNameToID[acc.Name] = acc.ID
+		}
 	}
 
+	categoryNames, categoryNameToID := categoryOptions(categories)
+
 	// Inputs
 	amountEntry := widget.NewEntry()
 	amountEntry.PlaceHolder = "Amount"
@@ -76,7 +88,10 @@ func (a *App) createSimpleForm(w fyne.Window) fyne.CanvasObject {
 	noteEntry := widget.NewEntry()
 	noteEntry.PlaceHolder = "Note (e.g., Lunch)"
 
-	typeSelect := widget.NewSelect([]string{"Expense", "Income"}, nil)
+	tagsEntry := widget.NewEntry()
+	tagsEntry.PlaceHolder = "Tags, comma-separated (e.g. trip, tax)"
+
+	typeSelect := widget.NewSelect([]string{"Expense", "Income", "Transfer"}, nil)
 	typeSelect.Selected = "Expense"
 
 	dateEntry := widget.NewEntry()
@@ -92,18 +107,71 @@ func (a *App) createSimpleForm(w fyne.Window) fyne.CanvasObject {
 		categorySelect.Selected = categoryNames[0]
 	}
 
+	toAccountSelect := widget.NewSelect(transferNames, nil)
+	rateEntry := widget.NewEntry()
+
+	// A known payee fills in its default category and account
+	payeeEntry := a.newPayeeEntry(func(p model.Payee) {
+		for name, id := range categoryNameToID {
+			if p.DefaultCategoryID != nil && *p.DefaultCategoryID == id {
+				categorySelect.SetSelected(name)
+			}
+		}
+		for _, acc := range assetAccounts {
+			if p.DefaultAccountID != nil && *p.DefaultAccountID == acc.ID {
+				accountSelect.SetSelected(acc.Name)
+			}
+		}
+	})
+	payeeEntry.PlaceHolder = "Payee (optional)"
+
+	// Enable only the inputs that apply to the chosen type
+	updateInputs := func() {
+		if typeSelect.Selected != "Transfer" {
+			toAccountSelect.Disable()
+			rateEntry.Disable()
+			categorySelect.Enable()
+			return
+		}
+		categorySelect.Disable()
+		toAccountSelect.Enable()
+		fromCur := accountCurrency(accounts, accountNameToID[accountSelect.Selected])
+		toCur := accountCurrency(accounts, accountNameToID[toAccountSelect.Selected])
+		if toAccountSelect.Selected != "" && fromCur != toCur {
+			rateEntry.SetPlaceHolder(fmt.Sprintf("1 %s = ? %s (blank uses stored rate)", fromCur, toCur))
+			rateEntry.Enable()
+		} else {
+			rateEntry.SetText("")
+			rateEntry.SetPlaceHolder("Only for accounts in different currencies")
+			rateEntry.Disable()
+		}
+	}
+	typeSelect.OnChanged = func(string) { updateInputs() }
+	accountSelect.OnChanged = func(string) { updateInputs() }
+	toAccountSelect.OnChanged = func(string) { updateInputs() }
+	updateInputs()
+
 	form := widget.NewForm(
 		widget.NewFormItem("Type", typeSelect),
 		widget.NewFormItem("Amount", amountEntry),
 		widget.NewFormItem("Date", dateEntry),
+		widget.NewFormItem("Payee", payeeEntry),
 		widget.NewFormItem("Account", accountSelect),
 		widget.NewFormItem("Category", categorySelect),
+		widget.NewFormItem("To Account", toAccountSelect),
+		widget.NewFormItem("Exchange Rate", rateEntry),
 		widget.NewFormItem("Note", noteEntry),
+		widget.NewFormItem("Tags", tagsEntry),
 	)
 
 	saveBtn := widget.NewButtonWithIcon("Save Simple", theme.DocumentSaveIcon(), func() {
+		// Get actual IDs from selections
+		accountID := accountNameToID[accountSelect.Selected]
+		categoryID := categoryNameToID[categorySelect.Selected]
+		currency := accountCurrency(accounts, accountID)
+
 		// Validation
-		amountVal, err := ValidateAmount(amountEntry.Text)
+		amountVal, err := ValidateAmount(amountEntry.Text, currency)
 		if err != nil {
 			dialog.ShowError(errors.New("Invalid Amount: "+err.Error()), w)
 			return
@@ -115,41 +183,30 @@ func (a *App) createSimpleForm(w fyne.Window) fyne.CanvasObject {
 			return
 		}
 
-		// Logic to save
-		amountCents := int64(amountVal * 100)
+		if typeSelect.Selected == "Transfer" {
+			a.saveTransfer(w, date, accountID, toAccountSelect.Selected, amountVal, rateEntry.Text, noteEntry.Text,
+				strings.Split(tagsEntry.Text, ","))
+			return
+		}
 
-		// Get actual IDs from selections
-		accountID := accountNameToID[accountSelect.Selected]
-		categoryID := categoryNameToID[categorySelect.Selected]
+		// Logic to save
+		amountCents := amountVal.Amount
 
-		// Rule Engine Hook
+		// Rules and payees match on the description: the payee if given,
+		// else the note, else the category
 		desc := categorySelect.Selected
-		if noteEntry.Text != "" {
+		if payee := strings.TrimSpace(payeeEntry.Text); payee != "" {
+			desc = payee
+		} else if noteEntry.Text != "" {
 			desc = noteEntry.Text
 		}
 
-		enrichedPayee, enrichedCatID, enrichedNote := a.Repo.EnrichTransaction(desc)
-
-		finalDesc := desc
-		if enrichedPayee != "" && enrichedPayee != desc {
-			finalDesc = enrichedPayee
-		}
-
-		finalNote := noteEntry.Text
-		if enrichedNote != "" {
-			finalNote = enrichedNote
-		}
-
-		// If Rule found a category, use it
-		if enrichedCatID != nil {
-			categoryID = *enrichedCatID
-		}
-
 		t := &model.Transaction{
 			Date:        date,
-			Description: finalDesc,
-			Note:        finalNote,
+			Description: desc,
+			Note:        noteEntry.Text,
 			Status:      model.TransactionStatusPending,
+			Tags:        strings.Split(tagsEntry.Text, ","),
 		}
 
 		// Find Expense/Income account IDs
@@ -170,15 +227,13 @@ func (a *App) createSimpleForm(w fyne.Window) fyne.CanvasObject {
 				model.Split{ // Asset Leg (decrease)
 					AccountID: accountID,
 					Amount:    -amountCents,
-					Currency: "USD",
-					ExchangeRate: 1.0,
+					Currency:  currency,
 				},
 				model.Split{ // Expense Leg (increase)
 					AccountID: expenseAccountID,
 					CategoryID: &categoryID,
 					Amount:    amountCents,
-					Currency: "USD",
-					ExchangeRate: 1.0,
+					Currency:  currency,
 				},
 			)
 		} else {
@@ -186,21 +241,25 @@ func (a *App) createSimpleForm(w fyne.Window) fyne.CanvasObject {
 				model.Split{ // Asset Leg (increase)
 					AccountID: accountID,
 					Amount:    amountCents,
-					Currency: "USD",
-					ExchangeRate: 1.0,
+					Currency:  currency,
 				},
 				model.Split{ // Income Leg (decrease)
 					AccountID: incomeAccountID,
 					CategoryID: &categoryID,
 					Amount:    -amountCents,
-					Currency: "USD",
-					ExchangeRate: 1.0,
+					Currency:  currency,
 				},
 			)
 		}
 
 		t.Splits = splits
 
+		// Rule Engine Hook
+		if err := a.Repo.ApplyRules(t); err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+
 		if err := a.Repo.CreateTransaction(t); err != nil {
 			dialog.ShowError(err, w)
 		} else {
@@ -213,6 +272,44 @@ func (a *App) createSimpleForm(w fyne.Window) fyne.CanvasObject {
 	return container.NewVBox(form, saveBtn)
 }
 
+// saveTransfer records a transfer from the simple form. rateText, if set,
+// is the number of destination units per source unit.
+func (a *App) saveTransfer(w fyne.Window, date time.Time, fromID int64, toName string, amount model.Money, rateText, note string, tags []string) {
+	to, err := a.Repo.GetAccountByName(toName)
+	if err != nil || to == nil {
+		dialog.ShowError(errors.New("Choose the account to transfer to"), w)
+		return
+	}
+
+	tr := &model.Transfer{
+		Date:          date,
+		FromAccountID: fromID,
+		ToAccountID:   to.ID,
+		Amount:        amount,
+		Note:          note,
+		Tags:          tags,
+	}
+	if rateText = strings.TrimSpace(rateText); rateText != "" {
+		rate, err := strconv.ParseFloat(rateText, 64)
+		if err != nil || rate <= 0 {
+			dialog.ShowError(fmt.Errorf("Invalid Exchange Rate: %s", rateText), w)
+			return
+		}
+		if tr.ToAmount, err = amount.Convert(rate, to.Currency); err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+	}
+
+	if _, err := a.Repo.CreateTransfer(tr); err != nil {
+		dialog.ShowError(err, w)
+		return
+	}
+	a.ContentContainer.Refresh()
+	w.Close()
+	dialog.ShowInformation("Success", "Transfer added successfully!", a.Window)
+}
+
 func (a *App) createSplitForm(w fyne.Window) fyne.CanvasObject {
 	// Load real accounts and categories from database
 	accounts, err := a.Repo.GetAllAccounts()
@@ -225,9 +322,12 @@ func (a *App) createSplitForm(w fyne.Window) fyne.CanvasObject {
 		return widget.NewLabel("Error loading categories: " + err.Error())
 	}
 
-	// Filter asset accounts
+	// Filter asset accounts (closed accounts can't take new entries)
 	var assetAccounts []model.Account
 	for _, acc := range accounts {
+		if acc.IsClosed {
+			continue
+		}
 		if acc.Type == model.AccountTypeCash || acc.Type == model.AccountTypeBank ||
 		   acc.Type == model.AccountTypeCard || acc.Type == model.AccountTypeInvest {
 			assetAccounts = append(assetAccounts, acc)
@@ -242,40 +342,56 @@ func (a *App) createSplitForm(w fyne.Window) fyne.CanvasObject {
 		accountNameToID[acc.Name] = acc.ID
 	}
 
-	categoryNames := make([]string, len(categories))
-	categoryNameToID := make(map[string]int64)
-	for i, cat := range categories {
-		categoryNames[i] = cat.Name
-		categoryNameToID[cat.Name] = cat.ID
-	}
+	categoryNames, categoryNameToID := categoryOptions(categories)
 
 	// Header
 	dateEntry := widget.NewEntry()
 	dateEntry.SetText(time.Now().Format("2006-01-02"))
 
-	descEntry := widget.NewEntry()
-	descEntry.PlaceHolder = "Payee (e.g. Supermarket)"
-
 	sourceAccount := widget.NewSelect(accountNames, nil)
 	if len(accountNames) > 0 {
 		sourceAccount.Selected = accountNames[0]
 	}
 
+	descEntry := a.newPayeeEntry(func(p model.Payee) {
+		for _, acc := range assetAccounts {
+			if p.DefaultAccountID != nil && *p.DefaultAccountID == acc.ID {
+				sourceAccount.SetSelected(acc.Name)
+			}
+		}
+	})
+	descEntry.PlaceHolder = "Payee (e.g. Supermarket)"
+
+	tagsEntry := widget.NewEntry()
+	tagsEntry.PlaceHolder = "Tags for the whole transaction"
+
 	// Splits container
 	splitsContainer := container.NewVBox()
 
 	// Helper to add row
-	totalLabel := widget.NewLabel("Total: $0.00")
+	totalLabel := widget.NewLabel("")
 	var splitRows []*SplitRow
 
+	// Amounts are entered in the source account's currency
+	sourceCurrency := func() string {
+		return accountCurrency(accounts, accountNameToID[sourceAccount.Selected])
+	}
+
 	updateTotal := func() {
-		var sum float64
+		sum := model.NewMoney(0, sourceCurrency())
 		for _, row := range splitRows {
-			v, _ := strconv.ParseFloat(row.AmountEntry.Text, 64)
-			sum += v
+			v, err := model.ParseMoney(row.AmountEntry.Text, sum.Currency)
+			if err != nil {
+				continue
+			}
+			if total, err := sum.Add(v); err == nil {
+				sum = total
+			}
 		}
-		totalLabel.SetText(fmt.Sprintf("Total: $%.2f", sum))
+		totalLabel.SetText("Total: " + sum.Format())
 	}
+	sourceAccount.OnChanged = func(string) { updateTotal() }
+	updateTotal()
 
 	addSplitRow := func() {
 		row := NewSplitRow(categoryNames, updateTotal)
@@ -307,10 +423,12 @@ func (a *App) createSplitForm(w fyne.Window) fyne.CanvasObject {
 			Date: date,
 			Description: descEntry.Text,
 			Status: model.TransactionStatusPending,
+			Tags: strings.Split(tagsEntry.Text, ","),
 		}
 
 		// Get source account ID
 		srcID := accountNameToID[sourceAccount.Selected]
+		currency := accountCurrency(accounts, srcID)
 
 		// Find Expense account ID
 		var expenseAccountID int64
@@ -326,8 +444,13 @@ func (a *App) createSplitForm(w fyne.Window) fyne.CanvasObject {
 
 		// 1. Process Split Rows (Expenses usually)
 		for _, row := range splitRows {
-			amtFloat, _ := strconv.ParseFloat(row.AmountEntry.Text, 64)
-			amtCents := int64(amtFloat * 100)
+			if strings.TrimSpace(row.AmountEntry.Text) == "" { continue }
+			amt, err := ValidateAmount(row.AmountEntry.Text, currency)
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			amtCents := amt.Amount
 			if amtCents <= 0 { continue }
 
 			totalCents += amtCents
@@ -338,7 +461,8 @@ func (a *App) createSplitForm(w fyne.Window) fyne.CanvasObject {
 				AccountID: expenseAccountID,
 				CategoryID: &catID,
 				Amount: amtCents, // Debit Expense
-				Currency: "USD", ExchangeRate: 1.0,
+				Currency: currency,
+				Tags: strings.Split(row.TagsEntry.Text, ","),
 			})
 		}
 
@@ -346,7 +470,7 @@ func (a *App) createSplitForm(w fyne.Window) fyne.CanvasObject {
 		splits = append(splits, model.Split{
 			AccountID: srcID,
 			Amount: -totalCents, // Credit Source
-			Currency: "USD", ExchangeRate: 1.0,
+			Currency: currency,
 		})
 
 		t.Splits = splits
@@ -366,6 +490,7 @@ func (a *App) createSplitForm(w fyne.Window) fyne.CanvasObject {
 			widget.NewFormItem("Date", dateEntry),
 			widget.NewFormItem("Payee", descEntry),
 			widget.NewFormItem("Source", sourceAccount),
+			widget.NewFormItem("Tags", tagsEntry),
 		),
 		widget.NewSeparator(),
 		widget.NewLabel("Category Splits:"),
@@ -381,6 +506,7 @@ type SplitRow struct {
 	Container *fyne.Container
 	CategorySelect *widget.Select
 	AmountEntry *widget.Entry
+	TagsEntry *widget.Entry
 }
 
 func NewSplitRow(categoryNames []string, onChange func()) *SplitRow {
@@ -393,15 +519,19 @@ func NewSplitRow(categoryNames []string, onChange func()) *SplitRow {
 	amt.PlaceHolder = "0.00"
 	amt.OnChanged = func(s string) { onChange() }
 
-	// Layout: [Category (Expand)] [Amount (Fixed)]
+	tags := widget.NewEntry()
+	tags.PlaceHolder = "Tags"
+
+	// Layout: [Category (Expand)] [Amount (Fixed)] [Tags]
 	// Using Grid or HBox
 
-	row := container.NewGridWithColumns(2, cat, amt)
+	row := container.NewGridWithColumns(3, cat, amt, tags)
 
 	return &SplitRow{
 		Container: row,
 		CategorySelect: cat,
 		AmountEntry: amt,
+		TagsEntry: tags,
 	}
 }
 
@@ -439,38 +569,53 @@ func (a *App) ShowEditTransactionModal(txID int64) {
 	noteEntry := widget.NewEntry()
 	noteEntry.SetText(tx.Note)
 
-	statusSelect := widget.NewSelect([]string{
+	tagsEntry := widget.NewEntry()
+	tagsEntry.SetPlaceHolder("Comma-separated")
+	tagsEntry.SetText(strings.Join(tx.Tags, ", "))
+
+	// Reconciled is only reached by finishing a reconciliation
+	statuses := []string{
 		string(model.TransactionStatusPending),
 		string(model.TransactionStatusCleared),
-		string(model.TransactionStatusReconciled),
-	}, nil)
+	}
+	if tx.Status == model.TransactionStatusReconciled {
+		statuses = append(statuses, string(model.TransactionStatusReconciled))
+	}
+	statusSelect := widget.NewSelect(statuses, nil)
 	statusSelect.SetSelected(string(tx.Status))
 
 	// For simple transactions, show amount and account/category
 	// For split transactions, show all splits
 	var formContent fyne.CanvasObject
 
-	if len(tx.Splits) == 2 {
+	transfer := repository.IsTransfer(tx, accounts)
+	if len(tx.Splits) == 2 && !transfer {
 		// Simple transaction - show simple form
 		var amountCents int64
 		var accountID int64
 		var categoryID *int64
+		var currency string
 
 		for _, s := range tx.Splits {
 			if s.Amount > 0 {
 				amountCents = s.Amount
 				categoryID = s.CategoryID
+				currency = s.Currency
 			} else {
 				accountID = s.AccountID
 			}
 		}
 
 		amountEntry := widget.NewEntry()
-		amountEntry.SetText(fmt.Sprintf("%.2f", float64(amountCents)/100.0))
+		amountEntry.SetText(model.NewMoney(amountCents, currency).String())
 
 		accountNames := make([]string, 0)
 		accountNameToID := make(map[string]int64)
 		for _, acc := range accounts {
+			// Keep a closed account only if this transaction already uses it
+			if acc.IsClosed && acc.ID != accountID {
+				continue
+			}
 			if acc.Type == model.AccountTypeCash || acc.Type == model.AccountTypeBank ||
 				acc.Type == model.AccountTypeCard || acc.Type == model.AccountTypeInvest {
 				accountNames = append(accountNames, acc.Name)
@@ -478,12 +623,7 @@ func (a *App) ShowEditTransactionModal(txID int64) {
 			}
 		}
 
-		categoryNames := make([]string, 0)
-		categoryNameToID := make(map[string]int64)
-		for _, cat := range categories {
-			categoryNames = append(categoryNames, cat.Name)
-			categoryNameToID[cat.Name] = cat.ID
-		}
+		categoryNames, categoryNameToID := categoryOptions(categories)
 
 		accountSelect := widget.NewSelect(accountNames, nil)
 		for _, acc := range accounts {
@@ -495,9 +635,9 @@ func (a *App) ShowEditTransactionModal(txID int64) {
 
 		categorySelect := widget.NewSelect(categoryNames, nil)
 		if categoryID != nil {
-			for _, cat := range categories {
-				if cat.ID == *categoryID {
-					categorySelect.SetSelected(cat.Name)
+			for name, id := range categoryNameToID {
+				if id == *categoryID {
+					categorySelect.SetSelected(name)
 					break
 				}
 			}
@@ -507,6 +647,7 @@ func (a *App) ShowEditTransactionModal(txID int64) {
 			widget.NewFormItem("Date", dateEntry),
 			widget.NewFormItem("Description", descEntry),
 			widget.NewFormItem("Note", noteEntry),
+			widget.NewFormItem("Tags", tagsEntry),
 			widget.NewFormItem("Status", statusSelect),
 			widget.NewFormItem("Amount", amountEntry),
 			widget.NewFormItem("Account", accountSelect),
@@ -521,14 +662,15 @@ func (a *App) ShowEditTransactionModal(txID int64) {
 				return
 			}
 
-			amount, err := ValidateAmount(amountEntry.Text)
+			accID := accountNameToID[accountSelect.Selected]
+			currency := accountCurrency(accounts, accID)
+			amount, err := ValidateAmount(amountEntry.Text, currency)
 			if err != nil {
 				dialog.ShowError(err, w)
 				return
 			}
 
-			amountCents := int64(amount * 100)
-			accID := accountNameToID[accountSelect.Selected]
+			amountCents := amount.Amount
 			catID := categoryNameToID[categorySelect.Selected]
 
 			// Find expense/income accounts
@@ -545,11 +687,16 @@ func (a *App) ShowEditTransactionModal(txID int64) {
 			tx.Date = date
 			tx.Description = descEntry.Text
 			tx.Note = noteEntry.Text
+			tx.Tags = strings.Split(tagsEntry.Text, ",")
 			tx.Status = model.TransactionStatus(statusSelect.Selected)
 
 			// Determine if expense or income based on original splits
 			isExpense := true
+			var categoryTags []string
 			for _, s := range tx.Splits {
+				if s.CategoryID != nil {
+					categoryTags = s.Tags
+				}
 				if s.CategoryID != nil && s.Amount > 0 {
 					isExpense = true
 					break
@@ -558,36 +705,61 @@ func (a *App) ShowEditTransactionModal(txID int64) {
 
 			if isExpense {
 				tx.Splits = []model.Split{
-					{AccountID: accID, Amount: -amountCents, Currency: "USD", ExchangeRate: 1.0},
-					{AccountID: expenseAccountID, CategoryID: &catID, Amount: amountCents, Currency: "USD", ExchangeRate: 1.0},
+					{AccountID: accID, Amount: -amountCents, Currency: currency},
+					{AccountID: expenseAccountID, CategoryID: &catID, Amount: amountCents, Currency: currency, Tags: categoryTags},
 				}
 			} else {
 				tx.Splits = []model.Split{
-					{AccountID: accID, Amount: amountCents, Currency: "USD", ExchangeRate: 1.0},
-					{AccountID: incomeAccountID, CategoryID: &catID, Amount: -amountCents, Currency: "USD", ExchangeRate: 1.0},
+					{AccountID: accID, Amount: amountCents, Currency: currency},
+					{AccountID: incomeAccountID, CategoryID: &catID, Amount: -amountCents, Currency: currency, Tags: categoryTags},
 				}
 			}
 
-			if err := a.Repo.UpdateTransaction(tx); err != nil {
-				dialog.ShowError(err, w)
-			} else {
-				dialog.ShowInformation("Success", "Transaction updated", a.Window)
-				w.Close()
-				a.ContentContainer.Refresh()
-			}
+			saveEditedTransaction(a, w, tx)
 		})
 
 		formContent = container.NewVBox(formContent, saveBtn)
 	} else {
 		// Split transaction - show message that editing splits is complex
+		hint := "Note: Split transactions can only have their header and tags edited. To modify splits, delete and recreate."
+		if transfer {
+			hint = "Note: Transfers can only have their header and tags edited. To change the accounts or amount, delete and recreate."
+		}
+		// Each split keeps its own tags
+		splitTags := widget.NewForm()
+		splitTagEntries := make([]*widget.Entry, len(tx.Splits))
+		for i, s := range tx.Splits {
+			label := model.NewMoney(s.Amount, s.Currency).Format()
+			if s.CategoryID != nil {
+				for _, c := range categories {
+					if c.ID == *s.CategoryID {
+						label = c.Name + " " + label
+					}
+				}
+			} else {
+				for _, acc := range accounts {
+					if acc.ID == s.AccountID {
+						label = acc.Name + " " + label
+					}
+				}
+			}
+			entry := widget.NewEntry()
+			entry.SetText(strings.Join(s.Tags, ", "))
+			splitTagEntries[i] = entry
+			splitTags.Append(label, entry)
+		}
+
 		formContent = container.NewVBox(
 			widget.NewForm(
 				widget.NewFormItem("Date", dateEntry),
 				widget.NewFormItem("Description", descEntry),
 				widget.NewFormItem("Note", noteEntry),
+				widget.NewFormItem("Tags", tagsEntry),
 				widget.NewFormItem("Status", statusSelect),
 			),
-			widget.NewLabel("Note: Split transactions can only have their header edited. To modify splits, delete and recreate."),
+			widget.NewLabel(hint),
+			widget.NewLabel("Split Tags:"),
+			splitTags,
 		)
 
 		saveBtn := widget.NewButton("Save", func() {
@@ -600,21 +772,52 @@ func (a *App) ShowEditTransactionModal(txID int64) {
 			tx.Date = date
 			tx.Description = descEntry.Text
 			tx.Note = noteEntry.Text
+			tx.Tags = strings.Split(tagsEntry.Text, ",")
 			tx.Status = model.TransactionStatus(statusSelect.Selected)
-
-			if err := a.Repo.UpdateTransaction(tx); err != nil {
-				dialog.ShowError(err, w)
-			} else {
-				dialog.ShowInformation("Success", "Transaction updated", a.Window)
-				w.Close()
-				a.ContentContainer.Refresh()
+			for i, entry := range splitTagEntries {
+				tx.Splits[i].Tags = strings.Split(entry.Text, ",")
 			}
+
+			saveEditedTransaction(a, w, tx)
 		})
 
 		formContent = container.NewVBox(formContent, saveBtn)
 	}
 
+	makeRecurringBtn := widget.NewButton("Make Recurring", func() {
+		w.Close()
+		a.ShowScheduleModal(scheduleFromTransaction(tx))
+	})
+	formContent = container.NewVBox(formContent, widget.NewSeparator(), a.newAttachmentsSection(w, tx.ID),
+		widget.NewSeparator(), makeRecurringBtn)
+
 	w.Resize(fyne.NewSize(500, 600))
 	w.SetContent(container.NewPadded(formContent))
 	w.Show()
-}
\ No newline at end of file
+}
+// saveEditedTransaction saves an edit, asking for confirmation before
+// changing a transaction that has already been reconciled.
+func saveEditedTransaction(a *App, w fyne.Window, tx *model.Transaction) {
+	saved := func(err error) {
+		if err != nil {
+			dialog.ShowError(err, w)
+		} else {
+			dialog.ShowInformation("Success", "Transaction updated", a.Window)
+			w.Close()
+			a.ContentContainer.Refresh()
+		}
+	}
+
+	err := a.Repo.UpdateTransaction(tx, false)
+	if errors.Is(err, repository.ErrTransactionReconciled) {
+		dialog.ShowConfirm("Reconciled Transaction",
+			"This transaction has been reconciled against a statement.\nChanging it may make that statement no longer match. Save anyway?",
+			func(override bool) {
+				if override {
+					saved(a.Repo.UpdateTransaction(tx, true))
+				}
+			}, w)
+		return
+	}
+	saved(err)
+}
@@ -1,19 +1,40 @@
 package ui
 
 import (
+	"fmt"
 	"image/color"
+	"strconv"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
 	"github.com/nabinkatwal7/go-eila/internal/model"
 	"github.com/nabinkatwal7/go-eila/internal/repository"
 )
 
-func NewAnomaliesView(repo *repository.Repository) fyne.CanvasObject {
+func showAnomaliesView(a *App) {
+	a.ContentContainer.Objects = []fyne.CanvasObject{NewAnomaliesView(a.Repo, a)}
+	a.ContentContainer.Refresh()
+}
+
+func NewAnomaliesView(repo *repository.Repository, a *App) fyne.CanvasObject {
 	header := widget.NewLabelWithStyle("Spending Alerts & Anomalies", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
 
+	thresholdsBtn := widget.NewButton("Thresholds", func() {
+		showAnomalySettings(repo, a)
+	})
+	restoreBtn := widget.NewButton("Restore Dismissed", func() {
+		n, err := repo.RestoreDismissedAnomalies()
+		if err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		showAnomaliesView(a)
+		dialog.ShowInformation("Restored", fmt.Sprintf("Restored %d dismissed alert(s).", n), a.Window)
+	})
+
 	alerts, err := repo.DetectAnomalies()
 	content := container.NewVBox()
 
@@ -22,11 +43,15 @@ func NewAnomaliesView(repo *repository.Repository) fyne.CanvasObject {
 	} else if len(alerts) == 0 {
 		content.Add(widget.NewLabel("No anomalies detected. Good job!"))
 	} else {
-		for _, a := range alerts {
+		for _, alert := range alerts {
+			alert := alert
 			// Color code based on severity
-			c := color.RGBA{200, 200, 0, 255} // Yellow
-			if a.Severity == model.SeverityHigh {
+			c := color.RGBA{120, 120, 120, 255} // Grey
+			switch alert.Severity {
+			case model.SeverityHigh:
 				c = color.RGBA{200, 0, 0, 255} // Red
+			case model.SeverityMedium:
+				c = color.RGBA{200, 200, 0, 255} // Yellow
 			}
 
 			// Icon or colorful text
@@ -34,18 +59,159 @@ func NewAnomaliesView(repo *repository.Repository) fyne.CanvasObject {
 			indicator.Resize(fyne.NewSize(10, 10))
 			indicator.Move(fyne.NewPos(0, 5))
 
-			title := widget.NewLabelWithStyle(a.Type, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
-			desc := widget.NewLabel(a.Description)
-			date := widget.NewLabel(a.Date)
+			title := widget.NewLabelWithStyle(fmt.Sprintf("%s (%s)", alert.Type, alert.Severity),
+				fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+			desc := widget.NewLabel(alert.Description)
+			date := widget.NewLabel(alert.Date)
+
+			dismissBtn := widget.NewButton("Dismiss", func() {
+				if err := repo.DismissAnomaly(alert.Key); err != nil {
+					dialog.ShowError(err, a.Window)
+					return
+				}
+				showAnomaliesView(a)
+			})
+			actions := container.NewHBox(date, dismissBtn)
+			if alert.TransactionID != 0 {
+				actions.Add(widget.NewButton("Open", func() {
+					a.ShowEditTransactionModal(alert.TransactionID)
+				}))
+			}
+			if len(alert.Related) > 0 {
+				actions.Add(widget.NewButton(fmt.Sprintf("Transactions (%d)", len(alert.Related)), func() {
+					showRelatedTransactions(repo, a, alert)
+				}))
+			}
 
 			row := container.NewBorder(nil, nil,
 				container.NewHBox(indicator, title),
-				date,
+				actions,
 				desc,
 			)
 			content.Add(widget.NewCard("", "", row))
 		}
 	}
 
-	return container.NewBorder(header, nil, nil, nil, container.NewVScroll(content))
+	top := container.NewHBox(header, thresholdsBtn, restoreBtn)
+	return container.NewBorder(top, nil, nil, nil, container.NewVScroll(content))
+}
+
+// showRelatedTransactions lists the transactions behind an alert.
+func showRelatedTransactions(repo *repository.Repository, a *App, alert model.Anomaly) {
+	list := container.NewVBox()
+	for _, id := range alert.Related {
+		id := id
+		tx, err := repo.GetTransactionByID(id)
+		if err != nil || tx == nil {
+			continue
+		}
+		text := fmt.Sprintf("%s  %s  %s", tx.Date.Format("2006-01-02"), tx.Description, templateAmount(tx.Splits).Format())
+		openBtn := widget.NewButton("Open", func() {
+			a.ShowEditTransactionModal(id)
+		})
+		list.Add(container.NewBorder(nil, nil, nil, openBtn, widget.NewLabel(text)))
+	}
+	d := dialog.NewCustom(string(alert.Type), "Close", container.NewVScroll(list), a.Window)
+	d.Resize(fyne.NewSize(560, 420))
+	d.Show()
+}
+
+// showAnomalySettings edits the detection thresholds.
+func showAnomalySettings(repo *repository.Repository, a *App) {
+	s, err := repo.GetAnomalySettings()
+	if err != nil {
+		dialog.ShowError(err, a.Window)
+		return
+	}
+	base, _ := repo.GetBaseCurrency()
+
+	intEntry := func(v int) *widget.Entry {
+		e := widget.NewEntry()
+		e.SetText(strconv.Itoa(v))
+		return e
+	}
+	floatEntry := func(v float64) *widget.Entry {
+		e := widget.NewEntry()
+		e.SetText(strconv.FormatFloat(v, 'f', -1, 64))
+		return e
+	}
+	lookback := intEntry(s.LookbackDays)
+	baseline := intEntry(s.BaselineMonths)
+	minHistory := intEntry(s.MinHistory)
+	low := floatEntry(s.LowScore)
+	medium := floatEntry(s.MediumScore)
+	high := floatEntry(s.HighScore)
+	newPayeeMin := widget.NewEntry()
+	newPayeeMin.SetText(model.NewMoney(s.NewPayeeMin, base).String())
+	newPayees := widget.NewCheck("Flag first payments to new payees", nil)
+	newPayees.Checked = s.FlagNewPayees
+	unusualDay := widget.NewCheck("Flag payments on an unusual day of the month", nil)
+	unusualDay.Checked = s.FlagUnusualDay
+	budgetPace := floatEntry(s.BudgetPace)
+	budgetDrift := widget.NewCheck("Warn when a budget is spending ahead of pace", nil)
+	budgetDrift.Checked = s.FlagBudgetDrift
+	priceRise := floatEntry(s.PriceIncreaseMin * 100)
+	priceRises := widget.NewCheck("Flag price increases on recurring charges", nil)
+	priceRises.Checked = s.FlagPriceIncreases
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Check last (days)", lookback),
+		widget.NewFormItem("Baseline (months)", baseline),
+		widget.NewFormItem("Min. history", minHistory),
+		widget.NewFormItem("Low score", low),
+		widget.NewFormItem("Medium score", medium),
+		widget.NewFormItem("High score", high),
+		widget.NewFormItem(fmt.Sprintf("New payee min. (%s)", base), newPayeeMin),
+		widget.NewFormItem("", newPayees),
+		widget.NewFormItem("", unusualDay),
+		widget.NewFormItem("Budget pace", budgetPace),
+		widget.NewFormItem("", budgetDrift),
+		widget.NewFormItem("Price rise (%)", priceRise),
+		widget.NewFormItem("", priceRises),
+	}
+	dialog.ShowForm("Anomaly Thresholds", "Save", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		var errs []error
+		atoi := func(e *widget.Entry) int {
+			v, err := strconv.Atoi(e.Text)
+			errs = append(errs, err)
+			return v
+		}
+		atof := func(e *widget.Entry) float64 {
+			v, err := strconv.ParseFloat(e.Text, 64)
+			errs = append(errs, err)
+			return v
+		}
+		minAmount, err := ValidateAmount(newPayeeMin.Text, base)
+		errs = append(errs, err)
+		updated := model.AnomalySettings{
+			LookbackDays:   atoi(lookback),
+			BaselineMonths: atoi(baseline),
+			MinHistory:     atoi(minHistory),
+			LowScore:       atof(low),
+			MediumScore:    atof(medium),
+			HighScore:      atof(high),
+			NewPayeeMin:    minAmount.Amount,
+			FlagNewPayees:  newPayees.Checked,
+			FlagUnusualDay: unusualDay.Checked,
+
+			BudgetPace:         atof(budgetPace),
+			FlagBudgetDrift:    budgetDrift.Checked,
+			PriceIncreaseMin:   atof(priceRise) / 100,
+			FlagPriceIncreases: priceRises.Checked,
+		}
+		for _, err := range errs {
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("Invalid threshold: %w", err), a.Window)
+				return
+			}
+		}
+		if err := repo.SetAnomalySettings(updated); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		showAnomaliesView(a)
+	}, a.Window)
 }
@@ -69,8 +69,37 @@ func (a *App) createSidebar() fyne.CanvasObject {
 		a.ContentContainer.Refresh()
 	})
 
+	// Envelopes (zero-based budgeting)
+	envelopesBtn := widget.NewButton("Envelopes", func() {
+		a.ContentContainer.Objects = []fyne.CanvasObject{NewEnvelopesView(a.Repo, a)}
+		a.ContentContainer.Refresh()
+	})
+
+	// Recurring schedules
+	recurringBtn := widget.NewButton("Recurring", func() {
+		a.ContentContainer.Objects = []fyne.CanvasObject{NewRecurringView(a.Repo, a)}
+		a.ContentContainer.Refresh()
+	})
+
+	// Rules
+	rulesBtn := widget.NewButton("Rules", func() {
+		a.ContentContainer.Objects = []fyne.CanvasObject{NewRulesView(a.Repo, a)}
+		a.ContentContainer.Refresh()
+	})
+
+	// Payees
+	payeesBtn := widget.NewButton("Payees", func() {
+		showPayeesView(a)
+	})
+
+	// Categories
+	categoriesBtn := widget.NewButton("Categories", func() {
+		a.ContentContainer.Objects = []fyne.CanvasObject{NewCategoriesView(a.Repo, a)}
+		a.ContentContainer.Refresh()
+	})
+
 	settingsBtn := widget.NewButton("Settings", func() {
-		a.ContentContainer.Objects = []fyne.CanvasObject{NewSettingsView(a.Repo, a.Window)}
+		a.ContentContainer.Objects = []fyne.CanvasObject{NewSettingsView(a.Repo, a)}
 		a.ContentContainer.Refresh()
 	})
 
@@ -84,6 +113,11 @@ func (a *App) createSidebar() fyne.CanvasObject {
 		transBtn,
 		accountsBtn,
 		budgetsBtn,
+		envelopesBtn,
+		recurringBtn,
+		rulesBtn,
+		payeesBtn,
+		categoriesBtn,
 		widget.NewSeparator(),
 		settingsBtn,
 	)
@@ -92,6 +126,12 @@ func (a *App) createSidebar() fyne.CanvasObject {
 func (a *App) Init() {
 	// Initialize things like Shortcuts
 	a.SetupCommandPalette()
+
+	// Link transactions saved before payees existed
+	a.linkPayees()
+
+	// Post recurring transactions that fell due since the last run
+	a.postDueRecurring()
 }
 
 func (a *App) Run() {
@@ -0,0 +1,179 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/nabinkatwal7/go-eila/internal/model"
+	"github.com/nabinkatwal7/go-eila/internal/repository"
+)
+
+var attachmentExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".bmp", ".pdf"}
+
+// canPreview reports whether Fyne can decode the attachment as an image.
+func canPreview(att model.Attachment) bool {
+	switch att.MimeType {
+	case "image/png", "image/jpeg", "image/gif":
+		return true
+	}
+	return false
+}
+
+// newAttachmentsSection lists a transaction's receipts and documents with
+// a thumbnail for images, and lets the user attach, open, save or remove
+// files.
+func (a *App) newAttachmentsSection(w fyne.Window, txID int64) fyne.CanvasObject {
+	list := container.NewVBox()
+	var refresh func()
+	refresh = func() {
+		list.Objects = nil
+		attachments, err := a.Repo.GetAttachments(txID)
+		if err != nil {
+			list.Add(widget.NewLabel("Error loading attachments: " + err.Error()))
+		} else if len(attachments) == 0 {
+			list.Add(widget.NewLabel("No attachments."))
+		}
+		for _, att := range attachments {
+			list.Add(a.attachmentRow(w, att, refresh))
+		}
+		list.Refresh()
+	}
+	refresh()
+
+	attachBtn := widget.NewButtonWithIcon("Attach File...", theme.ContentAddIcon(), func() {
+		dlg := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			if reader == nil {
+				return // Cancelled
+			}
+			defer reader.Close()
+
+			// Read one byte past the limit so oversized files are rejected
+			data, err := io.ReadAll(io.LimitReader(reader, repository.MaxAttachmentSize+1))
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			if _, err := a.Repo.AddAttachment(txID, reader.URI().Name(), data); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			refresh()
+		}, w)
+		dlg.SetFilter(storage.NewExtensionFileFilter(attachmentExtensions))
+		dlg.Show()
+	})
+
+	header := widget.NewLabelWithStyle("Attachments", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	return container.NewVBox(container.NewBorder(nil, nil, header, attachBtn), list)
+}
+
+func (a *App) attachmentRow(w fyne.Window, att model.Attachment, refresh func()) fyne.CanvasObject {
+	var thumb fyne.CanvasObject = widget.NewIcon(theme.FileIcon())
+	if canPreview(att) {
+		if data, err := a.Repo.GetAttachmentData(att.ID); err == nil {
+			img := canvas.NewImageFromResource(fyne.NewStaticResource(att.Name, data))
+			img.FillMode = canvas.ImageFillContain
+			img.SetMinSize(fyne.NewSize(64, 64))
+			thumb = img
+		}
+	} else if att.MimeType == "application/pdf" {
+		thumb = widget.NewIcon(theme.DocumentIcon())
+	}
+
+	info := widget.NewLabel(fmt.Sprintf("%s\n%s · %s", att.Name, formatFileSize(att.Size), att.MimeType))
+	info.Truncation = fyne.TextTruncateEllipsis
+
+	openBtn := widget.NewButtonWithIcon("", theme.VisibilityIcon(), func() {
+		a.openAttachment(w, att)
+	})
+	saveBtn := widget.NewButtonWithIcon("", theme.DocumentSaveIcon(), func() {
+		dlg := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			if writer == nil {
+				return // Cancelled
+			}
+			defer writer.Close()
+
+			data, err := a.Repo.GetAttachmentData(att.ID)
+			if err == nil {
+				_, err = writer.Write(data)
+			}
+			if err != nil {
+				dialog.ShowError(err, w)
+			}
+		}, w)
+		dlg.SetFileName(att.Name)
+		dlg.Show()
+	})
+	removeBtn := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+		dialog.ShowConfirm("Remove Attachment", fmt.Sprintf("Remove %q from this transaction?", att.Name), func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := a.Repo.DeleteAttachment(att.ID); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			refresh()
+		}, w)
+	})
+
+	return container.NewBorder(nil, nil, thumb, container.NewHBox(openBtn, saveBtn, removeBtn), info)
+}
+
+// openAttachment shows images in a preview window and hands other files,
+// such as PDFs, to the system viewer.
+func (a *App) openAttachment(parent fyne.Window, att model.Attachment) {
+	data, err := a.Repo.GetAttachmentData(att.ID)
+	if err != nil {
+		dialog.ShowError(err, parent)
+		return
+	}
+
+	if canPreview(att) {
+		img := canvas.NewImageFromResource(fyne.NewStaticResource(att.Name, data))
+		img.FillMode = canvas.ImageFillContain
+		preview := a.FyneApp.NewWindow(att.Name)
+		preview.SetContent(img)
+		preview.Resize(fyne.NewSize(600, 800))
+		preview.Show()
+		return
+	}
+
+	// Named by hash so opening the same file twice reuses the copy
+	path := filepath.Join(os.TempDir(), "mytrack-"+att.Hash[:16]+filepath.Ext(att.Name))
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		dialog.ShowError(err, parent)
+		return
+	}
+	if err := a.FyneApp.OpenURL(&url.URL{Scheme: "file", Path: path}); err != nil {
+		dialog.ShowError(err, parent)
+	}
+}
+
+func formatFileSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.0f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
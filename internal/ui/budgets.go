@@ -13,7 +13,7 @@ import (
 )
 
 func NewBudgetsView(repo *repository.Repository, a *App) fyne.CanvasObject {
-	header := widget.NewLabelWithStyle("Budgets (This Month)", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	header := widget.NewLabelWithStyle("Budgets (Current Period)", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
 
 	// Add Budget Button
 	addBtn := widget.NewButton("Set/Update Budget", func() {
@@ -21,8 +21,7 @@ func NewBudgetsView(repo *repository.Repository, a *App) fyne.CanvasObject {
 	})
 
 	// Fetch Progress
-	now := time.Now()
-	progress, err := repo.GetBudgetsWithProgress(int(now.Month()), now.Year())
+	progress, err := repo.GetBudgetsWithProgress(time.Now())
 
 	content := container.NewVBox()
 
@@ -30,26 +29,37 @@ func NewBudgetsView(repo *repository.Repository, a *App) fyne.CanvasObject {
 		content.Add(widget.NewLabelWithStyle("Error loading budgets: "+err.Error(), fyne.TextAlignLeading, fyne.TextStyle{TabWidth: 2}))
 	} else {
 		for _, p := range progress {
-			// Row: Name --- Spending / Limit
+			// Row: Name (Period, window) --- Spending / Available
 			// Progress Bar
-			info := fmt.Sprintf("%s: $%.2f / $%.2f", p.CategoryName, p.Spent, p.Budgeted)
+			name := fmt.Sprintf("%s (%s, %s – %s)", p.CategoryName, p.Period,
+				p.PeriodStart.Format("Jan 2"), p.PeriodEnd.Format("Jan 2"))
+			info := fmt.Sprintf("%s: %s / %s", name, p.Spent.Format(), p.Available.Format())
 			label := widget.NewLabelWithStyle(info, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
 
 			bar := widget.NewProgressBar()
 			bar.Value = p.Percent
 			// Visual indication for over budget
-			if p.Spent > p.Budgeted {
+			if p.Spent.Amount > p.Available.Amount {
 				label.TextStyle = fyne.TextStyle{Bold: true, Italic: true} // Just style for now, color needs canvas
 				// Format to show overage
-				label.SetText(fmt.Sprintf("%s: $%.2f / $%.2f (OVER BUDGET!)", p.CategoryName, p.Spent, p.Budgeted))
+				label.SetText(fmt.Sprintf("%s: %s / %s (OVER BUDGET!)", name, p.Spent.Format(), p.Available.Format()))
 			}
 
 			row := container.NewVBox(label, bar)
+			if p.Rollover {
+				catID, catName := p.CategoryID, p.CategoryName
+				detail := widget.NewLabel(fmt.Sprintf("Budgeted %s + carried in %s = available %s",
+					p.Budgeted.Format(), p.CarriedIn.Format(), p.Available.Format()))
+				historyBtn := widget.NewButton("Rollover History", func() {
+					showRolloverLedger(repo, a, catID, catName)
+				})
+				row.Add(container.NewHBox(detail, historyBtn))
+			}
 			content.Add(row)
 		}
 
 		if len(progress) == 0 {
-			content.Add(widget.NewLabel("No budgets set for this month."))
+			content.Add(widget.NewLabel("No budgets set for this period."))
 		}
 	}
 
@@ -65,34 +75,65 @@ func showAddBudgetModal(repo *repository.Repository, a *App) {
 	}
 
 	if len(categories) == 0 {
-		dialog.ShowInformation("No Categories", "Please create some categories first (not yet implemented in UI).", a.Window)
+		dialog.ShowInformation("No Categories", "Please create some categories first in the Categories view.", a.Window)
 		return
 	}
 
-	categoryNames := make([]string, len(categories))
-	categoryNameToID := make(map[string]int64)
-	for i, c := range categories {
-		categoryNames[i] = c.Name
-		categoryNameToID[c.Name] = c.ID
-	}
+	categoryNames, categoryNameToID := categoryOptions(categories)
 
 	catSelect := widget.NewSelect(categoryNames, nil)
 	if len(categoryNames) > 0 {
 		catSelect.Selected = categoryNames[0]
 	}
 
+	// Budgets are set in the base currency
+	base, _ := repo.GetBaseCurrency()
+
 	amtEntry := widget.NewEntry()
-	amtEntry.PlaceHolder = "Monthly Limit (e.g. 500.00)"
+	amtEntry.PlaceHolder = "Limit per period (e.g. 500.00)"
+
+	// Recurring budgets take effect from the start of the period containing
+	// this date; custom budgets run from here to the end date.
+	fromEntry := widget.NewEntry()
+	fromEntry.SetText(time.Now().Format("2006-01-02"))
+
+	endEntry := widget.NewEntry()
+	endEntry.SetPlaceHolder("YYYY-MM-DD")
+	endEntry.Disable()
+
+	rolloverCheck := widget.NewCheck("Carry unspent amount into the next period", nil)
+
+	periodSelect := widget.NewSelect([]string{
+		string(model.BudgetPeriodWeekly),
+		string(model.BudgetPeriodMonthly),
+		string(model.BudgetPeriodQuarterly),
+		string(model.BudgetPeriodYearly),
+		string(model.BudgetPeriodCustom),
+	}, func(selected string) {
+		if selected == string(model.BudgetPeriodCustom) {
+			endEntry.Enable()
+			rolloverCheck.SetChecked(false)
+			rolloverCheck.Disable()
+		} else {
+			endEntry.Disable()
+			rolloverCheck.Enable()
+		}
+	})
+	periodSelect.SetSelected(string(model.BudgetPeriodMonthly))
 
 	items := []*widget.FormItem{
 		widget.NewFormItem("Category", catSelect),
-		widget.NewFormItem("Limit ($)", amtEntry),
+		widget.NewFormItem("Period", periodSelect),
+		widget.NewFormItem("Limit ("+base+")", amtEntry),
+		widget.NewFormItem("Effective From", fromEntry),
+		widget.NewFormItem("End Date (Custom)", endEntry),
+		widget.NewFormItem("Rollover", rolloverCheck),
 	}
 
 	d := dialog.NewForm("Set Budget", "Save", "Cancel", items, func(confirm bool) {
 		if confirm {
 			// Validate inputs
-			amt, err := ValidateAmount(amtEntry.Text)
+			amt, err := ValidateAmount(amtEntry.Text, base)
 			if err != nil {
 				dialog.ShowError(err, a.Window)
 				return // Fyne dialog callback doesn't support easy 'prevent close', checking here is tricky
@@ -100,26 +141,76 @@ func showAddBudgetModal(repo *repository.Repository, a *App) {
 				// For now: Show error dialog.
 			}
 
+			from, err := ValidateDate(fromEntry.Text)
+			if err != nil {
+				dialog.ShowError(err, a.Window)
+				return
+			}
+
 			catID := categoryNameToID[catSelect.Selected]
 
 			b := &model.Budget{
-				CategoryID: catID,
-				Amount: int64(amt * 100),
-				Period: "Monthly",
+				CategoryID:    catID,
+				Amount:        amt.Amount,
+				Period:        model.BudgetPeriod(periodSelect.Selected),
+				EffectiveFrom: from,
+				Rollover:      rolloverCheck.Checked,
 			}
 
-			// Note: CreateBudget in repo currently just inserts.
-			// Ideally should upsert (update if exists for category).
-			// Assuming repo handles it or we just add new row.
-			if err := repo.CreateBudget(b); err != nil {
+			if b.Period == model.BudgetPeriodCustom {
+				end, err := ValidateDate(endEntry.Text)
+				if err != nil {
+					dialog.ShowError(err, a.Window)
+					return
+				}
+				b.EndDate = &end
+			}
+
+			// SetBudget replaces an existing budget that starts in the same
+			// period; earlier periods keep their old limit.
+			if err := repo.SetBudget(b); err != nil {
 				dialog.ShowError(err, a.Window)
 			} else {
+				a.ContentContainer.Objects = []fyne.CanvasObject{NewBudgetsView(repo, a)}
 				a.ContentContainer.Refresh()
 				dialog.ShowInformation("Success", "Budget set successfully.", a.Window)
 			}
 		}
 	}, a.Window)
 
-	d.Resize(fyne.NewSize(400, 200))
+	d.Resize(fyne.NewSize(400, 340))
+	d.Show()
+}
+
+// showRolloverLedger lists the closed periods that fed a category's carry-over.
+func showRolloverLedger(repo *repository.Repository, a *App, categoryID int64, categoryName string) {
+	entries, err := repo.GetRolloverLedger(categoryID)
+	if err != nil {
+		dialog.ShowError(err, a.Window)
+		return
+	}
+	if len(entries) == 0 {
+		dialog.ShowInformation("Rollover History", "No closed periods yet for "+categoryName+".", a.Window)
+		return
+	}
+
+	base, _ := repo.GetBaseCurrency()
+	money := func(v int64) string {
+		return model.NewMoney(v, base).Format()
+	}
+
+	list := widget.NewList(
+		func() int { return len(entries) },
+		func() fyne.CanvasObject { return widget.NewLabel("Template") },
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			e := entries[i]
+			o.(*widget.Label).SetText(fmt.Sprintf("%s – %s: in %s + budget %s − spent %s = out %s",
+				e.PeriodStart.Format("Jan 2, 2006"), e.PeriodEnd.Format("Jan 2, 2006"),
+				money(e.CarriedIn), money(e.Budgeted), money(e.Spent), money(e.CarriedOut)))
+		},
+	)
+
+	d := dialog.NewCustom("Rollover History: "+categoryName, "Close", list, a.Window)
+	d.Resize(fyne.NewSize(600, 400))
 	d.Show()
 }
@@ -0,0 +1,435 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/nabinkatwal7/go-eila/internal/model"
+	"github.com/nabinkatwal7/go-eila/internal/repository"
+)
+
+const noParentOption = "(Top level)"
+
+// categoryPath returns "Parent › Child" for a category so that
+// subcategories with the same name can be told apart in pickers.
+func categoryPath(c model.Category, byID map[int64]model.Category) string {
+	path := c.Name
+	seen := map[int64]bool{c.ID: true}
+	for c.ParentID != nil {
+		parent, ok := byID[*c.ParentID]
+		if !ok || seen[parent.ID] {
+			break
+		}
+		seen[parent.ID] = true
+		path = parent.Name + " › " + path
+		c = parent
+	}
+	return path
+}
+
+// categoryOptions builds sorted picker labels and a label -> ID lookup.
+func categoryOptions(categories []model.Category) ([]string, map[string]int64) {
+	byID := make(map[int64]model.Category, len(categories))
+	for _, c := range categories {
+		byID[c.ID] = c
+	}
+
+	names := make([]string, 0, len(categories))
+	nameToID := make(map[string]int64, len(categories))
+	for _, c := range categories {
+		label := categoryPath(c, byID)
+		names = append(names, label)
+		nameToID[label] = c.ID
+	}
+	sort.Strings(names)
+	return names, nameToID
+}
+
+func NewCategoriesView(repo *repository.Repository, a *App) fyne.CanvasObject {
+	header := widget.NewLabelWithStyle("Categories", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
+	var categories []model.Category
+	byID := make(map[int64]model.Category)
+	children := make(map[string][]string)
+	var selectedID int64
+	var tree *widget.Tree
+
+	load := func() error {
+		var err error
+		categories, err = repo.GetAllCategories()
+		if err != nil {
+			return err
+		}
+		byID = make(map[int64]model.Category, len(categories))
+		for _, c := range categories {
+			byID[c.ID] = c
+		}
+		children = make(map[string][]string)
+		for _, c := range categories {
+			parent := ""
+			if c.ParentID != nil {
+				if _, ok := byID[*c.ParentID]; ok {
+					parent = strconv.FormatInt(*c.ParentID, 10)
+				}
+			}
+			children[parent] = append(children[parent], strconv.FormatInt(c.ID, 10))
+		}
+		for _, ids := range children {
+			sort.Slice(ids, func(i, j int) bool {
+				return byID[parseCategoryUID(ids[i])].Name < byID[parseCategoryUID(ids[j])].Name
+			})
+		}
+		return nil
+	}
+
+	reload := func() {
+		if err := load(); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		if _, ok := byID[selectedID]; !ok {
+			selectedID = 0
+		}
+		tree.Refresh()
+	}
+
+	if err := load(); err != nil {
+		return widget.NewLabel("Error loading categories: " + err.Error())
+	}
+
+	tree = widget.NewTree(
+		func(uid widget.TreeNodeID) []widget.TreeNodeID {
+			return children[uid]
+		},
+		func(uid widget.TreeNodeID) bool {
+			return uid == "" || len(children[uid]) > 0
+		},
+		func(branch bool) fyne.CanvasObject {
+			return widget.NewLabel("Category")
+		},
+		func(uid widget.TreeNodeID, branch bool, o fyne.CanvasObject) {
+			c := byID[parseCategoryUID(uid)]
+			label := c.Name
+			if c.Icon != "" {
+				label = c.Icon + " " + label
+			}
+			o.(*widget.Label).SetText(label)
+		},
+	)
+	tree.OnSelected = func(uid widget.TreeNodeID) {
+		selectedID = parseCategoryUID(uid)
+	}
+
+	requireSelection := func() (model.Category, bool) {
+		c, ok := byID[selectedID]
+		if !ok {
+			dialog.ShowInformation("No Category Selected", "Select a category in the list first.", a.Window)
+		}
+		return c, ok
+	}
+
+	addBtn := widget.NewButton("+ New Category", func() {
+		showCreateCategoryModal(repo, a, categories, nil, reload)
+	})
+
+	addChildBtn := widget.NewButton("Add Subcategory", func() {
+		if c, ok := requireSelection(); ok {
+			showCreateCategoryModal(repo, a, categories, &c.ID, reload)
+		}
+	})
+
+	moveBtn := widget.NewButton("Move", func() {
+		if c, ok := requireSelection(); ok {
+			showMoveCategoryModal(repo, a, categories, c, reload)
+		}
+	})
+
+	mergeBtn := widget.NewButton("Merge Into...", func() {
+		if c, ok := requireSelection(); ok {
+			showMergeCategoryModal(repo, a, categories, c, reload)
+		}
+	})
+
+	editBtn := widget.NewButton("Edit", func() {
+		if c, ok := requireSelection(); ok {
+			showEditCategoryModal(repo, a, c, reload)
+		}
+	})
+
+	deleteBtn := widget.NewButton("Delete", func() {
+		if c, ok := requireSelection(); ok {
+			showDeleteCategoryDialog(repo, a, categories, c, reload)
+		}
+	})
+
+	actions := container.NewHBox(addChildBtn, editBtn, moveBtn, mergeBtn, deleteBtn)
+
+	return container.NewBorder(
+		container.NewVBox(container.NewHBox(header, addBtn), actions),
+		nil, nil, nil,
+		tree,
+	)
+}
+
+func parseCategoryUID(uid string) int64 {
+	id, _ := strconv.ParseInt(uid, 10, 64)
+	return id
+}
+
+// parentOptions lists the categories c may be placed under, excluding c and
+// its own descendants. Pass a zero Category to allow every category.
+func parentOptions(categories []model.Category, c model.Category) ([]string, map[string]int64) {
+	excluded := map[int64]bool{}
+	if c.ID != 0 {
+		excluded[c.ID] = true
+		for changed := true; changed; {
+			changed = false
+			for _, other := range categories {
+				if other.ParentID != nil && excluded[*other.ParentID] && !excluded[other.ID] {
+					excluded[other.ID] = true
+					changed = true
+				}
+			}
+		}
+	}
+
+	byID := make(map[int64]model.Category, len(categories))
+	for _, other := range categories {
+		byID[other.ID] = other
+	}
+
+	names := []string{}
+	nameToID := make(map[string]int64)
+	for _, other := range categories {
+		if excluded[other.ID] {
+			continue
+		}
+		label := categoryPath(other, byID)
+		names = append(names, label)
+		nameToID[label] = other.ID
+	}
+	sort.Strings(names)
+	return append([]string{noParentOption}, names...), nameToID
+}
+
+func showCreateCategoryModal(repo *repository.Repository, a *App, categories []model.Category, parentID *int64, onDone func()) {
+	nameEntry := widget.NewEntry()
+	nameEntry.PlaceHolder = "Category Name (e.g., 'Groceries')"
+
+	parentNames, parentNameToID := parentOptions(categories, model.Category{})
+	parentSelect := widget.NewSelect(parentNames, nil)
+	parentSelect.SetSelected(noParentOption)
+	if parentID != nil {
+		for label, id := range parentNameToID {
+			if id == *parentID {
+				parentSelect.SetSelected(label)
+			}
+		}
+	}
+
+	iconEntry := widget.NewEntry()
+	iconEntry.PlaceHolder = "Optional, e.g. 🛒"
+
+	colorEntry := widget.NewEntry()
+	colorEntry.PlaceHolder = "#RRGGBB"
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Parent", parentSelect),
+		widget.NewFormItem("Icon", iconEntry),
+		widget.NewFormItem("Color", colorEntry),
+	}
+
+	dialog.ShowForm("New Category", "Create", "Cancel", items, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		if err := ValidateRequired(nameEntry.Text, "Category Name"); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		color, err := ValidateColor(colorEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+
+		c := &model.Category{Name: nameEntry.Text, Icon: strings.TrimSpace(iconEntry.Text), Color: color}
+		if id, ok := parentNameToID[parentSelect.Selected]; ok {
+			c.ParentID = &id
+		}
+		if err := repo.CreateCategory(c); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		onDone()
+	}, a.Window)
+}
+
+func showMoveCategoryModal(repo *repository.Repository, a *App, categories []model.Category, c model.Category, onDone func()) {
+	parentNames, parentNameToID := parentOptions(categories, c)
+	parentSelect := widget.NewSelect(parentNames, nil)
+	parentSelect.SetSelected(noParentOption)
+	if c.ParentID != nil {
+		for label, id := range parentNameToID {
+			if id == *c.ParentID {
+				parentSelect.SetSelected(label)
+			}
+		}
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("New Parent", parentSelect),
+	}
+
+	dialog.ShowForm(fmt.Sprintf("Move '%s'", c.Name), "Move", "Cancel", items, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		var newParent *int64
+		if id, ok := parentNameToID[parentSelect.Selected]; ok {
+			newParent = &id
+		}
+		if err := repo.MoveCategory(c.ID, newParent); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		onDone()
+	}, a.Window)
+}
+
+func showMergeCategoryModal(repo *repository.Repository, a *App, categories []model.Category, c model.Category, onDone func()) {
+	targetNames, targetNameToID := parentOptions(categories, c)
+	targetNames = targetNames[1:] // Can't merge into "top level"
+	if len(targetNames) == 0 {
+		dialog.ShowInformation("Nothing to Merge Into", "There are no other categories to merge into.", a.Window)
+		return
+	}
+
+	targetSelect := widget.NewSelect(targetNames, nil)
+	targetSelect.SetSelected(targetNames[0])
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Merge into", targetSelect),
+	}
+
+	dialog.ShowForm(fmt.Sprintf("Merge '%s'", c.Name), "Merge", "Cancel", items, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		if err := repo.MergeCategory(c.ID, targetNameToID[targetSelect.Selected]); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		onDone()
+	}, a.Window)
+}
+
+func showEditCategoryModal(repo *repository.Repository, a *App, c model.Category, onDone func()) {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(c.Name)
+
+	iconEntry := widget.NewEntry()
+	iconEntry.SetText(c.Icon)
+	iconEntry.PlaceHolder = "Optional, e.g. 🛒"
+
+	colorEntry := widget.NewEntry()
+	colorEntry.SetText(c.Color)
+	colorEntry.PlaceHolder = "#RRGGBB"
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Icon", iconEntry),
+		widget.NewFormItem("Color", colorEntry),
+	}
+
+	dialog.ShowForm(fmt.Sprintf("Edit '%s'", c.Name), "Save", "Cancel", items, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		if err := ValidateRequired(nameEntry.Text, "Category Name"); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		color, err := ValidateColor(colorEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+
+		c.Name = nameEntry.Text
+		c.Icon = strings.TrimSpace(iconEntry.Text)
+		c.Color = color
+		if err := repo.UpdateCategory(&c); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		onDone()
+	}, a.Window)
+}
+
+func showDeleteCategoryDialog(repo *repository.Repository, a *App, categories []model.Category, c model.Category, onDone func()) {
+	refs, err := repo.CategoryUsage(c.ID)
+	if err != nil {
+		dialog.ShowError(err, a.Window)
+		return
+	}
+
+	if refs == 0 {
+		dialog.ShowConfirm("Delete Category",
+			fmt.Sprintf("Delete '%s'? Its subcategories will move up one level.", c.Name),
+			func(confirmed bool) {
+				if !confirmed {
+					return
+				}
+				if err := repo.DeleteCategory(c.ID, 0); err != nil {
+					dialog.ShowError(err, a.Window)
+					return
+				}
+				onDone()
+			}, a.Window)
+		return
+	}
+
+	// Still in use: the user must pick where its transactions, budgets and rules go.
+	var targetNames []string
+	targetNameToID := make(map[string]int64)
+	names, nameToID := categoryOptions(categories)
+	for _, name := range names {
+		if nameToID[name] == c.ID {
+			continue
+		}
+		targetNames = append(targetNames, name)
+		targetNameToID[name] = nameToID[name]
+	}
+	if len(targetNames) == 0 {
+		dialog.ShowError(errors.New("create another category to move its transactions to first"), a.Window)
+		return
+	}
+
+	targetSelect := widget.NewSelect(targetNames, nil)
+	targetSelect.SetSelected(targetNames[0])
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Used by", widget.NewLabel(fmt.Sprintf("%d transactions, budgets or rules", refs))),
+		widget.NewFormItem("Replace with", targetSelect),
+	}
+
+	dialog.ShowForm(fmt.Sprintf("Delete '%s'", c.Name), "Delete", "Cancel", items, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		if err := repo.DeleteCategory(c.ID, targetNameToID[targetSelect.Selected]); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		onDone()
+	}, a.Window)
+}
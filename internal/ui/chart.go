@@ -1,7 +1,6 @@
 package ui
 
 import (
-	"fmt"
 	"image/color"
 
 	"fyne.io/fyne/v2"
@@ -25,8 +24,8 @@ func NewBarChart(stats []model.MonthlyStat) fyne.CanvasObject {
 	// Find max value for scaling
 	var maxVal float64
 	for _, s := range stats {
-		if s.Income > maxVal { maxVal = s.Income }
-		if s.Expense > maxVal { maxVal = s.Expense }
+		if s.Income.Float64() > maxVal { maxVal = s.Income.Float64() }
+		if s.Expense.Float64() > maxVal { maxVal = s.Expense.Float64() }
 	}
 	if maxVal == 0 { maxVal = 100 } // Avoid div by zero
 
@@ -36,7 +35,7 @@ func NewBarChart(stats []model.MonthlyStat) fyne.CanvasObject {
 		xBase := float32(i) * (barWidth*2 + spacing)
 
 		// Income Bar (Green)
-		incHeight := float32(s.Income / maxVal) * maxHeight
+		incHeight := float32(s.Income.Float64() / maxVal) * maxHeight
 		incBar := canvas.NewRectangle(color.RGBA{0, 200, 0, 200})
 		incBar.Resize(fyne.NewSize(barWidth, incHeight))
 		// Position: y starts from bottom.
@@ -47,7 +46,7 @@ func NewBarChart(stats []model.MonthlyStat) fyne.CanvasObject {
 		incBar.Move(fyne.NewPos(xBase, baseY-incHeight))
 
 		// Expense Bar (Red)
-		expHeight := float32(s.Expense / maxVal) * maxHeight
+		expHeight := float32(s.Expense.Float64() / maxVal) * maxHeight
 		expBar := canvas.NewRectangle(color.RGBA{200, 0, 0, 200})
 		expBar.Resize(fyne.NewSize(barWidth, expHeight))
 		expBar.Move(fyne.NewPos(xBase+barWidth, baseY-expHeight))
@@ -82,11 +81,11 @@ func NewLineChart(points []model.NetWorthPoint) fyne.CanvasObject {
 	// Find max and min values for scaling
 	var maxVal, minVal float64
 	for _, p := range points {
-		if p.NetWorth > maxVal {
-			maxVal = p.NetWorth
+		if p.NetWorth.Float64() > maxVal {
+			maxVal = p.NetWorth.Float64()
 		}
-		if p.NetWorth < minVal {
-			minVal = p.NetWorth
+		if p.NetWorth.Float64() < minVal {
+			minVal = p.NetWorth.Float64()
 		}
 	}
 	rangeVal := maxVal - minVal
@@ -100,9 +99,9 @@ func NewLineChart(points []model.NetWorthPoint) fyne.CanvasObject {
 	// Draw line connecting points
 	for i := 0; i < len(points)-1; i++ {
 		x1 := float32(i) * pointSpacing
-		y1 := baseY - float32((points[i].NetWorth-minVal)/rangeVal)*maxHeight
+		y1 := baseY - float32((points[i].NetWorth.Float64()-minVal)/rangeVal)*maxHeight
 		x2 := float32(i+1) * pointSpacing
-		y2 := baseY - float32((points[i+1].NetWorth-minVal)/rangeVal)*maxHeight
+		y2 := baseY - float32((points[i+1].NetWorth.Float64()-minVal)/rangeVal)*maxHeight
 
 		line := canvas.NewLine(color.RGBA{0, 100, 200, 255})
 		line.StrokeWidth = 2
@@ -114,7 +113,7 @@ func NewLineChart(points []model.NetWorthPoint) fyne.CanvasObject {
 	// Draw points and labels
 	for i, p := range points {
 		x := float32(i) * pointSpacing
-		y := baseY - float32((p.NetWorth-minVal)/rangeVal)*maxHeight
+		y := baseY - float32((p.NetWorth.Float64()-minVal)/rangeVal)*maxHeight
 
 		// Point
 		point := canvas.NewCircle(color.RGBA{0, 100, 200, 255})
@@ -133,16 +132,17 @@ func NewLineChart(points []model.NetWorthPoint) fyne.CanvasObject {
 	return container.NewPadded(chartContainer)
 }
 
-// NewPieChart creates a simple pie chart visualization for category breakdown
-func NewPieChart(breakdown []model.CategoryBreakdown) fyne.CanvasObject {
+// NewPieChart creates a simple pie chart visualization for category breakdown.
+// If onSelect is set, tapping a category that has subcategories calls it to drill down.
+func NewPieChart(breakdown []model.CategoryBreakdown, onSelect func(model.CategoryBreakdown)) fyne.CanvasObject {
 	if len(breakdown) == 0 {
 		return widget.NewLabel("No data for chart")
 	}
 
 	// Calculate total
-	var total float64
+	var total int64
 	for _, b := range breakdown {
-		total += b.Amount
+		total += b.Amount.Amount
 	}
 
 	if total == 0 {
@@ -164,11 +164,24 @@ func NewPieChart(breakdown []model.CategoryBreakdown) fyne.CanvasObject {
 		func(i int, o fyne.CanvasObject) {
 			b := breakdown[i]
 			box := o.(*fyne.Container)
-			box.Objects[0].(*widget.Label).SetText(b.CategoryName)
-			box.Objects[1].(*widget.Label).SetText(fmt.Sprintf("$%.2f", b.Amount))
-			box.Objects[2].(*widget.ProgressBar).SetValue(b.Amount / total)
+			name := b.CategoryName
+			if b.HasChildren {
+				name += " ›"
+			}
+			box.Objects[0].(*widget.Label).SetText(name)
+			box.Objects[1].(*widget.Label).SetText(b.Amount.Format())
+			box.Objects[2].(*widget.ProgressBar).SetValue(float64(b.Amount.Amount) / float64(total))
 		},
 	)
 
+	if onSelect != nil {
+		list.OnSelected = func(i widget.ListItemID) {
+			list.UnselectAll()
+			if breakdown[i].HasChildren {
+				onSelect(breakdown[i])
+			}
+		}
+	}
+
 	return container.NewPadded(list)
 }
\ No newline at end of file
@@ -24,9 +24,14 @@ func (a *App) SetupCommandPalette() {
 		{"Go to Transactions", "View transaction history", func() { a.ContentContainer.Objects = []fyne.CanvasObject{NewTransactionsView(a.Repo, a)}; a.ContentContainer.Refresh() }},
 		{"Go to Accounts", "Manage accounts", func() { a.ContentContainer.Objects = []fyne.CanvasObject{NewAccountsView(a.Repo, a)}; a.ContentContainer.Refresh() }},
 		{"Go to Budgets", "Manage spending limits", func() { a.ContentContainer.Objects = []fyne.CanvasObject{NewBudgetsView(a.Repo, a)}; a.ContentContainer.Refresh() }},
-		{"Go to Recurring", "View detected subscriptions", func() { a.ContentContainer.Objects = []fyne.CanvasObject{NewRecurringView(a.Repo)}; a.ContentContainer.Refresh() }},
-		{"Go to Alerts", "View spending anomalies", func() { a.ContentContainer.Objects = []fyne.CanvasObject{NewAnomaliesView(a.Repo)}; a.ContentContainer.Refresh() }},
-		{"Go to Settings", "Backup and Data options", func() { a.ContentContainer.Objects = []fyne.CanvasObject{NewSettingsView(a.Repo, a.Window)}; a.ContentContainer.Refresh() }},
+		{"Go to Envelopes", "Assign income to envelopes", func() { a.ContentContainer.Objects = []fyne.CanvasObject{NewEnvelopesView(a.Repo, a)}; a.ContentContainer.Refresh() }},
+		{"Go to Categories", "Organise categories and subcategories", func() { a.ContentContainer.Objects = []fyne.CanvasObject{NewCategoriesView(a.Repo, a)}; a.ContentContainer.Refresh() }},
+		{"Go to Recurring", "Scheduled transactions and detected subscriptions", func() { a.ContentContainer.Objects = []fyne.CanvasObject{NewRecurringView(a.Repo, a)}; a.ContentContainer.Refresh() }},
+		{"Find Duplicates", "Review transactions entered twice", func() { showDuplicatesView(a) }},
+		{"Go to Payees", "Payees, aliases and top payees", func() { showPayeesView(a) }},
+		{"Go to Rules", "Auto-categorization rules", func() { a.ContentContainer.Objects = []fyne.CanvasObject{NewRulesView(a.Repo, a)}; a.ContentContainer.Refresh() }},
+		{"Go to Alerts", "View spending anomalies", func() { a.ContentContainer.Objects = []fyne.CanvasObject{NewAnomaliesView(a.Repo, a)}; a.ContentContainer.Refresh() }},
+		{"Go to Settings", "Backup and Data options", func() { a.ContentContainer.Objects = []fyne.CanvasObject{NewSettingsView(a.Repo, a)}; a.ContentContainer.Refresh() }},
 		{"Go to Forecast", "Project future net worth", func() { a.ContentContainer.Objects = []fyne.CanvasObject{NewForecastView(a.Repo)}; a.ContentContainer.Refresh() }},
 		{"Go to Tools", "Calculators (Debt, Tax)", func() { a.ContentContainer.Objects = []fyne.CanvasObject{NewToolsView(a.Repo)}; a.ContentContainer.Refresh() }},
 		{"Go to Invoicing", "Create invoices", func() { a.ContentContainer.Objects = []fyne.CanvasObject{NewInvoicingView(a.Repo)}; a.ContentContainer.Refresh() }},
@@ -23,7 +23,7 @@ func (a *App) ShowCreateAccountModal() {
 	typeSelect.Selected = string(model.AccountTypeBank)
 
 	// Currency Select
-	currencySelect := widget.NewSelect([]string{"USD", "EUR", "GBP", "NPR", "JPY"}, nil)
+	currencySelect := widget.NewSelect(currencyOptions, nil)
 	currencySelect.Selected = "USD"
 
 	items := []*widget.FormItem{
@@ -0,0 +1,225 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/nabinkatwal7/go-eila/internal/model"
+	"github.com/nabinkatwal7/go-eila/internal/repository"
+)
+
+var currencyOptions = model.CurrencyCodes()
+
+// newCurrencySettings holds the base currency picker and the rate history.
+func newCurrencySettings(repo *repository.Repository, w fyne.Window) fyne.CanvasObject {
+	base, err := repo.GetBaseCurrency()
+	if err != nil {
+		return widget.NewLabel("Error loading base currency: " + err.Error())
+	}
+
+	baseSelect := widget.NewSelect(currencyOptions, nil)
+	baseSelect.SetSelected(base)
+	baseSelect.OnChanged = func(code string) {
+		if code == base {
+			return
+		}
+		dialog.ShowConfirm("Change Base Currency",
+			fmt.Sprintf("Report everything in %s? Stored rates on every transaction will be re-derived.", code),
+			func(ok bool) {
+				if !ok {
+					baseSelect.SetSelected(base)
+					return
+				}
+				if err := repo.SetBaseCurrency(code); err != nil {
+					dialog.ShowError(err, w)
+					baseSelect.SetSelected(base)
+					return
+				}
+				base = code
+			}, w)
+	}
+
+	ratesBtn := widget.NewButton("Exchange Rates", func() {
+		showExchangeRatesDialog(repo, w)
+	})
+
+	return container.NewHBox(widget.NewLabel("Base Currency"), baseSelect, ratesBtn)
+}
+
+// showExchangeRatesDialog lists stored rates, newest first, and lets the
+// user add or delete them.
+func showExchangeRatesDialog(repo *repository.Repository, w fyne.Window) {
+	var rates []model.ExchangeRate
+	load := func() {
+		var err error
+		rates, err = repo.GetExchangeRates("", "")
+		if err != nil {
+			dialog.ShowError(err, w)
+		}
+	}
+	load()
+
+	var list *widget.List
+	list = widget.NewList(
+		func() int { return len(rates) },
+		func() fyne.CanvasObject {
+			return container.NewBorder(nil, nil, nil, widget.NewButton("Delete", nil), widget.NewLabel("Template"))
+		},
+		func(i int, o fyne.CanvasObject) {
+			er := rates[i]
+			box := o.(*fyne.Container)
+			text := fmt.Sprintf("%s   1 %s = %s %s", er.Date.Format("2006-01-02"), er.From,
+				strconv.FormatFloat(er.Rate, 'f', -1, 64), er.To)
+			if er.Source != "" {
+				text += "   (" + er.Source + ")"
+			}
+			box.Objects[0].(*widget.Label).SetText(text)
+			box.Objects[1].(*widget.Button).OnTapped = func() {
+				if err := repo.DeleteExchangeRate(er.ID); err != nil {
+					dialog.ShowError(err, w)
+					return
+				}
+				load()
+				list.Refresh()
+			}
+		},
+	)
+
+	addBtn := widget.NewButton("+ Add Rate", func() {
+		showAddExchangeRateModal(repo, w, func() {
+			load()
+			list.Refresh()
+		})
+	})
+
+	content := container.NewBorder(addBtn, nil, nil, nil, list)
+	d := dialog.NewCustom("Exchange Rates", "Close", content, w)
+	d.Resize(fyne.NewSize(520, 420))
+	d.Show()
+}
+
+func showAddExchangeRateModal(repo *repository.Repository, w fyne.Window, onDone func()) {
+	base, _ := repo.GetBaseCurrency()
+
+	dateEntry := widget.NewEntry()
+	dateEntry.SetText(time.Now().Format("2006-01-02"))
+	fromSelect := widget.NewSelect(currencyOptions, nil)
+	fromSelect.SetSelected("EUR")
+	toSelect := widget.NewSelect(currencyOptions, nil)
+	toSelect.SetSelected(base)
+	rateEntry := widget.NewEntry()
+	rateEntry.SetPlaceHolder("Units of To per 1 From")
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Date", dateEntry),
+		widget.NewFormItem("From", fromSelect),
+		widget.NewFormItem("To", toSelect),
+		widget.NewFormItem("Rate", rateEntry),
+	}
+
+	dialog.ShowForm("Add Exchange Rate", "Save", "Cancel", items, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		date, err := ValidateDate(dateEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rateEntry.Text), 64)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("invalid rate: %s", rateEntry.Text), w)
+			return
+		}
+		er := &model.ExchangeRate{Date: date, From: fromSelect.Selected, To: toSelect.Selected, Rate: rate, Source: "manual"}
+		if err := repo.AddExchangeRate(er); err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		onDone()
+	}, w)
+}
+
+// rateImportSummary describes an import, ending with any currencies that
+// still cannot be valued.
+func rateImportSummary(report *model.RateImportReport) string {
+	text := fmt.Sprintf("%s file: %d new rate(s), %d updated, %d day(s) forward-filled.",
+		report.Format, report.Imported, report.Updated, report.Filled)
+	if report.Duplicates > 0 {
+		text += fmt.Sprintf("\n%d duplicate row(s) in the file; the last value was kept.", report.Duplicates)
+	}
+	if report.Skipped > 0 {
+		text += fmt.Sprintf("\n%d blank or unreadable value(s) skipped.", report.Skipped)
+	}
+	if len(report.Uncovered) > 0 {
+		text += "\n\nStill no rate coverage for: " + strings.Join(report.Uncovered, ", ") +
+			".\nAdd rates for these currencies before their first transaction."
+	} else {
+		text += "\n\nEvery account currency has rate coverage."
+	}
+	return text
+}
+
+// showFXGainLossDialog reports unrealized gains and losses on accounts held
+// in a foreign currency, valued today.
+func showFXGainLossDialog(repo *repository.Repository, w fyne.Window) {
+	vals, err := repo.GetFXGainLoss(time.Now())
+	if err != nil {
+		dialog.ShowError(err, w)
+		return
+	}
+	base, _ := repo.GetBaseCurrency()
+
+	content := container.NewVBox()
+	var total int64
+	for _, v := range vals {
+		if v.MissingRate {
+			content.Add(widget.NewLabel(fmt.Sprintf("%s: %s (no exchange rate to %s)",
+				v.AccountName, model.NewMoney(v.Balance, v.Currency).Format(), base)))
+			continue
+		}
+		total += v.UnrealizedFX
+		content.Add(widget.NewLabel(fmt.Sprintf("%s: %s = %s (cost %s, %s %s)",
+			v.AccountName, model.NewMoney(v.Balance, v.Currency).Format(), model.NewMoney(v.BaseValue, base).Format(),
+			model.NewMoney(v.CostBasis, base).Format(), gainOrLoss(v.UnrealizedFX), model.NewMoney(abs64(v.UnrealizedFX), base).Format())))
+	}
+	if len(vals) == 0 {
+		content.Add(widget.NewLabel("No accounts are held in a currency other than " + base + "."))
+	} else {
+		content.Add(widget.NewSeparator())
+		content.Add(widget.NewLabelWithStyle(fmt.Sprintf("Total unrealized %s: %s",
+			gainOrLoss(total), model.NewMoney(abs64(total), base).Format()), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	}
+
+	dialog.ShowCustom("Unrealized FX Gains/Losses", "Close", content, w)
+}
+
+// accountCurrency returns the currency an account is kept in.
+func accountCurrency(accounts []model.Account, id int64) string {
+	for _, acc := range accounts {
+		if acc.ID == id {
+			return acc.Currency
+		}
+	}
+	return ""
+}
+
+func gainOrLoss(cents int64) string {
+	if cents < 0 {
+		return "loss"
+	}
+	return "gain"
+}
+
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
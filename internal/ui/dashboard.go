@@ -1,29 +1,57 @@
 package ui
 
 import (
-	"fmt"
 	"image/color"
 	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
+	"github.com/nabinkatwal7/go-eila/internal/model"
 	"github.com/nabinkatwal7/go-eila/internal/repository"
 )
 
 func NewDashboard(repo *repository.Repository) fyne.CanvasObject {
-	stats, err := repo.GetDashboardStats()
+	body := container.NewStack()
+	var show func(asOf time.Time)
+	show = func(asOf time.Time) {
+		body.Objects = []fyne.CanvasObject{newDashboardAsOf(repo, asOf, show)}
+		body.Refresh()
+	}
+	show(time.Now())
+	return body
+}
+
+// newDashboardAsOf values the dashboard in the base currency on asOf;
+// choosing another date calls show to rebuild it.
+func newDashboardAsOf(repo *repository.Repository, asOf time.Time, show func(time.Time)) fyne.CanvasObject {
+	var warning fyne.CanvasObject = layout.NewSpacer()
+	stats, err := repo.GetDashboardStats(asOf)
 	if err != nil {
 		stats = &repository.DashboardStats{} // Empty on error
+		warning = widget.NewLabel("Could not value accounts: " + err.Error())
 	}
+	base, _ := repo.GetBaseCurrency()
+
+	asOfEntry := widget.NewEntry()
+	asOfEntry.SetText(asOf.Format("2006-01-02"))
+	asOfBtn := widget.NewButton("Value As Of", func() {
+		date, err := ValidateDate(asOfEntry.Text)
+		if err != nil {
+			asOfEntry.SetText(asOf.Format("2006-01-02"))
+			return
+		}
+		show(date)
+	})
 
 	// 4 Pillars
-	incomeCard := createInfoCard("Income", fmt.Sprintf("$%.2f", stats.TotalIncome), color.RGBA{0, 200, 0, 255})
-	expenseCard := createInfoCard("Expenses", fmt.Sprintf("$%.2f", stats.TotalExpense), color.RGBA{200, 0, 0, 255})
-	assetCard := createInfoCard("Assets", fmt.Sprintf("$%.2f", stats.TotalAssets), color.RGBA{0, 0, 200, 255})
+	incomeCard := createInfoCard("Income", stats.TotalIncome.Format(), color.RGBA{0, 200, 0, 255})
+	expenseCard := createInfoCard("Expenses", stats.TotalExpense.Format(), color.RGBA{200, 0, 0, 255})
+	assetCard := createInfoCard("Assets", stats.TotalAssets.Format(), color.RGBA{0, 0, 200, 255})
 	// Net Worth
-	netWorthCard := createInfoCard("Net Worth", fmt.Sprintf("$%.2f", stats.NetWorth), color.RGBA{100, 100, 100, 255})
+	netWorthCard := createInfoCard("Net Worth", stats.NetWorth.Format(), color.RGBA{100, 100, 100, 255})
 
 	pillars := container.NewGridWithColumns(4, incomeCard, expenseCard, assetCard, netWorthCard)
 
@@ -32,14 +60,32 @@ func NewDashboard(repo *repository.Repository) fyne.CanvasObject {
 	incomeExpenseChart := NewBarChart(chartStats)
 
 	// Net Worth Progression
-	netWorthHistory, _ := repo.GetNetWorthHistory(12)
+	netWorthHistory, _ := repo.GetNetWorthHistory(12, asOf)
 	netWorthChart := NewLineChart(netWorthHistory)
 
-	// Category Breakdown (last 30 days)
+	// Category Breakdown (last 30 days), with drill-down into subcategories
 	now := time.Now()
 	thirtyDaysAgo := now.AddDate(0, 0, -30)
-	categoryBreakdown, _ := repo.GetCategoryBreakdown(&thirtyDaysAgo, &now)
-	categoryChart := NewPieChart(categoryBreakdown)
+	categoryChart := container.NewVBox()
+	var showCategoryLevel func(parentID int64, title string)
+	showCategoryLevel = func(parentID int64, title string) {
+		breakdown, _ := repo.GetSubcategoryBreakdown(parentID, &thirtyDaysAgo, &now)
+		chart := NewPieChart(breakdown, func(b model.CategoryBreakdown) {
+			showCategoryLevel(b.CategoryID, b.CategoryName)
+		})
+		categoryChart.Objects = []fyne.CanvasObject{chart}
+		if parentID != 0 {
+			backBtn := widget.NewButton("‹ All Categories", func() {
+				showCategoryLevel(0, "")
+			})
+			categoryChart.Objects = []fyne.CanvasObject{
+				container.NewHBox(backBtn, widget.NewLabel(title)),
+				chart,
+			}
+		}
+		categoryChart.Refresh()
+	}
+	showCategoryLevel(0, "")
 
 	incomeExpenseArea := container.NewVBox(
 		widget.NewLabelWithStyle("Income vs Expense (6 Months)", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
@@ -56,10 +102,40 @@ func NewDashboard(repo *repository.Repository) fyne.CanvasObject {
 		container.NewCenter(categoryChart),
 	)
 
+	// Top payees over the same 30 days
+	payeeList := container.NewVBox()
+	topPayees, _ := repo.GetPayeeBreakdown(&thirtyDaysAgo, &now, 5)
+	for _, p := range topPayees {
+		payeeList.Add(container.NewBorder(nil, nil, nil, widget.NewLabel(p.Amount.Format()), widget.NewLabel(p.Name)))
+	}
+	if len(topPayees) == 0 {
+		payeeList.Add(widget.NewLabel("No spending yet."))
+	}
+	payeeArea := container.NewVBox(
+		widget.NewLabelWithStyle("Top Payees (Last 30 Days)", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		payeeList,
+	)
+
+	// Spending by tag over the same 30 days
+	tagList := container.NewVBox()
+	tagBreakdown, _ := repo.GetTagBreakdown(&thirtyDaysAgo, &now)
+	for _, b := range tagBreakdown {
+		tagList.Add(container.NewBorder(nil, nil, nil, widget.NewLabel(b.Amount.Format()), widget.NewLabel("#"+b.Tag)))
+	}
+	if len(tagBreakdown) == 0 {
+		tagList.Add(widget.NewLabel("No tagged spending yet."))
+	}
+	tagArea := container.NewVBox(
+		widget.NewLabelWithStyle("Spending by Tag (Last 30 Days)", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		tagList,
+	)
+
 	header := widget.NewLabelWithStyle("Dashboard", fyne.TextAlignLeading, fyne.TextStyle{Bold: true, Monospace: true})
+	valuedIn := widget.NewLabel("Values in " + base)
 
 	return container.NewVScroll(container.NewVBox(
-		header,
+		container.NewHBox(header, layout.NewSpacer(), valuedIn, asOfEntry, asOfBtn),
+		warning,
 		pillars,
 		widget.NewSeparator(),
 		incomeExpenseArea,
@@ -67,6 +143,10 @@ func NewDashboard(repo *repository.Repository) fyne.CanvasObject {
 		netWorthArea,
 		widget.NewSeparator(),
 		categoryArea,
+		widget.NewSeparator(),
+		payeeArea,
+		widget.NewSeparator(),
+		tagArea,
 	))
 }
 
@@ -77,7 +157,7 @@ func createInfoCard(title, amount string, c color.Color) fyne.CanvasObject {
 	rect.SetMinSize(fyne.NewSize(150, 80))
 
 	titleLabel := widget.NewLabelWithStyle(title, fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
-	amountLabel := widget.NewLabelWithStyle("$"+amount, fyne.TextAlignCenter, fyne.TextStyle{})
+	amountLabel := widget.NewLabelWithStyle(amount, fyne.TextAlignCenter, fyne.TextStyle{})
 
 	content := container.NewVBox(titleLabel, amountLabel)
 
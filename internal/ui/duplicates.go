@@ -0,0 +1,119 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/nabinkatwal7/go-eila/internal/model"
+	"github.com/nabinkatwal7/go-eila/internal/repository"
+)
+
+func showDuplicatesView(a *App) {
+	a.ContentContainer.Objects = []fyne.CanvasObject{NewDuplicatesView(a.Repo, a)}
+	a.ContentContainer.Refresh()
+}
+
+// NewDuplicatesView lists likely duplicate transactions side by side. Each
+// pair is merged into the copy the user keeps, or marked as not a duplicate.
+func NewDuplicatesView(repo *repository.Repository, a *App) fyne.CanvasObject {
+	header := widget.NewLabelWithStyle("Possible Duplicates", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	backBtn := widget.NewButton("‹ Transactions", func() {
+		a.ContentContainer.Objects = []fyne.CanvasObject{NewTransactionsView(repo, a)}
+		a.ContentContainer.Refresh()
+	})
+
+	pairs, err := repo.FindDuplicates()
+	content := container.NewVBox()
+	if err != nil {
+		content.Add(widget.NewLabel("Error: " + err.Error()))
+	} else if len(pairs) == 0 {
+		content.Add(widget.NewLabel("No duplicate transactions found."))
+	}
+
+	accounts, _ := repo.GetAllAccounts()
+	accountNames := make(map[int64]string, len(accounts))
+	for _, acc := range accounts {
+		accountNames[acc.ID] = acc.Name
+	}
+	categories, _ := repo.GetAllCategories()
+	categoryNames := make(map[int64]string, len(categories))
+	for _, c := range categories {
+		categoryNames[c.ID] = c.Name
+	}
+
+	merge := func(keep, drop int64) {
+		if err := repo.MergeDuplicates(keep, drop); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		showDuplicatesView(a)
+	}
+
+	for _, p := range pairs {
+		p := p
+		keepA := widget.NewButton("Keep Left", func() { merge(p.A.ID, p.B.ID) })
+		keepB := widget.NewButton("Keep Right", func() { merge(p.B.ID, p.A.ID) })
+		// A reconciled copy is confirmed by a statement, so it is the one kept
+		if p.B.Status == model.TransactionStatusReconciled {
+			keepA.Disable()
+		}
+		if p.A.Status == model.TransactionStatusReconciled {
+			keepB.Disable()
+		}
+		notDup := widget.NewButton("Not a Duplicate", func() {
+			if err := repo.DismissDuplicate(p.A.ID, p.B.ID); err != nil {
+				dialog.ShowError(err, a.Window)
+				return
+			}
+			showDuplicatesView(a)
+		})
+
+		sides := container.NewGridWithColumns(2,
+			duplicateSide(p.A, accountNames, categoryNames),
+			duplicateSide(p.B, accountNames, categoryNames),
+		)
+		match := widget.NewLabel(fmt.Sprintf("%.0f%% match: %s", p.Score*100, strings.Join(p.Reasons, ", ")))
+		actions := container.NewHBox(keepA, keepB, notDup)
+		content.Add(widget.NewCard("", "", container.NewVBox(match, sides, actions)))
+	}
+
+	top := container.NewHBox(backBtn, header)
+	return container.NewBorder(top, nil, nil, nil, container.NewVScroll(content))
+}
+
+// duplicateSide shows one transaction of a pair.
+func duplicateSide(t model.Transaction, accountNames, categoryNames map[int64]string) fyne.CanvasObject {
+	var accs, cats []string
+	for _, s := range t.Splits {
+		if name := accountNames[s.AccountID]; name != "" && s.Amount < 0 {
+			accs = append(accs, name)
+		}
+		if s.CategoryID != nil {
+			cats = append(cats, categoryNames[*s.CategoryID])
+		}
+	}
+	note := t.Note
+	if note == "" {
+		note = "—"
+	}
+	category := strings.Join(cats, ", ")
+	if category == "" {
+		category = "—"
+	}
+
+	form := widget.NewForm(
+		widget.NewFormItem("Date", widget.NewLabel(t.Date.Format("2006-01-02"))),
+		widget.NewFormItem("Amount", widget.NewLabel(templateAmount(t.Splits).Format())),
+		widget.NewFormItem("Account", widget.NewLabel(strings.Join(accs, ", "))),
+		widget.NewFormItem("Category", widget.NewLabel(category)),
+		widget.NewFormItem("Note", widget.NewLabel(note)),
+		widget.NewFormItem("Status", widget.NewLabel(string(t.Status))),
+	)
+	title := widget.NewLabelWithStyle(t.Description, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	title.Wrapping = fyne.TextWrapWord
+	return container.NewVBox(title, form)
+}
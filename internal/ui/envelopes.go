@@ -0,0 +1,227 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/nabinkatwal7/go-eila/internal/model"
+	"github.com/nabinkatwal7/go-eila/internal/repository"
+)
+
+// readyToAssignOption is the picker label for the unassigned money pool.
+const readyToAssignOption = "Ready to Assign"
+
+// NewEnvelopesView shows the zero-based (envelope) budget for the current month.
+func NewEnvelopesView(repo *repository.Repository, a *App) fyne.CanvasObject {
+	return newEnvelopeMonthView(repo, a, time.Now())
+}
+
+func showEnvelopeMonth(repo *repository.Repository, a *App, month time.Time) {
+	a.ContentContainer.Objects = []fyne.CanvasObject{newEnvelopeMonthView(repo, a, month)}
+	a.ContentContainer.Refresh()
+}
+
+func newEnvelopeMonthView(repo *repository.Repository, a *App, month time.Time) fyne.CanvasObject {
+	header := widget.NewLabelWithStyle("Envelopes", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
+	em, err := repo.GetEnvelopeMonth(month)
+	if err != nil {
+		return container.NewVBox(header, widget.NewLabel("Error loading envelopes: "+err.Error()))
+	}
+
+	prevBtn := widget.NewButton("‹", func() {
+		showEnvelopeMonth(repo, a, em.Month.AddDate(0, -1, 0))
+	})
+	nextBtn := widget.NewButton("›", func() {
+		showEnvelopeMonth(repo, a, em.Month.AddDate(0, 1, 0))
+	})
+	monthLabel := widget.NewLabelWithStyle(em.Month.Format("January 2006"), fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+
+	fillBtn := widget.NewButton("Assign Budgeted Amounts", func() {
+		n, err := repo.AssignBudgetedAmounts(em.Month)
+		if err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		showEnvelopeMonth(repo, a, em.Month)
+		dialog.ShowInformation("Assigned", fmt.Sprintf("Filled %d envelope(s) from their monthly budgets.", n), a.Window)
+	})
+
+	base, _ := repo.GetBaseCurrency()
+	money := func(v int64) string {
+		return model.NewMoney(v, base).Format()
+	}
+
+	ready := widget.NewLabelWithStyle("Ready to Assign: "+money(em.ReadyToAssign),
+		fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	if em.ReadyToAssign < 0 {
+		ready.SetText("Ready to Assign: " + money(em.ReadyToAssign) + " (ASSIGNED MORE THAN YOU HAVE!)")
+	}
+	summary := widget.NewLabel(fmt.Sprintf("Income this month: %s   Assigned this month: %s",
+		money(em.Income), money(em.Assigned)))
+
+	top := container.NewVBox(
+		container.NewHBox(header, prevBtn, monthLabel, nextBtn, fillBtn),
+		ready,
+		summary,
+	)
+	if em.OverspentBefore > 0 {
+		top.Add(widget.NewLabel(fmt.Sprintf("Overspending not covered in earlier months: %s", money(em.OverspentBefore))))
+	}
+
+	content := container.NewVBox()
+	for _, e := range em.Envelopes {
+		e := e
+		name := widget.NewLabel(strings.Repeat("    ", e.Depth) + e.CategoryName)
+		figures := fmt.Sprintf("Assigned %s   Activity %s   Available %s",
+			money(e.Assigned), money(e.Activity), money(e.Available))
+		if e.Carryover != 0 {
+			figures = fmt.Sprintf("Carried %s   ", money(e.Carryover)) + figures
+		}
+		amounts := widget.NewLabel(figures)
+		if e.Available < 0 {
+			amounts.TextStyle = fyne.TextStyle{Bold: true, Italic: true}
+			amounts.SetText(figures + " (OVERSPENT!)")
+		}
+
+		assignBtn := widget.NewButton("Assign", func() {
+			showAssignEnvelopeModal(repo, a, em.Month, e, base)
+		})
+		moveBtn := widget.NewButton("Move", func() {
+			showMoveEnvelopeModal(repo, a, em.Month, e, base)
+		})
+		buttons := container.NewHBox(assignBtn, moveBtn)
+		if e.Available < 0 {
+			coverBtn := widget.NewButton("Cover", func() {
+				showCoverOverspendingModal(repo, a, em.Month, e, base)
+			})
+			coverBtn.Importance = widget.HighImportance
+			buttons.Add(coverBtn)
+		}
+
+		content.Add(container.NewBorder(nil, nil, name, buttons, amounts))
+	}
+
+	if len(em.Envelopes) == 0 {
+		content.Add(widget.NewLabel("No categories yet. Create some in the Categories view."))
+	}
+
+	return container.NewBorder(top, nil, nil, nil, container.NewVScroll(content))
+}
+
+func showAssignEnvelopeModal(repo *repository.Repository, a *App, month time.Time, e model.Envelope, currency string) {
+	amtEntry := widget.NewEntry()
+	amtEntry.SetText(model.NewMoney(e.Assigned, currency).String())
+	if e.Assigned == 0 && e.Budgeted > 0 {
+		amtEntry.SetText(model.NewMoney(e.Budgeted, currency).String())
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Assigned ("+currency+")", amtEntry),
+	}
+
+	dialog.ShowForm("Assign to "+e.CategoryName, "Save", "Cancel", items, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		amt, err := ValidateAmount(amtEntry.Text, currency)
+		if err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		if err := repo.AssignToEnvelope(month, e.CategoryID, amt.Amount); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		showEnvelopeMonth(repo, a, month)
+	}, a.Window)
+}
+
+// envelopeOptions lists every category plus the Ready to Assign pool,
+// leaving out exclude.
+func envelopeOptions(repo *repository.Repository, exclude int64) ([]string, map[string]int64, error) {
+	categories, err := repo.GetAllCategories()
+	if err != nil {
+		return nil, nil, err
+	}
+	names, nameToID := categoryOptions(categories)
+
+	options := []string{readyToAssignOption}
+	for _, n := range names {
+		if nameToID[n] != exclude {
+			options = append(options, n)
+		}
+	}
+	nameToID[readyToAssignOption] = 0
+	return options, nameToID, nil
+}
+
+// showMoveEnvelopeModal moves money out of an envelope into another one or
+// back to Ready to Assign.
+func showMoveEnvelopeModal(repo *repository.Repository, a *App, month time.Time, e model.Envelope, currency string) {
+	options, nameToID, err := envelopeOptions(repo, e.CategoryID)
+	if err != nil {
+		dialog.ShowError(err, a.Window)
+		return
+	}
+
+	toSelect := widget.NewSelect(options, nil)
+	toSelect.SetSelected(readyToAssignOption)
+	amtEntry := widget.NewEntry()
+	amtEntry.SetPlaceHolder("Up to " + model.NewMoney(e.Available, currency).String())
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("To", toSelect),
+		widget.NewFormItem("Amount ("+currency+")", amtEntry),
+	}
+
+	dialog.ShowForm("Move from "+e.CategoryName, "Move", "Cancel", items, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		amt, err := ValidateAmount(amtEntry.Text, currency)
+		if err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		if err := repo.MoveEnvelopeFunds(month, e.CategoryID, nameToID[toSelect.Selected], amt.Amount); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		showEnvelopeMonth(repo, a, month)
+	}, a.Window)
+}
+
+// showCoverOverspendingModal lets the user pick which envelope pays for an
+// overspent one.
+func showCoverOverspendingModal(repo *repository.Repository, a *App, month time.Time, e model.Envelope, currency string) {
+	options, nameToID, err := envelopeOptions(repo, e.CategoryID)
+	if err != nil {
+		dialog.ShowError(err, a.Window)
+		return
+	}
+
+	fromSelect := widget.NewSelect(options, nil)
+	fromSelect.SetSelected(readyToAssignOption)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Overspent", widget.NewLabel(model.NewMoney(-e.Available, currency).Format())),
+		widget.NewFormItem("Take From", fromSelect),
+	}
+
+	dialog.ShowForm("Cover "+e.CategoryName, "Cover", "Cancel", items, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		if err := repo.CoverOverspending(month, e.CategoryID, nameToID[fromSelect.Selected]); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		showEnvelopeMonth(repo, a, month)
+	}, a.Window)
+}
@@ -20,7 +20,7 @@ func NewForecastView(repo *repository.Repository) fyne.CanvasObject {
 		content.Add(widget.NewLabel("Error: " + err.Error()))
 	} else {
 		// Summary
-		summary := fmt.Sprintf("Based on your recent average savings of $%.2f / month...", avgSavings)
+		summary := fmt.Sprintf("Based on your recent average savings of %s / month...", avgSavings.Format())
 		content.Add(widget.NewLabel(summary))
 
 		// Simple Line Chart (Simulated with bars for now as Canvas Line is tricky with many points)
@@ -30,7 +30,7 @@ func NewForecastView(repo *repository.Repository) fyne.CanvasObject {
 		for _, p := range points {
 			row := container.NewHBox(
 				widget.NewLabel(p.Month+":"),
-				widget.NewLabelWithStyle(fmt.Sprintf("$%.2f", p.Value), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+				widget.NewLabelWithStyle(p.Value.Format(), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 			)
 			content.Add(row)
 		}
@@ -2,6 +2,7 @@ package ui
 
 import (
 	"fmt"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -21,25 +22,32 @@ func NewInvestmentView(repo *repository.Repository) fyne.CanvasObject {
 
 	content := container.NewVBox()
 
-	totalValue := 0.0
+	base, _ := repo.GetBaseCurrency()
+	totalValue := model.NewMoney(0, base)
 
 	for _, a := range accounts {
 		if a.Type == model.AccountTypeInvest {
-			bal, _ := repo.GetAccountBalance(a.ID)
-			totalValue += bal
+			v, _ := repo.GetAccountValuation(a.ID, time.Now())
+			bal := model.NewMoney(v.BaseValue, base)
+			if sum, err := totalValue.Add(bal); err == nil {
+				totalValue = sum
+			}
 
 			// Mocking Performance for now as we don't have separate 'Cost Basis' tracking in splits yet
 			// In real app, we'd query transfers vs income.
 			// Let's assume 10% gain for demo visual.
-			gain := bal * 0.10
-			cost := bal - gain
+			parts, err := bal.Allocate(1, 9)
+			if err != nil {
+				continue
+			}
+			gain, cost := parts[0], parts[1]
 
 			card := createInvestCard(a.Name, bal, cost, gain)
 			content.Add(card)
 		}
 	}
 
-	summary := widget.NewLabelWithStyle(fmt.Sprintf("Total Portfolio Value: $%.2f", totalValue), fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+	summary := widget.NewLabelWithStyle("Total Portfolio Value: "+totalValue.Format(), fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
 
 	return container.NewVScroll(container.NewVBox(
 		header,
@@ -49,10 +57,10 @@ func NewInvestmentView(repo *repository.Repository) fyne.CanvasObject {
 	))
 }
 
-func createInvestCard(name string, value, cost, gain float64) fyne.CanvasObject {
-	valStr := fmt.Sprintf("Value: $%.2f", value)
-	costStr := fmt.Sprintf("Cost: $%.2f", cost)
-	gainStr := fmt.Sprintf("Unrealized Gain: $%.2f (+10%%)", gain)
+func createInvestCard(name string, value, cost, gain model.Money) fyne.CanvasObject {
+	valStr := "Value: " + value.Format()
+	costStr := "Cost: " + cost.Format()
+	gainStr := fmt.Sprintf("Unrealized Gain: %s (+10%%)", gain.Format())
 
 	return widget.NewCard(name, valStr, container.NewVBox(
 		widget.NewLabel(costStr),
@@ -30,6 +30,14 @@ func NewInvoicingView(repo *repository.Repository) fyne.CanvasObject {
 	preview.SetMinRowsVisible(10)
 
 	btn := widget.NewButton("Generate Invoice", func() {
+		// Invoices are billed in the base currency
+		base, _ := repo.GetBaseCurrency()
+		total, err := ValidateAmount(amount.Text, base)
+		if err != nil {
+			preview.SetText("Invalid amount: " + err.Error())
+			return
+		}
+
 		// Mock ID
 		id := rand.Intn(10000)
 		date := time.Now().Format("2006-01-02")
@@ -41,13 +49,13 @@ Client: %s
 ----------------------------------------
 Description             Amount
 ----------------------------------------
-%s                      $%s
+%s                      %s
 ----------------------------------------
-Total:                  $%s
+Total:                  %s
 
 Thank you for your business!
 Payment due within 30 days.
-`, id, date, client.Text, desc.Text, amount.Text, amount.Text)
+`, id, date, client.Text, desc.Text, total.Format(), total.Format())
 
 		preview.SetText(inv)
 	})
@@ -0,0 +1,265 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/nabinkatwal7/go-eila/internal/model"
+	"github.com/nabinkatwal7/go-eila/internal/repository"
+)
+
+const noDefault = "(none)"
+
+func showPayeesView(a *App) {
+	a.ContentContainer.Objects = []fyne.CanvasObject{NewPayeesView(a.Repo, a)}
+	a.ContentContainer.Refresh()
+}
+
+// NewPayeesView lists payees with their aliases and defaults, below the
+// payees with the most spending in the last 30 days.
+func NewPayeesView(repo *repository.Repository, a *App) fyne.CanvasObject {
+	header := widget.NewLabelWithStyle("Payees", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	addBtn := widget.NewButton("+ New Payee", func() {
+		showPayeeModal(repo, a, nil)
+	})
+
+	top := container.NewVBox(widget.NewLabelWithStyle("Top Payees (Last 30 Days)", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	now := time.Now()
+	from := now.AddDate(0, 0, -30)
+	spending, err := repo.GetPayeeBreakdown(&from, &now, 10)
+	if err != nil {
+		top.Add(widget.NewLabel("Error: " + err.Error()))
+	} else if len(spending) == 0 {
+		top.Add(widget.NewLabel("No spending in the last 30 days."))
+	}
+	for _, s := range spending {
+		top.Add(container.NewBorder(nil, nil, nil,
+			widget.NewLabel(fmt.Sprintf("%s  (%d)", s.Amount.Format(), s.Count)),
+			widget.NewLabel(s.Name)))
+	}
+
+	payees, err := repo.GetPayees()
+	if err != nil {
+		return widget.NewLabel("Error loading payees: " + err.Error())
+	}
+	accounts, _ := repo.GetAllAccounts()
+	accountNames := make(map[int64]string, len(accounts))
+	for _, acc := range accounts {
+		accountNames[acc.ID] = acc.Name
+	}
+	categories, _ := repo.GetAllCategories()
+	categoryNames := make(map[int64]string, len(categories))
+	for _, c := range categories {
+		categoryNames[c.ID] = c.Name
+	}
+
+	list := container.NewVBox()
+	filter := widget.NewEntry()
+	filter.SetPlaceHolder("Filter by name or alias")
+	render := func(text string) {
+		text = strings.ToLower(strings.TrimSpace(text))
+		list.Objects = nil
+		for _, p := range payees {
+			p := p
+			if text != "" && !payeeMatchesFilter(p, text) {
+				continue
+			}
+			details := []string{fmt.Sprintf("%d transaction(s)", p.Transactions)}
+			if len(p.Aliases) > 0 {
+				details = append(details, "also "+strings.Join(p.Aliases, ", "))
+			}
+			if p.DefaultCategoryID != nil {
+				details = append(details, "category "+categoryNames[*p.DefaultCategoryID])
+			}
+			if p.DefaultAccountID != nil {
+				details = append(details, "paid from "+accountNames[*p.DefaultAccountID])
+			}
+			info := widget.NewLabel(p.Name + "\n" + strings.Join(details, " · "))
+			info.Wrapping = fyne.TextWrapWord
+
+			editBtn := widget.NewButton("Edit", func() { showPayeeModal(repo, a, &p) })
+			mergeBtn := widget.NewButton("Merge Into...", func() { showMergePayeeModal(repo, a, payees, p) })
+			deleteBtn := widget.NewButton("Delete", func() {
+				dialog.ShowConfirm("Delete Payee", fmt.Sprintf("Delete %q?", p.Name), func(ok bool) {
+					if !ok {
+						return
+					}
+					if err := repo.DeletePayee(p.ID); err != nil {
+						dialog.ShowError(err, a.Window)
+						return
+					}
+					showPayeesView(a)
+				}, a.Window)
+			})
+			if p.Transactions > 0 {
+				deleteBtn.Disable()
+			}
+			list.Add(container.NewBorder(nil, nil, nil, container.NewHBox(editBtn, mergeBtn, deleteBtn), info))
+		}
+		if len(list.Objects) == 0 {
+			list.Add(widget.NewLabel("No payees found."))
+		}
+		list.Refresh()
+	}
+	filter.OnChanged = render
+	render("")
+
+	content := container.NewVBox(top, widget.NewSeparator(), filter, list)
+	return container.NewBorder(container.NewHBox(header, addBtn), nil, nil, nil, container.NewVScroll(content))
+}
+
+func payeeMatchesFilter(p model.Payee, text string) bool {
+	if strings.Contains(strings.ToLower(p.Name), text) {
+		return true
+	}
+	for _, alias := range p.Aliases {
+		if strings.Contains(strings.ToLower(alias), text) {
+			return true
+		}
+	}
+	return false
+}
+
+// showPayeeModal creates a payee, or edits p when it is not nil.
+func showPayeeModal(repo *repository.Repository, a *App, p *model.Payee) {
+	categories, _ := repo.GetAllCategories()
+	categoryNames, categoryNameToID := categoryOptions(categories)
+	accounts, _ := repo.GetAllAccounts()
+	accountNames := []string{noDefault}
+	accountNameToID := make(map[string]int64)
+	for _, acc := range accounts {
+		if acc.IsClosed || acc.Type == model.AccountTypeIncome || acc.Type == model.AccountTypeExpense {
+			continue
+		}
+		accountNames = append(accountNames, acc.Name)
+		accountNameToID[acc.Name] = acc.ID
+	}
+
+	nameEntry := widget.NewEntry()
+	aliasesEntry := widget.NewMultiLineEntry()
+	aliasesEntry.SetPlaceHolder("One per line, e.g. AMZN Mktp")
+	categorySelect := widget.NewSelect(append([]string{noDefault}, categoryNames...), nil)
+	categorySelect.SetSelected(noDefault)
+	accountSelect := widget.NewSelect(accountNames, nil)
+	accountSelect.SetSelected(noDefault)
+
+	title := "New Payee"
+	if p != nil {
+		title = "Edit Payee"
+		nameEntry.SetText(p.Name)
+		aliasesEntry.SetText(strings.Join(p.Aliases, "\n"))
+		for name, id := range categoryNameToID {
+			if p.DefaultCategoryID != nil && *p.DefaultCategoryID == id {
+				categorySelect.SetSelected(name)
+			}
+		}
+		for name, id := range accountNameToID {
+			if p.DefaultAccountID != nil && *p.DefaultAccountID == id {
+				accountSelect.SetSelected(name)
+			}
+		}
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Aliases", aliasesEntry),
+		widget.NewFormItem("Default Category", categorySelect),
+		widget.NewFormItem("Default Account", accountSelect),
+	}
+	d := dialog.NewForm(title, "Save", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		payee := model.Payee{Name: nameEntry.Text, Aliases: strings.Split(aliasesEntry.Text, "\n")}
+		if id, ok := categoryNameToID[categorySelect.Selected]; ok {
+			payee.DefaultCategoryID = &id
+		}
+		if id, ok := accountNameToID[accountSelect.Selected]; ok {
+			payee.DefaultAccountID = &id
+		}
+		var err error
+		if p == nil {
+			err = repo.CreatePayee(&payee)
+		} else {
+			payee.ID = p.ID
+			err = repo.UpdatePayee(&payee)
+		}
+		if err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		showPayeesView(a)
+	}, a.Window)
+	d.Resize(fyne.NewSize(450, 400))
+	d.Show()
+}
+
+func showMergePayeeModal(repo *repository.Repository, a *App, payees []model.Payee, p model.Payee) {
+	var targetNames []string
+	targetNameToID := make(map[string]int64)
+	for _, other := range payees {
+		if other.ID == p.ID {
+			continue
+		}
+		targetNames = append(targetNames, other.Name)
+		targetNameToID[other.Name] = other.ID
+	}
+	if len(targetNames) == 0 {
+		dialog.ShowInformation("Nothing to Merge Into", "There are no other payees to merge into.", a.Window)
+		return
+	}
+
+	targetSelect := widget.NewSelectEntry(targetNames)
+	items := []*widget.FormItem{
+		widget.NewFormItem("Merge into", targetSelect),
+		widget.NewFormItem("", widget.NewLabel(fmt.Sprintf("%q and its aliases become aliases of the payee you pick.", p.Name))),
+	}
+	dialog.ShowForm(fmt.Sprintf("Merge '%s'", p.Name), "Merge", "Cancel", items, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		targetID, ok := targetNameToID[targetSelect.Text]
+		if !ok {
+			dialog.ShowError(fmt.Errorf("no payee named %q", targetSelect.Text), a.Window)
+			return
+		}
+		if err := repo.MergePayees(p.ID, targetID); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		showPayeesView(a)
+	}, a.Window)
+}
+
+func (a *App) linkPayees() {
+	if _, err := a.Repo.LinkPayees(); err != nil {
+		dialog.ShowError(fmt.Errorf("Linking payees: %w", err), a.Window)
+	}
+}
+
+// newPayeeEntry is a payee field offering known payees. When the text
+// matches a different payee than before, onMatch gets it so the form can
+// fill in the payee's defaults.
+func (a *App) newPayeeEntry(onMatch func(p model.Payee)) *widget.SelectEntry {
+	payees, _ := a.Repo.GetPayees()
+	names := make([]string, len(payees))
+	for i, p := range payees {
+		names[i] = p.Name
+	}
+	entry := widget.NewSelectEntry(names)
+	var last int64
+	entry.OnChanged = func(text string) {
+		p, err := a.Repo.MatchPayee(text)
+		if err != nil || p == nil || p.ID == last {
+			return
+		}
+		last = p.ID
+		onMatch(*p)
+	}
+	return entry
+}
@@ -0,0 +1,236 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/nabinkatwal7/go-eila/internal/model"
+	"github.com/nabinkatwal7/go-eila/internal/repository"
+)
+
+// statementSign converts between stored balances and the way a statement
+// prints them: cards and loans show what is owed as a positive number.
+func statementSign(acc model.Account) int64 {
+	if acc.Type == model.AccountTypeCard || acc.Type == model.AccountTypeLiability {
+		return -1
+	}
+	return 1
+}
+
+func showReconcileView(repo *repository.Repository, a *App, acc model.Account) {
+	a.ContentContainer.Objects = []fyne.CanvasObject{NewReconcileView(repo, a, acc)}
+	a.ContentContainer.Refresh()
+}
+
+func showAccountsView(repo *repository.Repository, a *App) {
+	a.ContentContainer.Objects = []fyne.CanvasObject{NewAccountsView(repo, a)}
+	a.ContentContainer.Refresh()
+}
+
+// NewReconcileView runs a reconcile session for one account: enter the
+// statement, tick cleared transactions until the difference is zero, then
+// finish to lock them as Reconciled.
+func NewReconcileView(repo *repository.Repository, a *App, acc model.Account) fyne.CanvasObject {
+	header := widget.NewLabelWithStyle("Reconcile "+acc.Name, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	backBtn := widget.NewButton("‹ Accounts", func() {
+		showAccountsView(repo, a)
+	})
+
+	rec, err := repo.GetOpenReconciliation(acc.ID)
+	if err != nil {
+		return container.NewVBox(container.NewHBox(backBtn, header), widget.NewLabel("Error loading reconciliation: "+err.Error()))
+	}
+	if rec == nil {
+		startBtn := widget.NewButton("Start Reconciliation", func() {
+			showStatementModal(repo, a, acc, nil)
+		})
+		startBtn.Importance = widget.HighImportance
+		top := container.NewVBox(
+			container.NewHBox(backBtn, header),
+			widget.NewLabel("Enter the closing date and ending balance from your statement to begin."),
+			startBtn,
+			widget.NewSeparator(),
+			widget.NewLabelWithStyle("Past Statements", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		)
+		return container.NewBorder(top, nil, nil, nil, reconcileHistory(repo, acc))
+	}
+
+	sign := statementSign(acc)
+	items, err := repo.GetReconcileItems(rec)
+	if err != nil {
+		return container.NewVBox(container.NewHBox(backBtn, header), widget.NewLabel("Error loading transactions: "+err.Error()))
+	}
+
+	money := func(v int64) string {
+		return model.NewMoney(sign*v, acc.Currency).Format()
+	}
+
+	statementLbl := widget.NewLabel(fmt.Sprintf("Statement ending %s: %s",
+		rec.StatementDate.Format("Jan 2, 2006"), money(rec.StatementBalance)))
+	clearedLbl := widget.NewLabel("")
+	diffLbl := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
+	finishBtn := widget.NewButton("Finish", func() {
+		if err := repo.FinishReconciliation(rec.ID); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		showReconcileView(repo, a, acc)
+		dialog.ShowInformation("Reconciled", "Cleared transactions are now locked as Reconciled.", a.Window)
+	})
+	finishBtn.Importance = widget.HighImportance
+
+	updateSummary := func() {
+		sum, err := repo.GetReconcileSummary(rec)
+		if err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		clearedLbl.SetText(fmt.Sprintf("Cleared balance: %s (previously reconciled %s + cleared %s)",
+			money(sum.ClearedBalance), money(sum.OpeningBalance), money(sum.ClearedAmount)))
+		diffLbl.SetText("Difference: " + money(sum.Difference))
+		if sum.Difference == 0 {
+			finishBtn.Enable()
+		} else {
+			finishBtn.Disable()
+		}
+	}
+	updateSummary()
+
+	list := widget.NewList(
+		func() int {
+			return len(items)
+		},
+		func() fyne.CanvasObject {
+			return container.NewHBox(
+				widget.NewCheck("", nil),
+				widget.NewLabel("2006-01-02"),
+				widget.NewLabel("Description"),
+				widget.NewLabel("Amount"),
+			)
+		},
+		func(i int, o fyne.CanvasObject) {
+			it := &items[i]
+			box := o.(*fyne.Container)
+
+			check := box.Objects[0].(*widget.Check)
+			check.OnChanged = nil // Don't write back while recycling the row
+			check.SetChecked(it.Status == model.TransactionStatusCleared)
+			check.OnChanged = func(cleared bool) {
+				if err := repo.SetTransactionCleared(it.TransactionID, acc.ID, cleared); err != nil {
+					dialog.ShowError(err, a.Window)
+					return
+				}
+				if cleared {
+					it.Status = model.TransactionStatusCleared
+				} else {
+					it.Status = model.TransactionStatusPending
+				}
+				updateSummary()
+			}
+
+			box.Objects[1].(*widget.Label).SetText(it.Date.Format("2006-01-02"))
+			box.Objects[2].(*widget.Label).SetText(it.Description)
+			box.Objects[3].(*widget.Label).SetText(money(it.Amount))
+		},
+	)
+
+	editBtn := widget.NewButton("Edit Statement", func() {
+		showStatementModal(repo, a, acc, rec)
+	})
+	cancelBtn := widget.NewButton("Cancel Reconciliation", func() {
+		dialog.ShowConfirm("Cancel Reconciliation", "Discard this session? Transactions you ticked stay Cleared.", func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := repo.CancelReconciliation(rec.ID); err != nil {
+				dialog.ShowError(err, a.Window)
+				return
+			}
+			showReconcileView(repo, a, acc)
+		}, a.Window)
+	})
+
+	top := container.NewVBox(
+		container.NewHBox(backBtn, header, editBtn, cancelBtn, finishBtn),
+		statementLbl,
+		clearedLbl,
+		diffLbl,
+		widget.NewLabel("Tick each transaction that appears on the statement."),
+	)
+	if len(items) == 0 {
+		top.Add(widget.NewLabel("No unreconciled transactions on or before the statement date."))
+	}
+
+	return container.NewBorder(top, nil, nil, nil, list)
+}
+
+// showStatementModal starts a session, or edits the open one when rec is set.
+func showStatementModal(repo *repository.Repository, a *App, acc model.Account, rec *model.Reconciliation) {
+	sign := statementSign(acc)
+
+	dateEntry := widget.NewEntry()
+	dateEntry.SetText(time.Now().Format("2006-01-02"))
+	balanceEntry := widget.NewEntry()
+	balanceEntry.SetPlaceHolder("Ending balance (e.g. 1234.56)")
+	if rec != nil {
+		dateEntry.SetText(rec.StatementDate.Format("2006-01-02"))
+		balanceEntry.SetText(model.NewMoney(sign*rec.StatementBalance, acc.Currency).String())
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Statement Date", dateEntry),
+		widget.NewFormItem("Ending Balance", balanceEntry),
+	}
+
+	dialog.ShowForm("Statement for "+acc.Name, "Save", "Cancel", items, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		date, err := ValidateDate(dateEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		balance, err := ValidateSignedAmount(balanceEntry.Text, acc.Currency)
+		if err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		if _, err := repo.StartReconciliation(acc.ID, date, sign*balance.Amount); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		showReconcileView(repo, a, acc)
+	}, a.Window)
+}
+
+// reconcileHistory lists the account's finished reconciliations.
+func reconcileHistory(repo *repository.Repository, acc model.Account) fyne.CanvasObject {
+	recs, err := repo.GetReconciliations(acc.ID)
+	if err != nil {
+		return widget.NewLabel("Error loading history: " + err.Error())
+	}
+	if len(recs) == 0 {
+		return widget.NewLabel("This account has not been reconciled yet.")
+	}
+
+	sign := statementSign(acc)
+	return widget.NewList(
+		func() int { return len(recs) },
+		func() fyne.CanvasObject { return widget.NewLabel("Template") },
+		func(i int, o fyne.CanvasObject) {
+			rec := recs[i]
+			text := fmt.Sprintf("Statement ending %s: %s", rec.StatementDate.Format("Jan 2, 2006"),
+				model.NewMoney(sign*rec.StatementBalance, acc.Currency).Format())
+			if rec.CompletedAt != nil {
+				text += " — reconciled " + rec.CompletedAt.Format("Jan 2, 2006")
+			}
+			o.(*widget.Label).SetText(text)
+		},
+	)
+}
@@ -5,11 +5,14 @@ import (
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
+	"github.com/nabinkatwal7/go-eila/internal/model"
 	"github.com/nabinkatwal7/go-eila/internal/repository"
 )
 
-func NewRecurringView(repo *repository.Repository) fyne.CanvasObject {
+func NewRecurringView(repo *repository.Repository, a *App) fyne.CanvasObject {
+	schedules := newSchedulesSection(repo, a)
 	header := widget.NewLabelWithStyle("Smart Recurring Detection", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
 
 	subs, err := repo.DetectRecurringPatterns()
@@ -21,12 +24,34 @@ func NewRecurringView(repo *repository.Repository) fyne.CanvasObject {
 		content.Add(widget.NewLabel("No recurring patterns detected yet. Add more history!"))
 	} else {
 		for _, s := range subs {
-			card := widget.NewCard(s.Name, fmt.Sprintf("Est. $%.2f / %s", s.Amount, s.Frequency),
-				widget.NewLabel("Next Due: " + s.NextDueDate),
+			s := s
+			confirmBtn := widget.NewButton("Confirm", func() {
+				confirmSubscription(repo, a, s)
+			})
+			info := widget.NewLabel(fmt.Sprintf("Next Due: %s   Confidence: %.0f%% (%d payments, last %s)",
+				s.NextDueDate, s.Confidence*100, s.Occurrences, s.LastDate.Format("2006-01-02")))
+			card := widget.NewCard(s.Name, fmt.Sprintf("Est. %s / %s", s.Amount.Format(), s.Frequency),
+				container.NewBorder(nil, nil, nil, confirmBtn, info),
 			)
 			content.Add(card)
 		}
 	}
 
-	return container.NewBorder(header, nil, nil, nil, container.NewVScroll(content))
+	return container.NewVScroll(container.NewVBox(schedules, widget.NewSeparator(), header, content))
+}
+
+// confirmSubscription schedules a detected subscription.
+func confirmSubscription(repo *repository.Repository, a *App, s model.Subscription) {
+	autoPost := widget.NewCheck("Post automatically", nil)
+	msg := widget.NewLabel(fmt.Sprintf("Schedule %s %s %s, starting %s?", s.Name, s.Amount.Format(), s.Frequency, s.NextDueDate))
+	dialog.ShowCustomConfirm("Confirm Subscription", "Schedule", "Cancel", container.NewVBox(msg, autoPost), func(ok bool) {
+		if !ok {
+			return
+		}
+		if _, err := repo.ConfirmSubscription(s, autoPost.Checked); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		showRecurringView(a)
+	}, a.Window)
 }
@@ -0,0 +1,513 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/nabinkatwal7/go-eila/internal/model"
+	"github.com/nabinkatwal7/go-eila/internal/repository"
+)
+
+const (
+	anyAccount  = "Any account"
+	noCategory  = "(leave unchanged)"
+	keepStatus  = "(leave unchanged)"
+	maxPreviews = 200 // Matches listed in a preview; the count covers all
+)
+
+func showRulesView(a *App) {
+	a.ContentContainer.Objects = []fyne.CanvasObject{NewRulesView(a.Repo, a)}
+	a.ContentContainer.Refresh()
+}
+
+// NewRulesView lists the rules in the order they apply, with the batches
+// recently applied to history so they can be undone.
+func NewRulesView(repo *repository.Repository, a *App) fyne.CanvasObject {
+	header := widget.NewLabelWithStyle("Rules", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	addBtn := widget.NewButton("+ New Rule", func() {
+		a.ShowRuleModal(nil)
+	})
+	content := container.NewVBox()
+
+	rules, err := repo.GetRules()
+	if err != nil {
+		content.Add(widget.NewLabel("Error loading rules: " + err.Error()))
+	} else if len(rules) == 0 {
+		content.Add(widget.NewLabel("No rules yet. Rules fill in the category, payee, note, tags or status of new transactions."))
+	} else {
+		content.Add(widget.NewLabel("Rules apply from top to bottom; the first rule to set something wins, and tags add up."))
+	}
+
+	accounts, _ := repo.GetAllAccounts()
+	base, _ := repo.GetBaseCurrency()
+	categories, _ := repo.GetAllCategories()
+	categoryNames := make(map[int64]string, len(categories))
+	for _, c := range categories {
+		categoryNames[c.ID] = c.Name
+	}
+
+	move := func(i, delta int) {
+		ids := make([]int64, len(rules))
+		for k, rule := range rules {
+			ids[k] = rule.ID
+		}
+		ids[i], ids[i+delta] = ids[i+delta], ids[i]
+		if err := repo.ReorderRules(ids); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		showRulesView(a)
+	}
+
+	for i, rule := range rules {
+		i, rule := i, rule
+		upBtn := widget.NewButton("↑", func() { move(i, -1) })
+		downBtn := widget.NewButton("↓", func() { move(i, 1) })
+		if i == 0 {
+			upBtn.Disable()
+		}
+		if i == len(rules)-1 {
+			downBtn.Disable()
+		}
+		editBtn := widget.NewButton("Edit", func() {
+			a.ShowRuleModal(&rule)
+		})
+		testBtn := widget.NewButton("Test", func() {
+			showRulePreview(repo, a, rule, a.Window)
+		})
+		applyBtn := widget.NewButton("Apply to History", func() {
+			applyRuleToHistory(repo, a, rule)
+		})
+		deleteBtn := widget.NewButton("Delete", func() {
+			dialog.ShowConfirm("Delete Rule",
+				fmt.Sprintf("Delete %q? Transactions it already changed stay as they are.", ruleTitle(rule)),
+				func(ok bool) {
+					if !ok {
+						return
+					}
+					if err := repo.DeleteRule(rule.ID); err != nil {
+						dialog.ShowError(err, a.Window)
+						return
+					}
+					showRulesView(a)
+				}, a.Window)
+		})
+
+		summary := widget.NewLabel(fmt.Sprintf("If %s\nthen %s",
+			ruleConditions(rule, accounts, base), ruleActions(rule, categoryNames)))
+		summary.Wrapping = fyne.TextWrapWord
+		actions := container.NewHBox(upBtn, downBtn, editBtn, testBtn, applyBtn, deleteBtn)
+		content.Add(widget.NewCard(fmt.Sprintf("%d. %s", i+1, ruleTitle(rule)), "", container.NewVBox(summary, actions)))
+	}
+
+	content.Add(widget.NewSeparator())
+	content.Add(widget.NewLabelWithStyle("Applied to History", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	batches, err := repo.GetRuleBatches(20)
+	if err != nil {
+		content.Add(widget.NewLabel("Error loading batches: " + err.Error()))
+	} else if len(batches) == 0 {
+		content.Add(widget.NewLabel("Nothing applied yet."))
+	}
+	for _, b := range batches {
+		b := b
+		info := widget.NewLabel(fmt.Sprintf("%s  %s: %d transaction(s) changed",
+			b.AppliedAt.Format("2006-01-02 15:04"), b.RuleName, b.Changed))
+		var action fyne.CanvasObject
+		if b.UndoneAt != nil {
+			action = widget.NewLabel("Undone " + b.UndoneAt.Format("2006-01-02 15:04"))
+		} else {
+			action = widget.NewButton("Undo", func() {
+				dialog.ShowConfirm("Undo Batch",
+					fmt.Sprintf("Put back the %d transaction(s) %q changed?", b.Changed, b.RuleName),
+					func(ok bool) {
+						if !ok {
+							return
+						}
+						if err := repo.UndoRuleBatch(b.ID); err != nil {
+							dialog.ShowError(err, a.Window)
+							return
+						}
+						showRulesView(a)
+					}, a.Window)
+			})
+		}
+		content.Add(container.NewBorder(nil, nil, nil, action, info))
+	}
+
+	top := container.NewHBox(header, addBtn)
+	return container.NewBorder(top, nil, nil, nil, container.NewVScroll(content))
+}
+
+func ruleTitle(rule model.Rule) string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	if rule.Pattern != "" {
+		return rule.Pattern
+	}
+	return fmt.Sprintf("Rule %d", rule.ID)
+}
+
+// ruleConditions describes what a rule matches.
+func ruleConditions(rule model.Rule, accounts []model.Account, base string) string {
+	var parts []string
+	if rule.Pattern != "" {
+		if rule.IsRegex {
+			parts = append(parts, fmt.Sprintf("description matches /%s/", rule.Pattern))
+		} else {
+			parts = append(parts, fmt.Sprintf("description contains %q", rule.Pattern))
+		}
+	}
+	// Amounts are in the account's currency, or the base currency
+	currency := base
+	if rule.AccountID != nil {
+		currency = accountCurrency(accounts, *rule.AccountID)
+		for _, acc := range accounts {
+			if acc.ID == *rule.AccountID {
+				parts = append(parts, "account is "+acc.Name)
+			}
+		}
+	}
+	amount := func(v int64) string {
+		return model.NewMoney(v, currency).Format()
+	}
+	switch {
+	case rule.MinAmount != nil && rule.MaxAmount != nil:
+		parts = append(parts, fmt.Sprintf("amount %s to %s", amount(*rule.MinAmount), amount(*rule.MaxAmount)))
+	case rule.MinAmount != nil:
+		parts = append(parts, "amount at least "+amount(*rule.MinAmount))
+	case rule.MaxAmount != nil:
+		parts = append(parts, "amount at most "+amount(*rule.MaxAmount))
+	}
+	if rule.StartDate != nil {
+		parts = append(parts, "on or after "+rule.StartDate.Format("2006-01-02"))
+	}
+	if rule.EndDate != nil {
+		parts = append(parts, "on or before "+rule.EndDate.Format("2006-01-02"))
+	}
+	return strings.Join(parts, " and ")
+}
+
+// ruleActions describes what a rule sets.
+func ruleActions(rule model.Rule, categoryNames map[int64]string) string {
+	var parts []string
+	if rule.TargetCategoryID != nil {
+		parts = append(parts, "category "+categoryNames[*rule.TargetCategoryID])
+	}
+	if rule.TargetPayee != "" {
+		parts = append(parts, fmt.Sprintf("payee %q", rule.TargetPayee))
+	}
+	if rule.TargetNote != "" {
+		parts = append(parts, fmt.Sprintf("note %q", rule.TargetNote))
+	}
+	if len(rule.TargetTags) > 0 {
+		parts = append(parts, "tags "+strings.Join(rule.TargetTags, ", "))
+	}
+	if rule.TargetStatus != "" {
+		parts = append(parts, "status "+string(rule.TargetStatus))
+	}
+	return "set " + strings.Join(parts, ", ")
+}
+
+// ShowRuleModal creates or edits a rule. The form can test the rule
+// against history before it is saved.
+func (a *App) ShowRuleModal(rule *model.Rule) {
+	title := "New Rule"
+	if rule != nil {
+		title = "Edit Rule"
+	}
+	w := a.FyneApp.NewWindow(title)
+
+	accounts, err := a.Repo.GetAllAccounts()
+	if err != nil {
+		dialog.ShowError(err, a.Window)
+		return
+	}
+	categories, err := a.Repo.GetAllCategories()
+	if err != nil {
+		dialog.ShowError(err, a.Window)
+		return
+	}
+	base, _ := a.Repo.GetBaseCurrency()
+
+	accountNames := []string{anyAccount}
+	accountNameToID := make(map[string]int64)
+	for _, acc := range accounts {
+		accountNames = append(accountNames, acc.Name)
+		accountNameToID[acc.Name] = acc.ID
+	}
+	categoryNames, categoryNameToID := categoryOptions(categories)
+	categoryNames = append([]string{noCategory}, categoryNames...)
+
+	nameEntry := widget.NewEntry()
+	nameEntry.PlaceHolder = "e.g. Coffee shops"
+	patternEntry := widget.NewEntry()
+	patternEntry.PlaceHolder = "Text in the description"
+	regexCheck := widget.NewCheck("Regular expression", nil)
+	minEntry := widget.NewEntry()
+	minEntry.PlaceHolder = "Any"
+	maxEntry := widget.NewEntry()
+	maxEntry.PlaceHolder = "Any"
+	accountSelect := widget.NewSelect(accountNames, nil)
+	accountSelect.Selected = anyAccount
+	startEntry := widget.NewEntry()
+	startEntry.PlaceHolder = "YYYY-MM-DD (optional)"
+	endEntry := widget.NewEntry()
+	endEntry.PlaceHolder = "YYYY-MM-DD (optional)"
+
+	categorySelect := widget.NewSelect(categoryNames, nil)
+	categorySelect.Selected = noCategory
+	payeeEntry := widget.NewEntry()
+	payeeEntry.PlaceHolder = "Replaces the description"
+	noteEntry := widget.NewEntry()
+	tagsEntry := widget.NewEntry()
+	tagsEntry.PlaceHolder = "Comma-separated"
+	statusSelect := widget.NewSelect([]string{keepStatus, string(model.TransactionStatusPending), string(model.TransactionStatusCleared)}, nil)
+	statusSelect.Selected = keepStatus
+
+	// Amounts are in the chosen account's currency, or the base currency
+	amountCurrency := func() string {
+		if id, ok := accountNameToID[accountSelect.Selected]; ok {
+			return accountCurrency(accounts, id)
+		}
+		return base
+	}
+
+	if rule != nil {
+		currency := base
+		if rule.AccountID != nil {
+			currency = accountCurrency(accounts, *rule.AccountID)
+			for _, acc := range accounts {
+				if acc.ID == *rule.AccountID {
+					accountSelect.Selected = acc.Name
+				}
+			}
+		}
+		nameEntry.SetText(rule.Name)
+		patternEntry.SetText(rule.Pattern)
+		regexCheck.Checked = rule.IsRegex
+		if rule.MinAmount != nil {
+			minEntry.SetText(model.NewMoney(*rule.MinAmount, currency).String())
+		}
+		if rule.MaxAmount != nil {
+			maxEntry.SetText(model.NewMoney(*rule.MaxAmount, currency).String())
+		}
+		if rule.StartDate != nil {
+			startEntry.SetText(rule.StartDate.Format("2006-01-02"))
+		}
+		if rule.EndDate != nil {
+			endEntry.SetText(rule.EndDate.Format("2006-01-02"))
+		}
+		if rule.TargetCategoryID != nil {
+			for name, id := range categoryNameToID {
+				if id == *rule.TargetCategoryID {
+					categorySelect.Selected = name
+				}
+			}
+		}
+		payeeEntry.SetText(rule.TargetPayee)
+		noteEntry.SetText(rule.TargetNote)
+		tagsEntry.SetText(strings.Join(rule.TargetTags, ", "))
+		if rule.TargetStatus != "" {
+			statusSelect.Selected = string(rule.TargetStatus)
+		}
+	}
+
+	// readRule turns the form into a rule, keeping the ID and priority
+	readRule := func() (model.Rule, error) {
+		var r model.Rule
+		if rule != nil {
+			r.ID, r.Priority = rule.ID, rule.Priority
+		}
+		r.Name = nameEntry.Text
+		r.Pattern = strings.TrimSpace(patternEntry.Text)
+		r.IsRegex = regexCheck.Checked
+		if id, ok := accountNameToID[accountSelect.Selected]; ok {
+			r.AccountID = &id
+		}
+		for _, bound := range []struct {
+			entry *widget.Entry
+			dst   **int64
+			label string
+		}{{minEntry, &r.MinAmount, "Min Amount"}, {maxEntry, &r.MaxAmount, "Max Amount"}} {
+			if strings.TrimSpace(bound.entry.Text) == "" {
+				continue
+			}
+			amt, err := ValidateAmount(bound.entry.Text, amountCurrency())
+			if err != nil {
+				return r, fmt.Errorf("Invalid %s: %w", bound.label, err)
+			}
+			v := amt.Amount
+			*bound.dst = &v
+		}
+		for _, bound := range []struct {
+			entry *widget.Entry
+			dst   **time.Time
+			label string
+		}{{startEntry, &r.StartDate, "Start"}, {endEntry, &r.EndDate, "End"}} {
+			if strings.TrimSpace(bound.entry.Text) == "" {
+				continue
+			}
+			d, err := ValidateDate(bound.entry.Text)
+			if err != nil {
+				return r, fmt.Errorf("Invalid %s: %w", bound.label, err)
+			}
+			*bound.dst = &d
+		}
+
+		if id, ok := categoryNameToID[categorySelect.Selected]; ok {
+			r.TargetCategoryID = &id
+		}
+		r.TargetPayee = strings.TrimSpace(payeeEntry.Text)
+		r.TargetNote = noteEntry.Text
+		r.TargetTags = strings.Split(tagsEntry.Text, ",")
+		if statusSelect.Selected != keepStatus {
+			r.TargetStatus = model.TransactionStatus(statusSelect.Selected)
+		}
+		return r, nil
+	}
+
+	form := widget.NewForm(
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Description", patternEntry),
+		widget.NewFormItem("", regexCheck),
+		widget.NewFormItem("Min Amount", minEntry),
+		widget.NewFormItem("Max Amount", maxEntry),
+		widget.NewFormItem("Account", accountSelect),
+		widget.NewFormItem("From", startEntry),
+		widget.NewFormItem("To", endEntry),
+	)
+	actions := widget.NewForm(
+		widget.NewFormItem("Set Category", categorySelect),
+		widget.NewFormItem("Set Payee", payeeEntry),
+		widget.NewFormItem("Set Note", noteEntry),
+		widget.NewFormItem("Add Tags", tagsEntry),
+		widget.NewFormItem("Set Status", statusSelect),
+	)
+
+	testBtn := widget.NewButton("Test Against History", func() {
+		r, err := readRule()
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		showRulePreview(a.Repo, a, r, w)
+	})
+	saveBtn := widget.NewButton("Save", func() {
+		r, err := readRule()
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		if r.ID == 0 {
+			err = a.Repo.CreateRule(&r)
+		} else {
+			err = a.Repo.UpdateRule(&r)
+		}
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		w.Close()
+		showRulesView(a)
+	})
+	saveBtn.Importance = widget.HighImportance
+
+	w.Resize(fyne.NewSize(520, 640))
+	w.SetContent(container.NewPadded(container.NewVScroll(container.NewVBox(
+		widget.NewLabelWithStyle("When a transaction matches all of", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		form,
+		widget.NewLabelWithStyle("Then", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		actions,
+		container.NewHBox(testBtn, saveBtn),
+	))))
+	w.Show()
+}
+
+// showRulePreview lists the existing transactions a rule matches and what
+// it would change in each.
+func showRulePreview(repo *repository.Repository, a *App, rule model.Rule, parent fyne.Window) {
+	matches, err := repo.PreviewRule(rule)
+	if err != nil {
+		dialog.ShowError(err, parent)
+		return
+	}
+
+	changing := 0
+	list := container.NewVBox()
+	for i, m := range matches {
+		if len(m.Changes) > 0 {
+			changing++
+		}
+		if i >= maxPreviews {
+			continue
+		}
+		txID := m.Transaction.ID
+		text := fmt.Sprintf("%s  %s  %s", m.Transaction.Date.Format("2006-01-02"), m.Transaction.Description,
+			templateAmount(m.Transaction.Splits).Format())
+		changes := "already up to date"
+		if len(m.Changes) > 0 {
+			changes = strings.Join(m.Changes, "; ")
+		}
+		openBtn := widget.NewButton("Open", func() {
+			a.ShowEditTransactionModal(txID)
+		})
+		detail := widget.NewLabel(changes)
+		detail.Wrapping = fyne.TextWrapWord
+		list.Add(container.NewBorder(nil, nil, nil, openBtn, container.NewVBox(widget.NewLabel(text), detail)))
+	}
+
+	summary := fmt.Sprintf("Matches %d transaction(s); applying it would change %d.", len(matches), changing)
+	if len(matches) > maxPreviews {
+		summary += fmt.Sprintf(" Showing the newest %d.", maxPreviews)
+	}
+	d := dialog.NewCustom("Test: "+ruleTitle(rule), "Close",
+		container.NewBorder(widget.NewLabel(summary), nil, nil, nil, container.NewVScroll(list)), parent)
+	d.Resize(fyne.NewSize(640, 480))
+	d.Show()
+}
+
+// applyRuleToHistory runs a saved rule over existing transactions after
+// confirming how many will change.
+func applyRuleToHistory(repo *repository.Repository, a *App, rule model.Rule) {
+	matches, err := repo.PreviewRule(rule)
+	if err != nil {
+		dialog.ShowError(err, a.Window)
+		return
+	}
+	changing := 0
+	for _, m := range matches {
+		if len(m.Changes) > 0 {
+			changing++
+		}
+	}
+	if changing == 0 {
+		dialog.ShowInformation("Apply to History", "No existing transactions need changing.", a.Window)
+		return
+	}
+
+	dialog.ShowConfirm("Apply to History",
+		fmt.Sprintf("Change %d existing transaction(s) with %q? You can undo this afterwards.", changing, ruleTitle(rule)),
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			batch, err := repo.ApplyRuleToHistory(rule.ID)
+			if err != nil {
+				dialog.ShowError(err, a.Window)
+				return
+			}
+			showRulesView(a)
+			if batch.ID == 0 {
+				dialog.ShowInformation("Apply to History", "No existing transactions needed changing.", a.Window)
+				return
+			}
+			dialog.ShowInformation("Apply to History",
+				fmt.Sprintf("Changed %d transaction(s). Undo it under Applied to History.", batch.Changed), a.Window)
+		}, a.Window)
+}
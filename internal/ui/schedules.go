@@ -0,0 +1,606 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/nabinkatwal7/go-eila/internal/model"
+	"github.com/nabinkatwal7/go-eila/internal/repository"
+)
+
+// templateAmount is the total a split template moves: the sum of its
+// debits, in the currency of the first one.
+func templateAmount(splits []model.Split) model.Money {
+	var total model.Money
+	for _, s := range splits {
+		if s.Amount > 0 {
+			if total.Currency == "" {
+				total.Currency = s.Currency
+			}
+			total.Amount += s.Amount
+		}
+	}
+	return total
+}
+
+func showRecurringView(a *App) {
+	a.ContentContainer.Objects = []fyne.CanvasObject{NewRecurringView(a.Repo, a)}
+	a.ContentContainer.Refresh()
+}
+
+// newSchedulesSection lists the stored recurring schedules.
+func newSchedulesSection(repo *repository.Repository, a *App) fyne.CanvasObject {
+	header := widget.NewLabelWithStyle("Scheduled", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	addBtn := widget.NewButton("+ New Schedule", func() {
+		a.ShowScheduleModal(nil)
+	})
+	content := container.NewVBox(container.NewHBox(header, addBtn))
+
+	rules, err := repo.GetRecurringRules()
+	if err != nil {
+		content.Add(widget.NewLabel("Error loading schedules: " + err.Error()))
+		return content
+	}
+	if len(rules) == 0 {
+		content.Add(widget.NewLabel("No schedules yet. Add one here or use \"Make Recurring\" on a transaction."))
+		return content
+	}
+
+	for _, rule := range rules {
+		rule := rule
+		freq := string(rule.Frequency)
+		if rule.Frequency == model.FrequencyEveryNDays {
+			freq = fmt.Sprintf("Every %d days", rule.IntervalDays)
+		}
+		next := "series ended"
+		if rule.HasOccurrence(rule.NextIndex) {
+			next = "next " + rule.OccurrenceDate(rule.NextIndex).Format("2006-01-02")
+		}
+		mode := "confirm before posting"
+		if rule.AutoPost {
+			mode = "posts automatically"
+		}
+		info := widget.NewLabel(fmt.Sprintf("%s, %s, %s", freq, next, mode))
+		if rule.EndDate != nil {
+			info.SetText(info.Text + ", ends " + rule.EndDate.Format("2006-01-02"))
+		}
+
+		upcomingBtn := widget.NewButton("Upcoming", func() {
+			a.showUpcomingOccurrences(rule.ID)
+		})
+		editBtn := widget.NewButton("Edit", func() {
+			a.ShowScheduleModal(&rule)
+		})
+		endBtn := widget.NewButton("End Series", func() {
+			a.showEndSeriesDialog(rule)
+		})
+		deleteBtn := widget.NewButton("Delete", func() {
+			dialog.ShowConfirm("Delete Schedule",
+				fmt.Sprintf("Delete %q? Transactions it already posted are kept.", rule.Name),
+				func(ok bool) {
+					if !ok {
+						return
+					}
+					if err := repo.DeleteRecurringRule(rule.ID); err != nil {
+						dialog.ShowError(err, a.Window)
+						return
+					}
+					showRecurringView(a)
+				}, a.Window)
+		})
+
+		content.Add(widget.NewCard(rule.Name, templateAmount(rule.Splits).Format(),
+			container.NewVBox(info, container.NewHBox(upcomingBtn, editBtn, endBtn, deleteBtn))))
+	}
+	return content
+}
+
+// ShowScheduleModal creates or edits a schedule. A nil rule starts a new
+// one from a simple expense, income or transfer; a rule that already has a
+// split template (being edited, or copied from a transaction) keeps it.
+func (a *App) ShowScheduleModal(rule *model.RecurringRule) {
+	title := "New Schedule"
+	if rule != nil && rule.ID != 0 {
+		title = "Edit Schedule"
+	}
+	w := a.FyneApp.NewWindow(title)
+
+	accounts, err := a.Repo.GetAllAccounts()
+	if err != nil {
+		dialog.ShowError(err, a.Window)
+		return
+	}
+	categories, err := a.Repo.GetAllCategories()
+	if err != nil {
+		dialog.ShowError(err, a.Window)
+		return
+	}
+
+	var accountNames, transferNames []string
+	accountNameToID := make(map[string]int64)
+	var expenseAccountID, incomeAccountID int64
+	for _, acc := range accounts {
+		switch {
+		case acc.Type == model.AccountTypeExpense:
+			expenseAccountID = acc.ID
+		case acc.Type == model.AccountTypeIncome:
+			incomeAccountID = acc.ID
+		case acc.IsClosed:
+		default:
+			transferNames = append(transferNames, acc.Name)
+			accountNameToID[acc.Name] = acc.ID
+			if acc.Type != model.AccountTypeLiability && acc.Type != model.AccountTypeEquity {
+				accountNames = append(accountNames, acc.Name)
+			}
+		}
+	}
+	categoryNames, categoryNameToID := categoryOptions(categories)
+
+	nameEntry := widget.NewEntry()
+	nameEntry.PlaceHolder = "e.g. Rent"
+	noteEntry := widget.NewEntry()
+
+	typeSelect := widget.NewSelect([]string{"Expense", "Income", "Transfer"}, nil)
+	typeSelect.Selected = "Expense"
+	amountEntry := widget.NewEntry()
+	amountEntry.PlaceHolder = "Amount"
+	accountSelect := widget.NewSelect(accountNames, nil)
+	if len(accountNames) > 0 {
+		accountSelect.Selected = accountNames[0]
+	}
+	categorySelect := widget.NewSelect(categoryNames, nil)
+	if len(categoryNames) > 0 {
+		categorySelect.Selected = categoryNames[0]
+	}
+	toAccountSelect := widget.NewSelect(transferNames, nil)
+
+	frequencies := make([]string, len(model.RecurrenceFrequencies))
+	for i, f := range model.RecurrenceFrequencies {
+		frequencies[i] = string(f)
+	}
+	frequencySelect := widget.NewSelect(frequencies, nil)
+	frequencySelect.Selected = string(model.FrequencyMonthly)
+	intervalEntry := widget.NewEntry()
+	intervalEntry.PlaceHolder = "Days between occurrences"
+
+	startEntry := widget.NewEntry()
+	startEntry.SetText(time.Now().Format("2006-01-02"))
+	endEntry := widget.NewEntry()
+	endEntry.PlaceHolder = "YYYY-MM-DD (blank runs forever)"
+	autoPostCheck := widget.NewCheck("Post automatically (otherwise ask at startup)", nil)
+
+	hasTemplate := rule != nil && len(rule.Splits) > 0
+	if rule != nil {
+		nameEntry.SetText(rule.Name)
+		noteEntry.SetText(rule.Note)
+		frequencySelect.Selected = string(rule.Frequency)
+		if rule.IntervalDays > 0 {
+			intervalEntry.SetText(strconv.Itoa(rule.IntervalDays))
+		}
+		if !rule.StartDate.IsZero() {
+			startEntry.SetText(rule.StartDate.Format("2006-01-02"))
+		}
+		if rule.EndDate != nil {
+			endEntry.SetText(rule.EndDate.Format("2006-01-02"))
+		}
+		autoPostCheck.Checked = rule.AutoPost
+	}
+
+	// Enable only the inputs that apply
+	updateInputs := func() {
+		if frequencySelect.Selected == string(model.FrequencyEveryNDays) {
+			intervalEntry.Enable()
+		} else {
+			intervalEntry.Disable()
+		}
+		if hasTemplate {
+			return
+		}
+		if typeSelect.Selected == "Transfer" {
+			categorySelect.Disable()
+			toAccountSelect.Enable()
+		} else {
+			categorySelect.Enable()
+			toAccountSelect.Disable()
+		}
+	}
+	typeSelect.OnChanged = func(string) { updateInputs() }
+	frequencySelect.OnChanged = func(string) { updateInputs() }
+	updateInputs()
+
+	form := widget.NewForm(
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Note", noteEntry),
+	)
+	if hasTemplate {
+		amount := templateAmount(rule.Splits)
+		form.Append("Template", widget.NewLabel(fmt.Sprintf("%d splits, %s", len(rule.Splits), amount.Format())))
+		amountEntry.SetText(amount.String())
+		form.Append("Amount", amountEntry)
+	} else {
+		form.Append("Type", typeSelect)
+		form.Append("Amount", amountEntry)
+		form.Append("Account", accountSelect)
+		form.Append("Category", categorySelect)
+		form.Append("To Account", toAccountSelect)
+	}
+	form.Append("Frequency", frequencySelect)
+	form.Append("Every N Days", intervalEntry)
+	form.Append("Start", startEntry)
+	form.Append("End", endEntry)
+	form.Append("", autoPostCheck)
+
+	// buildTemplate turns the simple inputs into a balanced split template
+	buildTemplate := func() ([]model.Split, error) {
+		accID := accountNameToID[accountSelect.Selected]
+		currency := accountCurrency(accounts, accID)
+		amount, err := ValidateAmount(amountEntry.Text, currency)
+		if err != nil {
+			return nil, errors.New("Invalid Amount: " + err.Error())
+		}
+		switch typeSelect.Selected {
+		case "Transfer":
+			toID, ok := accountNameToID[toAccountSelect.Selected]
+			if !ok {
+				return nil, errors.New("Choose the account to transfer to")
+			}
+			if toID == accID {
+				return nil, repository.ErrTransferSameAccount
+			}
+			if accountCurrency(accounts, toID) != currency {
+				return nil, errors.New("Scheduled transfers need both accounts in the same currency")
+			}
+			return []model.Split{
+				{AccountID: accID, Amount: -amount.Amount, Currency: currency},
+				{AccountID: toID, Amount: amount.Amount, Currency: currency},
+			}, nil
+		case "Income":
+			catID := categoryNameToID[categorySelect.Selected]
+			return []model.Split{
+				{AccountID: accID, Amount: amount.Amount, Currency: currency},
+				{AccountID: incomeAccountID, CategoryID: &catID, Amount: -amount.Amount, Currency: currency},
+			}, nil
+		default:
+			catID := categoryNameToID[categorySelect.Selected]
+			return []model.Split{
+				{AccountID: accID, Amount: -amount.Amount, Currency: currency},
+				{AccountID: expenseAccountID, CategoryID: &catID, Amount: amount.Amount, Currency: currency},
+			}, nil
+		}
+	}
+
+	saveBtn := widget.NewButton("Save", func() {
+		r := &model.RecurringRule{}
+		if rule != nil {
+			*r = *rule
+		}
+		r.Name = strings.TrimSpace(nameEntry.Text)
+		r.Note = noteEntry.Text
+		r.Frequency = model.RecurrenceFrequency(frequencySelect.Selected)
+		r.AutoPost = autoPostCheck.Checked
+		r.IntervalDays = 0
+		if r.Frequency == model.FrequencyEveryNDays {
+			n, err := strconv.Atoi(strings.TrimSpace(intervalEntry.Text))
+			if err != nil || n <= 0 {
+				dialog.ShowError(errors.New("Every N Days needs a whole number of days"), w)
+				return
+			}
+			r.IntervalDays = n
+		}
+
+		start, err := ValidateDate(startEntry.Text)
+		if err != nil {
+			dialog.ShowError(errors.New("Invalid Start: "+err.Error()), w)
+			return
+		}
+		if rule != nil && rule.ID == 0 && !start.Equal(rule.StartDate) {
+			r.NextIndex = 0 // The copied transaction is no longer the first occurrence
+		}
+		r.StartDate = start
+		r.EndDate = nil
+		if strings.TrimSpace(endEntry.Text) != "" {
+			end, err := ValidateDate(endEntry.Text)
+			if err != nil {
+				dialog.ShowError(errors.New("Invalid End: "+err.Error()), w)
+				return
+			}
+			r.EndDate = &end
+		}
+
+		if hasTemplate {
+			amount := templateAmount(r.Splits)
+			newAmount, err := ValidateAmount(amountEntry.Text, amount.Currency)
+			if err != nil {
+				dialog.ShowError(errors.New("Invalid Amount: "+err.Error()), w)
+				return
+			}
+			if newAmount.Amount != amount.Amount {
+				if r.Splits, err = repository.ScaleTemplate(r.Splits, newAmount.Amount); err != nil {
+					dialog.ShowError(err, w)
+					return
+				}
+			}
+		} else if r.Splits, err = buildTemplate(); err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+
+		if r.ID == 0 {
+			err = a.Repo.CreateRecurringRule(r)
+		} else {
+			err = a.Repo.UpdateRecurringRule(r)
+		}
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		w.Close()
+		showRecurringView(a)
+	})
+
+	w.Resize(fyne.NewSize(500, 600))
+	w.SetContent(container.NewPadded(container.NewVBox(form, saveBtn)))
+	w.Show()
+}
+
+// scheduleFromTransaction copies a transaction into a new schedule whose
+// first occurrence is the transaction itself.
+func scheduleFromTransaction(tx *model.Transaction) *model.RecurringRule {
+	splits := make([]model.Split, len(tx.Splits))
+	for i, s := range tx.Splits {
+		splits[i] = model.Split{
+			AccountID:  s.AccountID,
+			CategoryID: s.CategoryID,
+			Amount:     s.Amount,
+			Currency:   s.Currency,
+		}
+	}
+	return &model.RecurringRule{
+		Name:      tx.Description,
+		Note:      tx.Note,
+		Frequency: model.FrequencyMonthly,
+		StartDate: tx.Date,
+		NextIndex: 1,
+		Splits:    splits,
+	}
+}
+
+// showUpcomingOccurrences lists the next occurrences of a schedule so a
+// single one can be skipped or changed.
+func (a *App) showUpcomingOccurrences(ruleID int64) {
+	var d dialog.Dialog
+	list := container.NewVBox()
+
+	var reload func()
+	reload = func() {
+		list.Objects = nil
+		occs, err := a.Repo.GetUpcomingOccurrences(ruleID, 8)
+		if err != nil {
+			list.Add(widget.NewLabel("Error: " + err.Error()))
+			list.Refresh()
+			return
+		}
+		if len(occs) == 0 {
+			list.Add(widget.NewLabel("This series has ended."))
+		}
+		for _, occ := range occs {
+			occ := occ
+			text := fmt.Sprintf("%s  %s", occ.Transaction.Date.Format("2006-01-02"),
+				templateAmount(occ.Transaction.Splits).Format())
+			switch {
+			case occ.Skip:
+				text += "  (skipped)"
+			case occ.Overridden:
+				text += "  (changed)"
+			}
+			if !occ.Transaction.Date.Equal(occ.Scheduled) {
+				text += "  was " + occ.Scheduled.Format("2006-01-02")
+			}
+
+			skipBtn := widget.NewButton("Skip", func() {
+				o := &model.RecurringOverride{RuleID: occ.RuleID, Index: occ.Index, Skip: true}
+				if err := a.Repo.SetRecurringOverride(o); err != nil {
+					dialog.ShowError(err, a.Window)
+				}
+				reload()
+			})
+			modifyBtn := widget.NewButton("Modify", func() {
+				a.showModifyOccurrence(occ, reload)
+			})
+			resetBtn := widget.NewButton("Reset", func() {
+				if err := a.Repo.ClearRecurringOverride(occ.RuleID, occ.Index); err != nil {
+					dialog.ShowError(err, a.Window)
+				}
+				reload()
+			})
+			if occ.Skip {
+				skipBtn.Disable()
+			}
+			if !occ.Overridden {
+				resetBtn.Disable()
+			}
+			list.Add(container.NewBorder(nil, nil, nil, container.NewHBox(skipBtn, modifyBtn, resetBtn), widget.NewLabel(text)))
+		}
+		list.Refresh()
+	}
+	reload()
+
+	d = dialog.NewCustom("Upcoming", "Close", container.NewVScroll(list), a.Window)
+	d.SetOnClosed(func() { showRecurringView(a) })
+	d.Resize(fyne.NewSize(560, 420))
+	d.Show()
+}
+
+// showModifyOccurrence moves a single occurrence or changes its amount or note.
+func (a *App) showModifyOccurrence(occ model.RecurringOccurrence, done func()) {
+	amount := templateAmount(occ.Transaction.Splits)
+
+	dateEntry := widget.NewEntry()
+	dateEntry.SetText(occ.Transaction.Date.Format("2006-01-02"))
+	amountEntry := widget.NewEntry()
+	amountEntry.SetText(amount.String())
+	noteEntry := widget.NewEntry()
+	noteEntry.SetText(occ.Transaction.Note)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Date", dateEntry),
+		widget.NewFormItem(fmt.Sprintf("Amount (%s)", amount.Currency), amountEntry),
+		widget.NewFormItem("Note", noteEntry),
+	}
+	dialog.ShowForm("Modify Occurrence", "Save", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		date, err := ValidateDate(dateEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		value, err := ValidateAmount(amountEntry.Text, amount.Currency)
+		if err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+
+		o := &model.RecurringOverride{RuleID: occ.RuleID, Index: occ.Index, Note: noteEntry.Text}
+		if !date.Equal(occ.Scheduled) {
+			o.Date = &date
+		}
+		if value.Amount != amount.Amount {
+			o.Amount = &value.Amount
+		}
+		if err := a.Repo.SetRecurringOverride(o); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		done()
+	}, a.Window)
+}
+
+func (a *App) showEndSeriesDialog(rule model.RecurringRule) {
+	endEntry := widget.NewEntry()
+	endEntry.SetText(time.Now().Format("2006-01-02"))
+	if rule.EndDate != nil {
+		endEntry.SetText(rule.EndDate.Format("2006-01-02"))
+	}
+
+	items := []*widget.FormItem{widget.NewFormItem("Last Date", endEntry)}
+	dialog.ShowForm("End "+rule.Name, "End Series", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		end, err := ValidateDate(endEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		if err := a.Repo.EndRecurringRule(rule.ID, end); err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		showRecurringView(a)
+	}, a.Window)
+}
+
+// postDueRecurring runs at startup: auto-post schedules are posted, and
+// due occurrences of the other schedules are offered for confirmation.
+func (a *App) postDueRecurring() {
+	posted, pending, err := a.Repo.ProcessDueRecurring(time.Now())
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("Posting recurring transactions: %w", err), a.Window)
+		return
+	}
+	if len(pending) == 0 {
+		if posted > 0 {
+			a.ContentContainer.Refresh()
+			dialog.ShowInformation("Recurring", fmt.Sprintf("Posted %d scheduled transaction(s).", posted), a.Window)
+		}
+		return
+	}
+
+	summary := widget.NewLabel("")
+	list := container.NewVBox()
+	var d dialog.Dialog
+
+	var reload func()
+	reload = func() {
+		n, due, err := a.Repo.ProcessDueRecurring(time.Now())
+		posted += n
+		if err != nil {
+			dialog.ShowError(err, a.Window)
+			return
+		}
+		pending = due
+		summary.SetText(fmt.Sprintf("%d occurrence(s) due. Posted so far: %d.", len(pending), posted))
+		list.Objects = nil
+
+		// Occurrences of a schedule are handled in order
+		first := make(map[int64]bool)
+		for _, occ := range pending {
+			occ := occ
+			postBtn := widget.NewButton("Post", func() {
+				if err := a.Repo.PostOccurrence(&occ); err != nil {
+					dialog.ShowError(err, a.Window)
+				} else {
+					posted++
+				}
+				reload()
+			})
+			skipBtn := widget.NewButton("Skip", func() {
+				if err := a.Repo.SkipOccurrence(&occ); err != nil {
+					dialog.ShowError(err, a.Window)
+				}
+				reload()
+			})
+			if first[occ.RuleID] {
+				postBtn.Disable()
+				skipBtn.Disable()
+			}
+			first[occ.RuleID] = true
+
+			text := fmt.Sprintf("%s  %s  %s", occ.Transaction.Date.Format("2006-01-02"), occ.RuleName,
+				templateAmount(occ.Transaction.Splits).Format())
+			list.Add(container.NewBorder(nil, nil, nil, container.NewHBox(postBtn, skipBtn), widget.NewLabel(text)))
+		}
+		list.Refresh()
+		if len(pending) == 0 && d != nil {
+			d.Hide()
+		}
+	}
+	reload()
+
+	postAllBtn := widget.NewButton("Post All", func() {
+		// Re-process after each post so skipped occurrences behind it move on too
+		for {
+			n, due, err := a.Repo.ProcessDueRecurring(time.Now())
+			posted += n
+			if err != nil {
+				dialog.ShowError(err, a.Window)
+				break
+			}
+			if len(due) == 0 {
+				break
+			}
+			if err := a.Repo.PostOccurrence(&due[0]); err != nil {
+				dialog.ShowError(fmt.Errorf("%s: %w", due[0].RuleName, err), a.Window)
+				break
+			}
+			posted++
+		}
+		reload()
+	})
+	postAllBtn.Importance = widget.HighImportance
+
+	content := container.NewBorder(summary, postAllBtn, nil, nil, container.NewVScroll(list))
+	d = dialog.NewCustom("Recurring Transactions Due", "Later", content, a.Window)
+	d.SetOnClosed(func() { a.ContentContainer.Refresh() })
+	d.Resize(fyne.NewSize(560, 420))
+	d.Show()
+}
@@ -1,6 +1,8 @@
 package ui
 
 import (
+	"fmt"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
@@ -9,7 +11,8 @@ import (
 	"github.com/nabinkatwal7/go-eila/internal/repository"
 )
 
-func NewSettingsView(repo *repository.Repository, w fyne.Window) fyne.CanvasObject {
+func NewSettingsView(repo *repository.Repository, a *App) fyne.CanvasObject {
+	w := a.Window
 	header := widget.NewLabelWithStyle("Settings & Data", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
 
 	exportBtn := widget.NewButton("Export Data (JSON)", func() {
@@ -90,12 +93,25 @@ func NewSettingsView(repo *repository.Repository, w fyne.Window) fyne.CanvasObje
 					if confirmed {
 						accountName := accountEntry.Text
 						categoryName := categoryEntry.Text
-						err = repo.ImportTransactionsFromCSV(reader.URI().Path(), accountName, categoryName)
+						report, err := repo.ImportTransactionsFromCSV(reader.URI().Path(), accountName, categoryName)
 						if err != nil {
 							dialog.ShowError(err, w)
-						} else {
-							dialog.ShowInformation("Success", "Transactions imported successfully.", w)
+							return
+						}
+						msg := fmt.Sprintf("Imported %d transaction(s).", report.Imported)
+						if report.Skipped > 0 {
+							msg += fmt.Sprintf(" Skipped %d unreadable row(s).", report.Skipped)
 						}
+						if len(report.Duplicates) == 0 {
+							dialog.ShowInformation("Success", msg, w)
+							return
+						}
+						msg += fmt.Sprintf("\n%d look like transactions already recorded. Review them now?", len(report.Duplicates))
+						dialog.ShowConfirm("Possible Duplicates", msg, func(review bool) {
+							if review {
+								showDuplicatesView(a)
+							}
+						}, w)
 					}
 				}, w)
 			importDlg.Resize(fyne.NewSize(400, 200))
@@ -105,14 +121,41 @@ func NewSettingsView(repo *repository.Repository, w fyne.Window) fyne.CanvasObje
 		dlg.Show()
 	})
 
+	ratesImportBtn := widget.NewButton("Import Exchange Rates (ECB/CSV)", func() {
+		dlg := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			if reader == nil {
+				return // Cancelled
+			}
+			defer reader.Close()
+
+			report, err := repo.ImportExchangeRates(reader.URI().Path())
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			dialog.ShowInformation("Exchange Rates Imported", rateImportSummary(report), w)
+		}, w)
+		dlg.SetFilter(storage.NewExtensionFileFilter([]string{".csv", ".xml"}))
+		dlg.Show()
+	})
+
 	snapshotBtn := widget.NewButton("Create Time-Travel Snapshot", func() {
 		// Mock snapshot creation
 		dialog.ShowInformation("Snapshot Created", "A snapshot of your database has been saved to 'snapshots/req_id.db'. (Mock)", w)
 	})
 
-	rolloverBtn := widget.NewButton("Run Budget Rollover", func() {
-		// Mock rollover logic
-		dialog.ShowInformation("Rollover Complete", "Unused budget from last month has been carried forward to current month. (Mock)", w)
+	rolloverBtn := widget.NewButton("Recompute Budget Rollovers", func() {
+		// Stores the ledger from transactions for auditing; Budgets works carry-overs out itself
+		n, err := repo.RecomputeBudgetRollovers()
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		dialog.ShowInformation("Rollover Complete", fmt.Sprintf("Saved %d closed budget period(s) to the rollover ledger. Carry-overs are shown in Budgets.", n), w)
 	})
 
 	return container.NewVBox(
@@ -122,6 +165,10 @@ func NewSettingsView(repo *repository.Repository, w fyne.Window) fyne.CanvasObje
 		exportBtn,
 		importBtn,
 		csvImportBtn,
+		ratesImportBtn,
+		widget.NewSeparator(),
+		widget.NewLabel("Currency"),
+		newCurrencySettings(repo, w),
 		widget.NewSeparator(),
 		widget.NewLabel("Advanced Features"),
 		snapshotBtn,
@@ -1,12 +1,14 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
-	"time"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/nabinkatwal7/go-eila/internal/model"
 	"github.com/nabinkatwal7/go-eila/internal/repository"
@@ -15,81 +17,91 @@ import (
 func NewTransactionsView(repo *repository.Repository, app *App) fyne.CanvasObject {
 	header := widget.NewLabelWithStyle("Transactions", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
 
-	// Search and filter controls
-	searchEntry := widget.NewEntry()
-	searchEntry.SetPlaceHolder("Search by description...")
-
-	startDateEntry := widget.NewEntry()
-	startDateEntry.SetPlaceHolder("Start date (YYYY-MM-DD)")
-	endDateEntry := widget.NewEntry()
-	endDateEntry.SetPlaceHolder("End date (YYYY-MM-DD)")
-
-	minAmountEntry := widget.NewEntry()
-	minAmountEntry.SetPlaceHolder("Min amount")
-	maxAmountEntry := widget.NewEntry()
-	maxAmountEntry.SetPlaceHolder("Max amount")
-
+	// One search bar; see model.ParseSearchQuery for the syntax
 	searchBtn := widget.NewButton("Search", nil)
 	clearBtn := widget.NewButton("Clear", nil)
+	searchEntry := widget.NewEntry()
+	searchEntry.SetPlaceHolder(`e.g. coffee cat:Food amount>50 account:"Chase Visa" after:2025-01-01 -status:pending`)
+	searchEntry.OnSubmitted = func(string) { searchBtn.OnTapped() }
+	helpBtn := widget.NewButtonWithIcon("", theme.HelpIcon(), func() {
+		help := widget.NewLabelWithStyle(searchHelp, fyne.TextAlignLeading, fyne.TextStyle{Monospace: true})
+		dialog.ShowCustom("Search Syntax", "Close", help, app.Window)
+	})
+
+	searchError := widget.NewLabel("")
+	searchError.Importance = widget.DangerImportance
+	searchError.Wrapping = fyne.TextWrapWord
+	searchError.Hide()
 
-	// Table to display transactions
+	// Table to display transactions. Rows are loaded a page at a time; the
+	// next page is fetched when the table draws rows near the end.
+	const pageSize = 100
 	var transactions []model.Transaction
 	var table *widget.Table
+	query := &model.SearchQuery{}
+	var next *repository.TransactionCursor
+	loading := false
+	generation := 0 // Bumped by each new search so stale pages are dropped
 
-	refreshTable := func() {
-		var err error
-		var startDate, endDate *time.Time
-		var minAmount, maxAmount *float64
-
-		// Parse filters
-		if startDateEntry.Text != "" {
-			if d, err := time.Parse("2006-01-02", startDateEntry.Text); err == nil {
-				startDate = &d
-			}
-		}
-		if endDateEntry.Text != "" {
-			if d, err := time.Parse("2006-01-02", endDateEntry.Text); err == nil {
-				endDate = &d
-			}
-		}
-		if minAmountEntry.Text != "" {
-			if amt, err := ValidateAmount(minAmountEntry.Text); err == nil {
-				minAmount = &amt
-			}
-		}
-		if maxAmountEntry.Text != "" {
-			if amt, err := ValidateAmount(maxAmountEntry.Text); err == nil {
-				maxAmount = &amt
-			}
+	loadMore := func() {
+		if next == nil || loading {
+			return
 		}
+		loading = true
+		q, cursor, gen := query, next, generation
+		go func() {
+			page, more, err := repo.SearchTransactions(q, cursor, pageSize)
+			fyne.Do(func() {
+				if gen != generation {
+					return
+				}
+				loading = false
+				if err != nil {
+					dialog.ShowError(err, app.Window)
+					return
+				}
+				transactions = append(transactions, page...)
+				next = more
+				table.Refresh()
+			})
+		}()
+	}
 
-		// Perform search
-		if searchEntry.Text != "" || startDate != nil || endDate != nil || minAmount != nil || maxAmount != nil {
-			transactions, err = repo.SearchTransactions(searchEntry.Text, startDate, endDate, minAmount, maxAmount, 1000)
-		} else {
-			transactions, err = repo.GetRecentTransactions(100)
+	// Amounts in the search are in the base currency
+	base, _ := repo.GetBaseCurrency()
+
+	refreshTable := func() {
+		q, err := model.ParseSearchQuery(searchEntry.Text, base)
+		if err != nil {
+			searchError.SetText("Search: " + err.Error())
+			searchError.Show()
+			return
 		}
+		searchError.Hide()
 
+		page, more, err := repo.SearchTransactions(q, nil, pageSize)
 		if err != nil {
 			dialog.ShowError(err, app.Window)
 			return
 		}
+		generation++
+		loading = false
+		query, transactions, next = q, page, more
 
 		table.Refresh()
 	}
 
-	searchBtn.OnTapped = refreshTable
+	searchBtn.OnTapped = func() {
+		refreshTable()
+		table.ScrollToTop()
+	}
 	clearBtn.OnTapped = func() {
 		searchEntry.SetText("")
-		startDateEntry.SetText("")
-		endDateEntry.SetText("")
-		minAmountEntry.SetText("")
-		maxAmountEntry.SetText("")
-		refreshTable()
+		searchBtn.OnTapped()
 	}
 
 	// Initial load
-	transactions, _ = repo.GetRecentTransactions(100)
+	transactions, next, _ = repo.SearchTransactions(query, nil, pageSize)
 
 	// Get categories for display
 	categories, _ := repo.GetAllCategories()
@@ -97,31 +109,56 @@ func NewTransactionsView(repo *repository.Repository, app *App) fyne.CanvasObjec
 	for _, cat := range categories {
 		categoryIDToName[cat.ID] = cat.Name
 	}
+	accounts, _ := repo.GetAllAccounts()
 
 	tableHeader := []string{"Date", "Payee", "Amount", "Category", "Actions"}
 	table = widget.NewTable(
 		func() (int, int) {
 			return len(transactions), len(tableHeader)
 		},
+		// Cells are reused across columns, so each holds a label, the
+		// highlighted payee text and the action buttons, showing one of them
 		func() fyne.CanvasObject {
-			if len(tableHeader) > 4 {
-				// Actions column
-				return container.NewHBox(
+			rich := widget.NewRichText()
+			rich.Truncation = fyne.TextTruncateEllipsis
+			return container.NewStack(
+				widget.NewLabel("Cell"),
+				rich,
+				container.NewHBox(
 					widget.NewButton("Edit", nil),
 					widget.NewButton("Delete", nil),
-				)
-			}
-			return widget.NewLabelWithStyle("Cell", fyne.TextAlignLeading, fyne.TextStyle{})
+				),
+			)
 		},
 		func(i widget.TableCellID, o fyne.CanvasObject) {
 			if i.Row >= len(transactions) {
 				return
 			}
+			if i.Row >= len(transactions)-pageSize/4 {
+				loadMore()
+			}
 
 			t := transactions[i.Row]
+			cell := o.(*fyne.Container)
+			label := cell.Objects[0].(*widget.Label)
+			rich := cell.Objects[1].(*widget.RichText)
+			box := cell.Objects[2].(*fyne.Container)
+			visible := 0
+			switch i.Col {
+			case 1:
+				visible = 1
+			case 4:
+				visible = 2
+			}
+			for j, obj := range cell.Objects {
+				if j == visible {
+					obj.Show()
+				} else {
+					obj.Hide()
+				}
+			}
 
 			if i.Col == 4 { // Actions column
-				box := o.(*fyne.Container)
 				editBtn := box.Objects[0].(*widget.Button)
 				deleteBtn := box.Objects[1].(*widget.Button)
 
@@ -133,8 +170,11 @@ func NewTransactionsView(repo *repository.Repository, app *App) fyne.CanvasObjec
 					dialog.ShowConfirm("Delete Transaction",
 						fmt.Sprintf("Are you sure you want to delete transaction '%s'?", t.Description),
 						func(confirmed bool) {
-							if confirmed {
-								if err := repo.DeleteTransaction(t.ID); err != nil {
+							if !confirmed {
+								return
+							}
+							deleted := func(err error) {
+								if err != nil {
 									dialog.ShowError(err, app.Window)
 								} else {
 									dialog.ShowInformation("Success", "Transaction deleted", app.Window)
@@ -142,25 +182,40 @@ func NewTransactionsView(repo *repository.Repository, app *App) fyne.CanvasObjec
 									app.ContentContainer.Refresh()
 								}
 							}
+							err := repo.DeleteTransaction(t.ID, false)
+							if errors.Is(err, repository.ErrTransactionReconciled) {
+								dialog.ShowConfirm("Reconciled Transaction",
+									"This transaction has been reconciled against a statement.\nDeleting it will make that statement no longer match. Delete anyway?",
+									func(override bool) {
+										if override {
+											deleted(repo.DeleteTransaction(t.ID, true))
+										}
+									}, app.Window)
+								return
+							}
+							deleted(err)
 						}, app.Window)
 				}
 				return
 			}
 
-			label := o.(*widget.Label)
+			label.TextStyle = fyne.TextStyle{}
+			label.Alignment = fyne.TextAlignLeading
 			switch i.Col {
 			case 0: // Date
 				label.SetText(t.Date.Format("2006-01-02"))
-			case 1: // Payee
-				label.SetText(t.Description)
+			case 1: // Payee with search matches highlighted, then its tags
+				rich.Segments = payeeSegments(t)
+				rich.Refresh()
 			case 2: // Amount
-				var amt int64
+				var amt model.Money
 				for _, s := range t.Splits {
 					if s.Amount > 0 {
-						amt += s.Amount
+						amt.Amount += s.Amount
+						amt.Currency = s.Currency
 					}
 				}
-				label.SetText(fmt.Sprintf("$%.2f", float64(amt)/100.0))
+				label.SetText(amt.Format())
 				label.TextStyle = fyne.TextStyle{Bold: true}
 				label.Alignment = fyne.TextAlignTrailing
 			case 3: // Category
@@ -173,6 +228,9 @@ func NewTransactionsView(repo *repository.Repository, app *App) fyne.CanvasObjec
 						}
 					}
 				}
+				if catName == "" && repository.IsTransfer(&t, accounts) {
+					catName = "Transfer"
+				}
 				label.SetText(catName)
 			}
 		},
@@ -184,30 +242,98 @@ func NewTransactionsView(repo *repository.Repository, app *App) fyne.CanvasObjec
 	table.SetColumnWidth(3, 120) // Category
 	table.SetColumnWidth(4, 150) // Actions
 
-	// Filter controls
 	filterBox := container.NewVBox(
-		container.NewHBox(
-			searchEntry,
-			searchBtn,
-			clearBtn,
-		),
-		container.NewHBox(
-			widget.NewLabel("Date Range:"),
-			startDateEntry,
-			widget.NewLabel("-"),
-			endDateEntry,
-		),
-		container.NewHBox(
-			widget.NewLabel("Amount Range:"),
-			minAmountEntry,
-			widget.NewLabel("-"),
-			maxAmountEntry,
-		),
+		container.NewBorder(nil, nil, nil, container.NewHBox(searchBtn, clearBtn, helpBtn), searchEntry),
+		searchError,
 	)
 
+	duplicatesBtn := widget.NewButton("Find Duplicates", func() {
+		showDuplicatesView(app)
+	})
+
 	return container.NewBorder(
-		container.NewVBox(header, filterBox),
+		container.NewVBox(container.NewHBox(header, duplicatesBtn), filterBox),
 		nil, nil, nil,
 		table,
 	)
 }
+
+const searchHelp = `Words search the payee, note, tags and categories.
+  "whole foods"   words in this order
+  star*           words starting with "star"
+
+Filters:
+  cat:Food                      category, including its subcategories
+  account:"Chase Visa"          any split in the account
+  payee:amazon  desc:…  note:…  text contained in the field
+  tag:trip-2026                 tag on the transaction or a split
+  status:pending                pending, cleared or reconciled
+  after:2025-01-01              on or after the date
+  before:2025-02-01             before the date
+  date:2025-01-15  date>=…      dates also take = < <= > >=
+  amount>50  amount<=12.50      total in the base currency
+
+Put "-" in front of a word or filter to exclude it, e.g. -note:refund.
+Values with spaces go in quotes.`
+
+// transactionTags lists a transaction's own tags followed by any others
+// found on its splits.
+func transactionTags(t model.Transaction) []string {
+	tags := append([]string(nil), t.Tags...)
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		seen[strings.ToLower(tag)] = true
+	}
+	for _, s := range t.Splits {
+		for _, tag := range s.Tags {
+			if !seen[strings.ToLower(tag)] {
+				seen[strings.ToLower(tag)] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// payeeSegments shows a transaction's description and tags. For search
+// results the matched terms are highlighted; when the match was in another
+// field, such as the note, the matching excerpt follows the description.
+func payeeSegments(t model.Transaction) []widget.RichTextSegment {
+	var segments []widget.RichTextSegment
+	if t.Match == nil {
+		segments = markedSegments(t.Description)
+	} else if strings.Contains(t.Match.Description, model.MatchStart) {
+		segments = markedSegments(t.Match.Description)
+	} else {
+		segments = append(markedSegments(t.Description), markedSegments("  · "+t.Match.Snippet)...)
+	}
+	if tags := transactionTags(t); len(tags) > 0 {
+		segments = append(segments, &widget.TextSegment{
+			Text:  "  #" + strings.Join(tags, " #"),
+			Style: widget.RichTextStyle{Inline: true, ColorName: theme.ColorNamePlaceHolder},
+		})
+	}
+	return segments
+}
+
+// markedSegments splits text at model.MatchStart and model.MatchEnd,
+// making the marked parts bold in the primary color.
+func markedSegments(text string) []widget.RichTextSegment {
+	var segments []widget.RichTextSegment
+	for text != "" {
+		before, rest, found := strings.Cut(text, model.MatchStart)
+		if before != "" {
+			segments = append(segments, &widget.TextSegment{Text: before, Style: widget.RichTextStyleInline})
+		}
+		if !found {
+			break
+		}
+		matched, after, _ := strings.Cut(rest, model.MatchEnd)
+		segments = append(segments, &widget.TextSegment{
+			Text:  matched,
+			Style: widget.RichTextStyle{Inline: true, ColorName: theme.ColorNamePrimary, TextStyle: fyne.TextStyle{Bold: true}},
+		})
+		text = after
+	}
+	return segments
+}
@@ -6,20 +6,40 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/nabinkatwal7/go-eila/internal/model"
 )
 
-// ValidateAmount parses a string input and ensures it is a positive number.
-func ValidateAmount(input string) (float64, error) {
+// ValidateAmount parses a string input exactly into the currency's minor
+// units and ensures it is not negative.
+func ValidateAmount(input, currency string) (model.Money, error) {
+	val, err := ValidateSignedAmount(input, currency)
+	if err != nil {
+		return model.Money{}, err
+	}
+	if val.IsNegative() {
+		return model.Money{}, errors.New("amount cannot be negative")
+	}
+	return val, nil
+}
+
+// ValidateSignedAmount parses an amount that may be negative, such as a
+// statement balance.
+func ValidateSignedAmount(input, currency string) (model.Money, error) {
 	input = strings.TrimSpace(input)
 	if input == "" {
-		return 0, errors.New("amount is required")
+		return model.Money{}, errors.New("amount is required")
 	}
-	val, err := strconv.ParseFloat(input, 64)
+	val, err := model.ParseMoney(input, currency)
 	if err != nil {
-		return 0, errors.New("invalid amount format")
-	}
-	if val < 0 {
-		return 0, errors.New("amount cannot be negative")
+		if errors.Is(err, model.ErrInvalidMoney) {
+			if exp := model.CurrencyExponent(currency); exp == 0 {
+				return model.Money{}, fmt.Errorf("invalid amount format (%s has no decimal places)", currency)
+			} else {
+				return model.Money{}, fmt.Errorf("invalid amount format (use at most %d decimal places)", exp)
+			}
+		}
+		return model.Money{}, err
 	}
 	return val, nil
 }
@@ -44,3 +64,22 @@ func ValidateRequired(input, fieldName string) error {
 	}
 	return nil
 }
+
+// ValidateColor checks that a non-empty input is a hex color like #FF8800.
+// An empty input is allowed and means "no color".
+func ValidateColor(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(input, "#") {
+		input = "#" + input
+	}
+	if len(input) != 7 {
+		return "", errors.New("color must be in #RRGGBB format")
+	}
+	if _, err := strconv.ParseUint(input[1:], 16, 32); err != nil {
+		return "", errors.New("color must be in #RRGGBB format")
+	}
+	return strings.ToUpper(input), nil
+}